@@ -1,16 +1,21 @@
 package main
 
 import (
+	"context"
 	"os"
+	"os/signal"
+	"syscall"
 
 	"github.com/ksysoev/authkeeper/pkg/cmd"
+	"github.com/ksysoev/authkeeper/pkg/ui"
 )
 
 var version = "dev"
 
 func main() {
 	if err := run(); err != nil {
-		os.Exit(1)
+		ui.ReportError(err)
+		os.Exit(ui.ExitCode(err))
 	}
 }
 
@@ -20,5 +25,9 @@ func run() error {
 		return err
 	}
 
-	return rootCmd.Execute()
+	// Ctrl-C cancels the command context, so waiting for an authorization stops cleanly
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	return rootCmd.ExecuteContext(ctx)
 }
@@ -4,19 +4,27 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"net/url"
 	"strings"
+	"sync/atomic"
 )
 
 // MockOAuthServer is a simple mock OAuth2 server for testing
 // Run with: go run examples/mock-server/main.go
 func main() {
 	http.HandleFunc("/oauth/token", handleToken)
+	http.HandleFunc("/oauth/authorize", handleAuthorize)
+	http.HandleFunc("/oauth/device", handleDevice)
 
 	fmt.Println("🔐 Mock OAuth2 Server starting on :8080")
 	fmt.Println("Token endpoint: http://localhost:8080/oauth/token")
+	fmt.Println("Authorization endpoint: http://localhost:8080/oauth/authorize")
+	fmt.Println("Device authorization endpoint: http://localhost:8080/oauth/device")
 	fmt.Println("Test credentials:")
 	fmt.Println("  Client ID: test-client-id")
 	fmt.Println("  Client Secret: test-client-secret")
+	fmt.Println("  Username: test-user (password grant)")
+	fmt.Println("  Password: test-password")
 	fmt.Println()
 
 	if err := http.ListenAndServe(":8080", nil); err != nil {
@@ -41,14 +49,56 @@ func handleToken(w http.ResponseWriter, r *http.Request) {
 
 	fmt.Printf("📥 Token request: grant_type=%s, client_id=%s\n", grantType, clientID)
 
-	if grantType != "client_credentials" {
-		http.Error(w, "Unsupported grant type", http.StatusBadRequest)
-		return
-	}
+	switch grantType {
+	case "client_credentials":
+		// Simple validation
+		if clientID == "" || clientSecret == "" {
+			http.Error(w, "Invalid credentials", http.StatusUnauthorized)
+			return
+		}
+	case "authorization_code":
+		// Public clients only send their ID, the code verifier proves the request is theirs
+		if clientID == "" || r.FormValue("code") != mockCode || r.FormValue("code_verifier") == "" {
+			http.Error(w, "Invalid grant", http.StatusBadRequest)
+			return
+		}
+	case "urn:ietf:params:oauth:grant-type:device_code":
+		if clientID == "" || r.FormValue("device_code") != mockDeviceCode {
+			writeOAuthError(w, "invalid_grant")
+			return
+		}
 
-	// Simple validation
-	if clientID == "" || clientSecret == "" {
-		http.Error(w, "Invalid credentials", http.StatusUnauthorized)
+		// The first poll finds the request still waiting for the user
+		if devicePolls.Add(1)%2 == 1 {
+			writeOAuthError(w, "authorization_pending")
+			return
+		}
+	case "urn:ietf:params:oauth:grant-type:token-exchange":
+		if clientID == "" || clientSecret == "" || r.FormValue("subject_token") == "" || r.FormValue("subject_token_type") == "" {
+			writeOAuthError(w, "invalid_request")
+			return
+		}
+
+		fmt.Printf("🔁 Exchanging %s for audience %v\n", r.FormValue("subject_token_type"), r.Form["audience"])
+	case "password":
+		if clientID == "" || r.FormValue("username") != "test-user" || r.FormValue("password") != "test-password" {
+			writeOAuthError(w, "invalid_grant")
+			return
+		}
+	case "urn:ietf:params:oauth:grant-type:jwt-bearer":
+		// The assertion signature is not verified, any signed assertion is accepted
+		if strings.Count(r.FormValue("assertion"), ".") != 2 {
+			writeOAuthError(w, "invalid_grant")
+			return
+		}
+	case "refresh_token":
+		// Refresh tokens are rotated, only the most recently issued one is accepted
+		if clientID == "" || r.FormValue("refresh_token") != mockRefreshToken(refreshCount.Load()) {
+			writeOAuthError(w, "invalid_grant")
+			return
+		}
+	default:
+		http.Error(w, "Unsupported grant type", http.StatusBadRequest)
 		return
 	}
 
@@ -66,12 +116,100 @@ func handleToken(w http.ResponseWriter, r *http.Request) {
 		"scope":        strings.TrimSpace(scope),
 	}
 
+	if grantType == "urn:ietf:params:oauth:grant-type:token-exchange" {
+		issuedType := r.FormValue("requested_token_type")
+		if issuedType == "" {
+			issuedType = "urn:ietf:params:oauth:token-type:access_token"
+		}
+
+		response["issued_token_type"] = issuedType
+	}
+
+	// User tokens come with a refresh token
+	if grantType == "authorization_code" || grantType == "refresh_token" || strings.HasSuffix(grantType, ":device_code") {
+		response["refresh_token"] = mockRefreshToken(refreshCount.Add(1))
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 	_ = json.NewEncoder(w).Encode(response)
 
 	fmt.Printf("✅ Token issued for client: %s\n", clientID)
 }
 
+// mockCode is the authorization code issued by the mock authorization endpoint
+const mockCode = "mock_authorization_code"
+
+// handleAuthorize approves every authorization request without a login page
+// and redirects straight back to the client
+func handleAuthorize(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	redirectURI, err := url.Parse(query.Get("redirect_uri"))
+	if err != nil || redirectURI.Hostname() == "" {
+		http.Error(w, "Invalid redirect_uri", http.StatusBadRequest)
+		return
+	}
+
+	if query.Get("code_challenge_method") != "S256" {
+		http.Error(w, "PKCE with S256 is required", http.StatusBadRequest)
+		return
+	}
+
+	fmt.Printf("📥 Authorization request: client_id=%s, redirect_uri=%s\n", query.Get("client_id"), redirectURI)
+
+	params := redirectURI.Query()
+	params.Set("code", mockCode)
+	params.Set("state", query.Get("state"))
+	redirectURI.RawQuery = params.Encode()
+
+	http.Redirect(w, r, redirectURI.String(), http.StatusFound)
+}
+
+// mockDeviceCode is the device code issued by the mock device authorization endpoint
+const mockDeviceCode = "mock_device_code"
+
+// devicePolls counts device code token requests, every second one is approved
+var devicePolls atomic.Int64
+
+// handleDevice issues a device code that is approved on the second poll
+func handleDevice(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Bad request", http.StatusBadRequest)
+		return
+	}
+
+	fmt.Printf("📥 Device authorization request: client_id=%s\n", r.FormValue("client_id"))
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"device_code":               mockDeviceCode,
+		"user_code":                 "MOCK-CODE",
+		"verification_uri":          "http://localhost:8080/device",
+		"verification_uri_complete": "http://localhost:8080/device?user_code=MOCK-CODE",
+		"expires_in":                300,
+		"interval":                  1,
+	})
+}
+
+// writeOAuthError writes an RFC 6749 error response
+func writeOAuthError(w http.ResponseWriter, code string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusBadRequest)
+	_ = json.NewEncoder(w).Encode(map[string]string{"error": code})
+}
+
+// refreshCount numbers the issued refresh tokens
+var refreshCount atomic.Int64
+
+func mockRefreshToken(n int64) string {
+	return fmt.Sprintf("mock_refresh_token_%d", n)
+}
+
 func generateMockToken(clientID string) string {
 	// Simple mock token for demonstration
 	return fmt.Sprintf("mock_token_%s_%d", clientID, 12345)
@@ -4,8 +4,15 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+
+	"golang.org/x/term"
 )
 
+// stdinIsTerminal reports whether stdin is an interactive terminal
+var stdinIsTerminal = func() bool {
+	return term.IsTerminal(int(os.Stdin.Fd()))
+}
+
 // getDefaultVaultPath returns the default path for the vault file.
 // It returns a string representing the path and an error if the path cannot be determined.
 func getDefaultVaultPath() (string, error) {
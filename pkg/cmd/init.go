@@ -2,6 +2,10 @@ package cmd
 
 import (
 	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/ksysoev/authkeeper/pkg/core"
 	"github.com/ksysoev/authkeeper/pkg/prov"
@@ -11,8 +15,20 @@ import (
 )
 
 type args struct {
-	version   string
-	vaultPath string
+	version         string
+	vaultPath       string
+	backups         int
+	passwordFile    string
+	passwordFD      int
+	passwordCommand string
+	output          string
+	noCache         bool
+	refreshSkew     time.Duration
+	timeout         time.Duration
+	proxyURL        string
+	caFile          string
+	tlsMinVersion   string
+	retries         int
 }
 
 // InitCommands initializes and returns the root command for the AuthKeeper service.
@@ -35,48 +51,161 @@ func InitCommands(version string) (*cobra.Command, error) {
 		Short:   "OAuth2/OIDC credential manager",
 		Long:    "A beautiful CLI tool for managing OAuth2/OIDC credentials and issuing access tokens with encrypted vault storage.",
 		Version: version,
+		// Errors are reported by the caller with a hint and exit code, see ui.ReportError
+		SilenceErrors: true,
+		PersistentPreRun: func(cmd *cobra.Command, _ []string) {
+			// Flags and arguments are valid at this point, later errors are not usage errors
+			cmd.SilenceUsage = true
+		},
 	}
 
+	cmd.SetFlagErrorFunc(func(_ *cobra.Command, err error) error {
+		return ui.UsageError(err)
+	})
+
 	cmd.PersistentFlags().StringVarP(&args.vaultPath, "vault", "v", args.vaultPath, "Path to the encrypted vault file")
+	cmd.PersistentFlags().StringVarP(&args.output, "output", "o", string(ui.OutputText), "Output format: text, json, yaml, env, raw or header")
+	cmd.PersistentFlags().IntVar(&args.backups, "backups", repo.DefaultBackups, "Number of previous vault generations to keep (0 disables backups)")
+	cmd.PersistentFlags().StringVar(&args.passwordFile, "password-file", "", "Read the master password from the first line of a file")
+	cmd.PersistentFlags().IntVar(&args.passwordFD, "password-fd", -1, "Read the master password from an open file descriptor")
+	cmd.PersistentFlags().StringVar(&args.passwordCommand, "password-command", "", "Run a command and use the first line of its output as the master password")
+	cmd.PersistentFlags().BoolVar(&args.noCache, "no-cache", false, "Don't read or store access tokens in the vault token cache")
+	cmd.PersistentFlags().DurationVar(&args.refreshSkew, "refresh-skew", core.DefaultRefreshSkew, "Refresh cached tokens that expire within this duration")
+	cmd.PersistentFlags().DurationVar(&args.timeout, "timeout", prov.DefaultTimeout, "Timeout of a single request to the authorization server")
+	cmd.PersistentFlags().StringVar(&args.proxyURL, "proxy", "", "Proxy URL for requests to the authorization server, e.g. http://proxy:3128 (HTTPS_PROXY is used by default)")
+	cmd.PersistentFlags().StringVar(&args.caFile, "ca-file", "", "PEM file with CA certificates trusted in addition to the system roots")
+	cmd.PersistentFlags().StringVar(&args.tlsMinVersion, "tls-min-version", "", "Minimum TLS version: 1.2 (default) or 1.3")
+	cmd.PersistentFlags().IntVar(&args.retries, "retries", prov.DefaultRetries, "Retries of requests failing with 429, 5xx or a connection reset")
 
 	cmd.AddCommand(AddCommand(args))
 	cmd.AddCommand(TokenCommand(args))
+	cmd.AddCommand(ExchangeCommand(args))
 	cmd.AddCommand(ListCommand(args))
+	cmd.AddCommand(EditCommand(args))
 	cmd.AddCommand(DeleteCommand(args))
+	cmd.AddCommand(PasswdCommand(args))
+	cmd.AddCommand(BackupCommand(args))
+	cmd.AddCommand(JWTCommand(args))
 
 	return cmd, nil
 }
 
 // initCLI initializes the CLI with the vault repository and provider
-func initCLI(arg *args) *ui.CLI {
-	repository := repo.NewVaultRepository(arg.vaultPath)
-	provider := prov.NewOAuthProvider()
-	service := core.NewService(repository, provider)
+func initCLI(arg *args, opts ...ui.Option) (*ui.CLI, error) {
+	format, err := ui.ParseOutputFormat(arg.output)
+	if err != nil {
+		return nil, err
+	}
+
+	settings, err := httpSettings(arg)
+	if err != nil {
+		return nil, err
+	}
+
+	repository := repo.NewVaultRepository(arg.vaultPath, repo.WithBackups(arg.backups))
+	provider := prov.NewOAuthProvider(prov.WithHTTPSettings(settings))
+	service := core.NewService(repository, provider, core.WithRefreshSkew(arg.refreshSkew))
+
+	opts = append([]ui.Option{
+		ui.WithPasswordSource(ui.PasswordSource{
+			File:    arg.passwordFile,
+			FD:      arg.passwordFD,
+			Command: arg.passwordCommand,
+		}),
+		ui.WithOutputFormat(format),
+	}, opts...)
+
+	return ui.NewCLI(service, opts...), nil
+}
 
-	return ui.NewCLI(service)
+// httpSettings returns the global HTTP settings given on the command line
+func httpSettings(arg *args) (core.HTTPSettings, error) {
+	settings := core.HTTPSettings{
+		Timeout:       arg.timeout,
+		ProxyURL:      arg.proxyURL,
+		TLSMinVersion: arg.tlsMinVersion,
+		Retries:       &arg.retries,
+	}
+
+	if arg.caFile != "" {
+		data, err := os.ReadFile(arg.caFile)
+		if err != nil {
+			return core.HTTPSettings{}, fmt.Errorf("failed to read CA file: %w", err)
+		}
+
+		settings.CACertificates = string(data)
+	}
+
+	if err := settings.Validate(); err != nil {
+		return core.HTTPSettings{}, ui.UsageError(err)
+	}
+
+	return settings, nil
 }
 
 // AddCommand creates a new cobra.Command to add a new OIDC client to the vault.
 // It returns a pointer to a cobra.Command which can be executed to add a client.
 func AddCommand(arg *args) *cobra.Command {
-	var name, clientID, clientSecret, tokenURL, scopes string
+	var in ui.ClientInput
+	var scopes string
+	var retries int
 
 	cmd := &cobra.Command{
 		Use:   "add [flags]",
 		Short: "Add a new OIDC client to the vault",
 		Long:  `Add a new OIDC client with credentials to the encrypted vault. If flags are not provided, the command will prompt interactively.`,
 		RunE: func(cmd *cobra.Command, _ []string) error {
-			cli := initCLI(arg)
+			cli, err := initCLI(arg)
+			if err != nil {
+				return err
+			}
+
+			if cmd.Flags().Changed("scopes") {
+				in.Scopes = &scopes
+			}
+
+			if cmd.Flags().Changed("http-retries") {
+				in.Retries = &retries
+			}
 
-			return cli.AddClient(cmd.Context(), name, clientID, clientSecret, tokenURL, scopes)
+			return cli.AddClient(cmd.Context(), in)
 		},
 	}
 
-	cmd.Flags().StringVarP(&name, "name", "n", "", "Client name")
-	cmd.Flags().StringVarP(&clientID, "client-id", "c", "", "Client ID")
-	cmd.Flags().StringVarP(&clientSecret, "client-secret", "s", "", "Client secret")
-	cmd.Flags().StringVarP(&tokenURL, "token-url", "t", "", "Token URL")
+	cmd.Flags().StringVarP(&in.Name, "name", "n", "", "Client name")
+	cmd.Flags().StringVarP(&in.ClientID, "client-id", "c", "", "Client ID")
+	cmd.Flags().StringVarP(&in.ClientSecret, "client-secret", "s", "", "Client secret")
+	cmd.Flags().StringVarP(&in.TokenURL, "token-url", "t", "", "Token URL")
+	cmd.Flags().StringVar(&in.Issuer, "issuer", "", "Issuer URL, the token URL and a supported auth method are discovered from its metadata")
 	cmd.Flags().StringVar(&scopes, "scopes", "", "Scopes (space-separated)")
+	cmd.Flags().StringVar(&in.AuthMethod, "auth-method", "", "Token endpoint auth method: client_secret_post (default), client_secret_basic, client_secret_jwt, private_key_jwt, tls_client_auth, self_signed_tls_client_auth or none")
+	cmd.Flags().StringVar(&in.PrivateKeyFile, "private-key", "", "PEM file with the private key signing private_key_jwt or jwt_bearer assertions")
+	cmd.Flags().StringVar(&in.KeyID, "key-id", "", "Key ID sent as the kid header of client assertions")
+	cmd.Flags().StringVar(&in.TLSCertFile, "tls-cert", "", "PEM file with the client certificate presented to the token endpoint (mutual TLS)")
+	cmd.Flags().StringVar(&in.TLSKeyFile, "tls-key", "", "PEM file with the private key of the client certificate")
+	cmd.Flags().StringVar(&in.SigningAlgorithm, "signing-alg", "", "Client assertion signing algorithm, e.g. RS256, PS256, ES256, EdDSA or HS256 (derived from the key or secret by default)")
+	cmd.Flags().StringVar(&in.GrantType, "grant", "", "Default grant used by the token command: client_credentials (default), authorization_code, device_code, jwt_bearer or password (legacy)")
+	cmd.Flags().StringVar(&in.AuthorizationURL, "authorization-url", "", "Authorization endpoint for the authorization_code grant (discovered from the issuer when omitted)")
+	cmd.Flags().StringVar(&in.RedirectURL, "redirect-url", "", "Loopback redirect URL registered for the client, e.g. http://127.0.0.1:8765/callback (any free port by default)")
+	cmd.Flags().StringVar(&in.DeviceAuthorizationURL, "device-authorization-url", "", "Device authorization endpoint for the device_code grant (discovered from the issuer when omitted)")
+	cmd.Flags().StringVar(&in.ServiceAccountFile, "service-account", "", "Import a Google service account JSON key file as a jwt_bearer client")
+	cmd.Flags().StringVar(&in.AssertionIssuer, "assertion-issuer", "", "iss claim of jwt_bearer assertions (client ID by default)")
+	cmd.Flags().StringVar(&in.AssertionSubject, "assertion-subject", "", "sub claim of jwt_bearer assertions, e.g. the user to impersonate")
+	cmd.Flags().StringVar(&in.AssertionAudience, "assertion-audience", "", "aud claim of jwt_bearer assertions (token URL by default)")
+	cmd.Flags().StringVar(&in.Username, "username", "", "Username stored for the password grant (asked for on every token request when omitted)")
+	cmd.Flags().StringVar(&in.Password, "user-password", "", "Password stored for the password grant")
+	cmd.Flags().StringVar(&in.Audience, "audience", "", "Audience sent with token requests, e.g. the API identifier Auth0 requires")
+	cmd.Flags().StringArrayVar(&in.Resource, "resource", nil, "Resource indicator (RFC 8707) sent with token requests, can be repeated")
+	cmd.Flags().StringArrayVar(&in.Params, "param", nil, "Extra form parameter key=value sent with token requests, can be repeated")
+	cmd.Flags().StringArrayVarP(&in.Headers, "header", "H", nil, "Extra HTTP header \"Name: value\" sent with token requests, can be repeated")
+	cmd.Flags().DurationVar(&in.Timeout, "http-timeout", 0, "Request timeout for this client, overrides --timeout")
+	cmd.Flags().StringVar(&in.ProxyURL, "http-proxy", "", "Proxy URL for this client, overrides --proxy")
+	cmd.Flags().StringVar(&in.CAFile, "http-ca-file", "", "PEM file with CA certificates trusted for this client in addition to --ca-file")
+	cmd.Flags().StringVar(&in.TLSMinVersion, "http-tls-min-version", "", "Minimum TLS version for this client, overrides --tls-min-version")
+	cmd.Flags().IntVar(&retries, "http-retries", 0, "Retries for this client, overrides --retries")
+	cmd.Flags().StringVar(&in.PinnedPublicKey, "pinned-pubkey", "", "Trust a server presenting this public key instead of a CA signed certificate (base64 SHA-256 of the SPKI)")
+	cmd.Flags().BoolVar(&in.InsecureSkipVerify, "insecure-skip-verify", false, "Don't verify the server certificate, only allowed for loopback and private addresses (INSECURE)")
+	cmd.Flags().BoolVarP(&in.Yes, "yes", "y", false, "Save the client without asking for confirmation")
 
 	return cmd
 }
@@ -84,24 +213,148 @@ func AddCommand(arg *args) *cobra.Command {
 // TokenCommand creates a new cobra.Command to issue an access token.
 // It returns a pointer to a cobra.Command which can be executed to issue a token.
 func TokenCommand(arg *args) *cobra.Command {
-	var clientName string
+	var clientName, grantName, audience string
+	var refresh, promptUser, decode bool
+	var scopes, addScopes, removeScopes, resource, params, headers []string
 
 	cmd := &cobra.Command{
 		Use:   "token [client-name]",
 		Short: "Issue an access token",
-		Long:  `Issue an access token for an OIDC client using client credentials flow. If client name is not provided, you will be prompted to select from available clients.`,
+		Long:  `Issue an access token for an OIDC client using the client's default grant, client credentials unless configured otherwise. The authorization_code grant opens a browser to sign in and receives the response on a loopback address. The device_code grant shows a code to approve on any other device and waits until it is approved, press Ctrl-C to stop waiting. The legacy password grant uses the user credentials stored with the client or asks for them. If client name is not provided, you will be prompted to select from available clients. A still valid token from the encrypted vault token cache is returned instead of requesting a new one.`,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			cli := initCLI(arg)
+			var grant core.GrantType
+			if grantName != "" {
+				var err error
+				if grant, err = core.ParseGrantType(grantName); err != nil {
+					return err
+				}
+			}
+
+			extraParams, err := core.ParseExtraParams(params)
+			if err != nil {
+				return err
+			}
+
+			extraHeaders, err := core.ParseExtraHeaders(headers)
+			if err != nil {
+				return err
+			}
+
+			var requested []string
+			if cmd.Flags().Changed("scope") {
+				// Not nil even when empty, so --scope "" requests no scopes at all
+				requested = append([]string{}, splitScopes(scopes)...)
+			}
+
+			if decode {
+				format, err := ui.ParseOutputFormat(arg.output)
+				if err != nil {
+					return err
+				}
+
+				switch format {
+				case ui.OutputEnv, ui.OutputRaw, ui.OutputHeader:
+					return ui.UsageError(fmt.Errorf("--decode requires the text, json or yaml output format"))
+				}
+			}
+
+			cli, err := initCLI(arg, ui.WithDecodedTokens(decode))
+			if err != nil {
+				return err
+			}
 
 			if len(args) > 0 && clientName == "" {
 				clientName = args[0]
 			}
 
-			return cli.IssueToken(cmd.Context(), clientName)
+			return cli.IssueToken(cmd.Context(), clientName, core.TokenOptions{
+				NoCache:      arg.noCache,
+				Refresh:      refresh,
+				Grant:        grant,
+				PromptUser:   promptUser,
+				Scopes:       requested,
+				AddScopes:    splitScopes(addScopes),
+				RemoveScopes: splitScopes(removeScopes),
+				Audience:     audience,
+				Resource:     resource,
+				ExtraParams:  extraParams,
+				ExtraHeaders: extraHeaders,
+			})
 		},
 	}
 
 	cmd.Flags().StringVarP(&clientName, "client", "c", "", "Client name")
+	cmd.Flags().BoolVar(&refresh, "refresh", false, "Ignore the cached token and request a new one")
+	cmd.Flags().BoolVar(&promptUser, "prompt-user", false, "Ask for the username and password of the password grant instead of using the stored ones")
+	cmd.Flags().StringVar(&grantName, "grant", "", "Grant to use instead of the client's default: client_credentials, authorization_code, device_code, jwt_bearer or password (legacy)")
+	cmd.Flags().BoolVar(&decode, "decode", false, "Show the decoded header and claims of JWT access tokens, the signature is not verified")
+	cmd.Flags().StringArrayVar(&scopes, "scope", nil, "Scope to request instead of the client's stored ones, can be repeated")
+	cmd.Flags().StringArrayVar(&addScopes, "add-scope", nil, "Scope to request in addition to the client's stored ones, can be repeated")
+	cmd.Flags().StringArrayVar(&removeScopes, "remove-scope", nil, "Stored scope not to request, can be repeated")
+	cmd.Flags().StringVar(&audience, "audience", "", "Audience to request instead of the client's stored one")
+	cmd.Flags().StringArrayVar(&resource, "resource", nil, "Resource indicator (RFC 8707) to request instead of the client's stored ones, can be repeated")
+	cmd.Flags().StringArrayVar(&params, "param", nil, "Extra form parameter key=value, overrides a stored one with the same key, can be repeated")
+	cmd.Flags().StringArrayVarP(&headers, "header", "H", nil, "Extra HTTP header \"Name: value\", overrides a stored one with the same name, can be repeated")
+
+	return cmd
+}
+
+// splitScopes splits space-separated scopes, so both --scope "read write" and repeated flags work
+func splitScopes(values []string) []string {
+	var scopes []string
+	for _, value := range values {
+		scopes = append(scopes, strings.Fields(value)...)
+	}
+
+	return scopes
+}
+
+// ExchangeCommand creates a new cobra.Command to exchange a token with an OIDC client (RFC 8693).
+// It returns a pointer to a cobra.Command which can be executed to exchange a token.
+func ExchangeCommand(arg *args) *cobra.Command {
+	var clientName string
+	var in ui.ExchangeInput
+
+	cmd := &cobra.Command{
+		Use:   "exchange [client-name]",
+		Short: "Exchange a token for another one (RFC 8693)",
+		Long:  `Exchange a subject token for a new token using OAuth 2.0 Token Exchange, e.g. to impersonate a user or delegate to another service. The subject token is given literally, read from a file ("-" for stdin) or issued for another stored client. An actor token turns impersonation into delegation. Token types accept short names (access_token, refresh_token, id_token, jwt, saml1, saml2) or full URIs. Exchanged tokens are never cached.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if in.SubjectTokenFile == "-" && in.ActorTokenFile == "-" {
+				return ui.UsageError(fmt.Errorf("only one token can be read from stdin"))
+			}
+
+			cli, err := initCLI(arg)
+			if err != nil {
+				return err
+			}
+
+			if len(args) > 0 && clientName == "" {
+				clientName = args[0]
+			}
+
+			in.NoCache = arg.noCache
+
+			return cli.ExchangeToken(cmd.Context(), clientName, in)
+		},
+	}
+
+	cmd.Flags().StringVarP(&clientName, "client", "c", "", "Client performing the exchange")
+	cmd.Flags().StringVar(&in.SubjectToken, "subject-token", "", "Subject token")
+	cmd.Flags().StringVar(&in.SubjectTokenFile, "subject-token-file", "", "Read the subject token from a file, - for stdin")
+	cmd.Flags().StringVar(&in.SubjectClient, "subject-client", "", "Use an access token issued for another stored client as subject token")
+	cmd.Flags().StringVar(&in.SubjectTokenType, "subject-token-type", "", "Subject token type (default access_token)")
+	cmd.Flags().StringVar(&in.ActorToken, "actor-token", "", "Actor token for delegation")
+	cmd.Flags().StringVar(&in.ActorTokenFile, "actor-token-file", "", "Read the actor token from a file, - for stdin")
+	cmd.Flags().StringVar(&in.ActorClient, "actor-client", "", "Use an access token issued for another stored client as actor token")
+	cmd.Flags().StringVar(&in.ActorTokenType, "actor-token-type", "", "Actor token type (default access_token)")
+	cmd.Flags().StringArrayVar(&in.Audience, "audience", nil, "Logical name of the target service, can be repeated")
+	cmd.Flags().StringArrayVar(&in.Resource, "resource", nil, "URI of the target resource, can be repeated")
+	cmd.Flags().StringVar(&in.RequestedTokenType, "requested-token-type", "", "Type of the token to issue")
+
+	cmd.MarkFlagsOneRequired("subject-token", "subject-token-file", "subject-client")
+	cmd.MarkFlagsMutuallyExclusive("subject-token", "subject-token-file", "subject-client")
+	cmd.MarkFlagsMutuallyExclusive("actor-token", "actor-token-file", "actor-client")
 
 	return cmd
 }
@@ -114,13 +367,182 @@ func ListCommand(arg *args) *cobra.Command {
 		Short: "List all OIDC clients",
 		Long:  `Display all OIDC clients stored in the vault.`,
 		RunE: func(cmd *cobra.Command, _ []string) error {
-			cli := initCLI(arg)
+			cli, err := initCLI(arg)
+			if err != nil {
+				return err
+			}
 
 			return cli.ListClients(cmd.Context())
 		},
 	}
 }
 
+// EditCommand creates a new cobra.Command to modify an existing OIDC client.
+// It returns a pointer to a cobra.Command which can be executed to edit a client.
+func EditCommand(arg *args) *cobra.Command {
+	var clientName, clientID, clientSecret, tokenURL, issuer, scopes, authMethod string
+	var privateKeyFile, keyID, signingAlg, tlsCertFile, tlsKeyFile string
+	var grant, authorizationURL, redirectURL, deviceAuthorizationURL string
+	var assertionIssuer, assertionSubject, assertionAudience string
+	var username, userPassword string
+	var httpTimeout time.Duration
+	var httpProxy, httpCAFile, httpTLSMinVersion string
+	var httpRetries int
+	var pinnedPubkey string
+	var insecureSkipVerify, yes bool
+	var audience string
+	var resource, params, headers []string
+
+	cmd := &cobra.Command{
+		Use:   "edit [client-name]",
+		Short: "Edit an existing OIDC client",
+		Long:  `Edit an OIDC client stored in the vault. Without field flags you will be prompted for the client ID and secret, endpoints, issuer, scopes, auth method, grant and audience with the current value pre-filled, and for the secret, key or certificate a new auth method needs; with field flags only the given fields are changed, without prompting. Resource indicators, extra parameters and headers, the key ID, signing algorithm, assertion issuer and audience, and the client's HTTP settings can only be changed with flags.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cli, err := initCLI(arg)
+			if err != nil {
+				return err
+			}
+
+			if len(args) > 0 && clientName == "" {
+				clientName = args[0]
+			}
+
+			var changes ui.ClientChanges
+			if cmd.Flags().Changed("client-id") {
+				changes.ClientID = &clientID
+			}
+			if cmd.Flags().Changed("client-secret") {
+				changes.ClientSecret = &clientSecret
+			}
+			if cmd.Flags().Changed("token-url") {
+				changes.TokenURL = &tokenURL
+			}
+			if cmd.Flags().Changed("issuer") {
+				changes.Issuer = &issuer
+			}
+			if cmd.Flags().Changed("scopes") {
+				changes.Scopes = &scopes
+			}
+			if cmd.Flags().Changed("auth-method") {
+				changes.AuthMethod = &authMethod
+			}
+			if cmd.Flags().Changed("private-key") {
+				changes.PrivateKeyFile = &privateKeyFile
+			}
+			if cmd.Flags().Changed("key-id") {
+				changes.KeyID = &keyID
+			}
+			if cmd.Flags().Changed("signing-alg") {
+				changes.SigningAlgorithm = &signingAlg
+			}
+			if cmd.Flags().Changed("tls-cert") {
+				changes.TLSCertFile = &tlsCertFile
+			}
+			if cmd.Flags().Changed("tls-key") {
+				changes.TLSKeyFile = &tlsKeyFile
+			}
+			if cmd.Flags().Changed("grant") {
+				changes.GrantType = &grant
+			}
+			if cmd.Flags().Changed("authorization-url") {
+				changes.AuthorizationURL = &authorizationURL
+			}
+			if cmd.Flags().Changed("redirect-url") {
+				changes.RedirectURL = &redirectURL
+			}
+			if cmd.Flags().Changed("device-authorization-url") {
+				changes.DeviceAuthorizationURL = &deviceAuthorizationURL
+			}
+			if cmd.Flags().Changed("assertion-issuer") {
+				changes.AssertionIssuer = &assertionIssuer
+			}
+			if cmd.Flags().Changed("assertion-subject") {
+				changes.AssertionSubject = &assertionSubject
+			}
+			if cmd.Flags().Changed("assertion-audience") {
+				changes.AssertionAudience = &assertionAudience
+			}
+			if cmd.Flags().Changed("username") {
+				changes.Username = &username
+			}
+			if cmd.Flags().Changed("user-password") {
+				changes.Password = &userPassword
+			}
+			if cmd.Flags().Changed("audience") {
+				changes.Audience = &audience
+			}
+			if cmd.Flags().Changed("resource") {
+				changes.Resource = &resource
+			}
+			if cmd.Flags().Changed("param") {
+				changes.Params = &params
+			}
+			if cmd.Flags().Changed("header") {
+				changes.Headers = &headers
+			}
+			if cmd.Flags().Changed("http-timeout") {
+				changes.Timeout = &httpTimeout
+			}
+			if cmd.Flags().Changed("http-proxy") {
+				changes.ProxyURL = &httpProxy
+			}
+			if cmd.Flags().Changed("http-ca-file") {
+				changes.CAFile = &httpCAFile
+			}
+			if cmd.Flags().Changed("http-tls-min-version") {
+				changes.TLSMinVersion = &httpTLSMinVersion
+			}
+			if cmd.Flags().Changed("http-retries") {
+				changes.Retries = &httpRetries
+			}
+			if cmd.Flags().Changed("pinned-pubkey") {
+				changes.PinnedPublicKey = &pinnedPubkey
+			}
+			if cmd.Flags().Changed("insecure-skip-verify") {
+				changes.InsecureSkipVerify = &insecureSkipVerify
+			}
+
+			return cli.EditClient(cmd.Context(), clientName, changes, yes)
+		},
+	}
+
+	cmd.Flags().StringVarP(&clientName, "client", "c", "", "Client name")
+	cmd.Flags().StringVar(&clientID, "client-id", "", "New client ID")
+	cmd.Flags().StringVarP(&clientSecret, "client-secret", "s", "", "New client secret")
+	cmd.Flags().StringVarP(&tokenURL, "token-url", "t", "", "New token URL")
+	cmd.Flags().StringVar(&issuer, "issuer", "", "New issuer URL whose metadata keeps the token URL up to date, empty to clear")
+	cmd.Flags().StringVar(&scopes, "scopes", "", "New scopes (space-separated, empty to clear)")
+	cmd.Flags().StringVar(&authMethod, "auth-method", "", "New token endpoint auth method: client_secret_post, client_secret_basic, client_secret_jwt, private_key_jwt, tls_client_auth, self_signed_tls_client_auth or none")
+	cmd.Flags().StringVar(&privateKeyFile, "private-key", "", "PEM file with the new private key for private_key_jwt or jwt_bearer")
+	cmd.Flags().StringVar(&keyID, "key-id", "", "New key ID for client assertions")
+	cmd.Flags().StringVar(&signingAlg, "signing-alg", "", "New client assertion signing algorithm")
+	cmd.Flags().StringVar(&tlsCertFile, "tls-cert", "", "PEM file with the new mutual TLS client certificate")
+	cmd.Flags().StringVar(&tlsKeyFile, "tls-key", "", "PEM file with the new client certificate key")
+	cmd.Flags().StringVar(&grant, "grant", "", "New default grant: client_credentials, authorization_code, device_code, jwt_bearer or password (legacy)")
+	cmd.Flags().StringVar(&authorizationURL, "authorization-url", "", "New authorization endpoint for the authorization_code grant")
+	cmd.Flags().StringVar(&redirectURL, "redirect-url", "", "New loopback redirect URL, empty to use any free port")
+	cmd.Flags().StringVar(&deviceAuthorizationURL, "device-authorization-url", "", "New device authorization endpoint for the device_code grant")
+	cmd.Flags().StringVar(&assertionIssuer, "assertion-issuer", "", "New iss claim of jwt_bearer assertions, empty for the client ID")
+	cmd.Flags().StringVar(&assertionSubject, "assertion-subject", "", "New sub claim of jwt_bearer assertions, empty for none")
+	cmd.Flags().StringVar(&assertionAudience, "assertion-audience", "", "New aud claim of jwt_bearer assertions, empty for the token URL")
+	cmd.Flags().StringVar(&username, "username", "", "New username stored for the password grant, empty together with --user-password to ask on every request")
+	cmd.Flags().StringVar(&userPassword, "user-password", "", "New password stored for the password grant")
+	cmd.Flags().StringVar(&audience, "audience", "", "New audience sent with token requests, empty to clear")
+	cmd.Flags().StringArrayVar(&resource, "resource", nil, "New resource indicators, replace the stored ones, can be repeated, empty to clear")
+	cmd.Flags().StringArrayVar(&params, "param", nil, "New extra form parameters key=value, replace the stored ones, can be repeated, empty to clear")
+	cmd.Flags().StringArrayVarP(&headers, "header", "H", nil, "New extra HTTP headers \"Name: value\", replace the stored ones, can be repeated, empty to clear")
+	cmd.Flags().DurationVar(&httpTimeout, "http-timeout", 0, "New request timeout for this client, 0 for the global --timeout")
+	cmd.Flags().StringVar(&httpProxy, "http-proxy", "", "New proxy URL for this client, empty for the global --proxy")
+	cmd.Flags().StringVar(&httpCAFile, "http-ca-file", "", "PEM file with the new CA certificates trusted for this client, empty to remove them")
+	cmd.Flags().StringVar(&httpTLSMinVersion, "http-tls-min-version", "", "New minimum TLS version for this client, empty for the global --tls-min-version")
+	cmd.Flags().IntVar(&httpRetries, "http-retries", 0, "New retries for this client, -1 for the global --retries")
+	cmd.Flags().StringVar(&pinnedPubkey, "pinned-pubkey", "", "New pinned server public key (base64 SHA-256 of the SPKI), empty to verify the certificate again")
+	cmd.Flags().BoolVar(&insecureSkipVerify, "insecure-skip-verify", false, "Don't verify the server certificate, only allowed for loopback and private addresses (INSECURE), =false to verify again")
+	cmd.Flags().BoolVarP(&yes, "yes", "y", false, "Save the changes without asking for confirmation")
+
+	return cmd
+}
+
 // DeleteCommand creates a new cobra.Command to delete an OIDC client.
 // It returns a pointer to a cobra.Command which can be executed to delete a client.
 func DeleteCommand(arg *args) *cobra.Command {
@@ -132,7 +554,10 @@ func DeleteCommand(arg *args) *cobra.Command {
 		Short: "Delete an OIDC client",
 		Long:  `Delete an OIDC client from the vault. If client name is not provided, you will be prompted to select from available clients.`,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			cli := initCLI(arg)
+			cli, err := initCLI(arg)
+			if err != nil {
+				return err
+			}
 
 			if len(args) > 0 && clientName == "" {
 				clientName = args[0]
@@ -147,3 +572,139 @@ func DeleteCommand(arg *args) *cobra.Command {
 
 	return cmd
 }
+
+// PasswdCommand creates a new cobra.Command to change the vault master password.
+// It returns a pointer to a cobra.Command which can be executed to re-encrypt the vault.
+func PasswdCommand(arg *args) *cobra.Command {
+	return &cobra.Command{
+		Use:   "passwd",
+		Short: "Change the vault master password",
+		Long:  `Change the master password of the vault. The current password is verified first and the vault is re-encrypted atomically with the new one.`,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			cli, err := initCLI(arg)
+			if err != nil {
+				return err
+			}
+
+			return cli.ChangePassword(cmd.Context())
+		},
+	}
+}
+
+// BackupCommand creates a new cobra.Command to manage automatic vault backups.
+// It returns a pointer to a cobra.Command grouping the backup subcommands.
+func BackupCommand(arg *args) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "backup",
+		Short: "Manage vault backups",
+		Long:  `Manage the encrypted vault backups that are created automatically every time the vault is modified.`,
+	}
+
+	cmd.AddCommand(BackupListCommand(arg))
+	cmd.AddCommand(BackupRestoreCommand(arg))
+
+	return cmd
+}
+
+// BackupListCommand creates a new cobra.Command to list vault backups.
+// It returns a pointer to a cobra.Command which can be executed to list backups.
+func BackupListCommand(arg *args) *cobra.Command {
+	return &cobra.Command{
+		Use:   "list",
+		Short: "List vault backups",
+		Long:  `Display the kept vault backups, newest first, with the time each generation was saved as the vault and its client count.`,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			cli, err := initCLI(arg)
+			if err != nil {
+				return err
+			}
+
+			return cli.ListBackups(cmd.Context())
+		},
+	}
+}
+
+// BackupRestoreCommand creates a new cobra.Command to restore a vault backup.
+// It returns a pointer to a cobra.Command which can be executed to restore a backup.
+func BackupRestoreCommand(arg *args) *cobra.Command {
+	var force bool
+
+	cmd := &cobra.Command{
+		Use:   "restore <n>",
+		Short: "Restore a vault backup",
+		Long:  `Replace the vault with backup number n as shown by 'backup list'. The current vault is kept as the newest backup.`,
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			index, err := strconv.Atoi(args[0])
+			if err != nil {
+				return fmt.Errorf("invalid backup number %q", args[0])
+			}
+
+			cli, err := initCLI(arg)
+			if err != nil {
+				return err
+			}
+
+			return cli.RestoreBackup(cmd.Context(), index, force)
+		},
+	}
+
+	cmd.Flags().BoolVarP(&force, "force", "f", false, "Skip confirmation prompt")
+
+	return cmd
+}
+
+// JWTCommand creates a new cobra.Command to inspect JSON Web Tokens.
+// It returns a pointer to a cobra.Command grouping the jwt subcommands.
+func JWTCommand(arg *args) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "jwt",
+		Short: "Inspect JSON Web Tokens",
+		Long:  `Inspect JSON Web Tokens offline, without pasting them into websites.`,
+	}
+
+	cmd.AddCommand(JWTDecodeCommand(arg))
+
+	return cmd
+}
+
+// JWTDecodeCommand creates a new cobra.Command to decode a JWT.
+// It returns a pointer to a cobra.Command which can be executed to decode a token.
+func JWTDecodeCommand(arg *args) *cobra.Command {
+	var file string
+
+	cmd := &cobra.Command{
+		Use:   "decode [token]",
+		Short: "Decode a JWT",
+		Long:  `Pretty-print the header and claims of a JWT with its issue, not-before and expiry times, and show whether it is expired or not yet valid. The token is given as argument, read from a file or from stdin when neither is given. Decoding happens offline and the vault is not opened. The signature is not verified, so the claims must not be trusted.`,
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(_ *cobra.Command, args []string) error {
+			var token string
+			if len(args) > 0 {
+				if file != "" {
+					return ui.UsageError(fmt.Errorf("give the token either as argument or with --file"))
+				}
+
+				token = args[0]
+			} else if file == "" {
+				file = "-"
+			}
+
+			// Reading stdin on a terminal would wait for input without any prompt
+			if file == "-" && stdinIsTerminal() {
+				return ui.UsageError(fmt.Errorf("a token argument or piped input is required"))
+			}
+
+			cli, err := initCLI(arg)
+			if err != nil {
+				return err
+			}
+
+			return cli.DecodeJWT(token, file)
+		},
+	}
+
+	cmd.Flags().StringVarP(&file, "file", "f", "", "Read the token from a file, - for stdin")
+
+	return cmd
+}
@@ -1,9 +1,16 @@
 package cmd
 
 import (
+	"io"
+	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
+	"github.com/ksysoev/authkeeper/pkg/core"
+	"github.com/ksysoev/authkeeper/pkg/ui"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestInitCommands(t *testing.T) {
@@ -19,7 +26,7 @@ func TestInitCommands(t *testing.T) {
 	assert.NotEmpty(t, rootCmd.Long)
 
 	subCommands := rootCmd.Commands()
-	assert.Len(t, subCommands, 4)
+	assert.Len(t, subCommands, 9)
 
 	commandNames := make(map[string]bool)
 	for _, cmd := range subCommands {
@@ -30,8 +37,50 @@ func TestInitCommands(t *testing.T) {
 
 	assert.True(t, commandNames["add"])
 	assert.True(t, commandNames["token"])
+	assert.True(t, commandNames["exchange"])
 	assert.True(t, commandNames["list"])
+	assert.True(t, commandNames["edit"])
 	assert.True(t, commandNames["delete"])
+	assert.True(t, commandNames["passwd"])
+	assert.True(t, commandNames["backup"])
+	assert.True(t, commandNames["jwt"])
+
+	assert.NotNil(t, rootCmd.PersistentFlags().Lookup("no-cache"))
+	assert.NotNil(t, rootCmd.PersistentFlags().Lookup("refresh-skew"))
+
+	for _, flag := range []string{"timeout", "proxy", "ca-file", "tls-min-version", "retries"} {
+		assert.NotNil(t, rootCmd.PersistentFlags().Lookup(flag), flag)
+	}
+}
+
+func TestHTTPSettings(t *testing.T) {
+	settings, err := httpSettings(&args{timeout: 10 * time.Second, proxyURL: "http://proxy.example.com:3128", retries: 3})
+	require.NoError(t, err)
+
+	assert.Equal(t, 10*time.Second, settings.Timeout)
+	assert.Equal(t, "http://proxy.example.com:3128", settings.ProxyURL)
+	require.NotNil(t, settings.Retries)
+	assert.Equal(t, 3, *settings.Retries)
+
+	_, err = httpSettings(&args{proxyURL: "ftp://proxy.example.com"})
+	assert.ErrorContains(t, err, "scheme must be http, https or socks5")
+	assert.Equal(t, ui.ExitUsage, ui.ExitCode(err))
+
+	_, err = httpSettings(&args{caFile: filepath.Join(t.TempDir(), "missing.pem")})
+	assert.ErrorContains(t, err, "failed to read CA file")
+}
+
+func TestInitCommands_UsageError(t *testing.T) {
+	rootCmd, err := InitCommands("1.0.0")
+	require.NoError(t, err)
+
+	rootCmd.SetArgs([]string{"token", "--bogus"})
+	rootCmd.SetOut(io.Discard)
+	rootCmd.SetErr(io.Discard)
+
+	err = rootCmd.Execute()
+	assert.ErrorContains(t, err, "unknown flag: --bogus")
+	assert.Equal(t, ui.ExitUsage, ui.ExitCode(err))
 }
 
 func TestAddCommand(t *testing.T) {
@@ -47,6 +96,12 @@ func TestAddCommand(t *testing.T) {
 	assert.NotEmpty(t, cmd.Short)
 	assert.NotEmpty(t, cmd.Long)
 	assert.NotNil(t, cmd.RunE)
+
+	for _, flag := range []string{"yes", "service-account", "assertion-issuer", "assertion-subject", "assertion-audience", "username", "user-password",
+		"http-timeout", "http-proxy", "http-ca-file", "http-tls-min-version", "http-retries", "pinned-pubkey", "insecure-skip-verify",
+		"audience", "resource", "param", "header"} {
+		assert.NotNil(t, cmd.Flags().Lookup(flag), flag)
+	}
 }
 
 func TestTokenCommand(t *testing.T) {
@@ -62,6 +117,107 @@ func TestTokenCommand(t *testing.T) {
 	assert.NotEmpty(t, cmd.Short)
 	assert.NotEmpty(t, cmd.Long)
 	assert.NotNil(t, cmd.RunE)
+	assert.NotNil(t, cmd.Flags().Lookup("refresh"))
+	assert.NotNil(t, cmd.Flags().Lookup("grant"))
+	assert.NotNil(t, cmd.Flags().Lookup("prompt-user"))
+
+	for _, flag := range []string{"decode", "scope", "add-scope", "remove-scope", "audience", "resource", "param", "header"} {
+		assert.NotNil(t, cmd.Flags().Lookup(flag), flag)
+	}
+}
+
+func TestSplitScopes(t *testing.T) {
+	assert.Nil(t, splitScopes(nil))
+	assert.Nil(t, splitScopes([]string{""}))
+	assert.Equal(t, []string{"read", "write", "admin"}, splitScopes([]string{"read write", "admin"}))
+}
+
+func TestTokenCommand_InvalidParam(t *testing.T) {
+	cmd := TokenCommand(&args{vaultPath: "/tmp/vault.enc"})
+	cmd.SetArgs([]string{"my-client", "--param", "tenant"})
+	cmd.SilenceUsage = true
+	cmd.SilenceErrors = true
+
+	err := cmd.Execute()
+	assert.ErrorContains(t, err, `invalid parameter "tenant", expected key=value`)
+}
+
+func TestTokenCommand_DecodeOutputFormat(t *testing.T) {
+	cmd := TokenCommand(&args{vaultPath: "/tmp/vault.enc", output: "raw"})
+	cmd.SetArgs([]string{"my-client", "--decode"})
+	cmd.SilenceUsage = true
+	cmd.SilenceErrors = true
+
+	err := cmd.Execute()
+	assert.ErrorContains(t, err, "--decode requires the text, json or yaml output format")
+	assert.Equal(t, ui.ExitUsage, ui.ExitCode(err))
+
+	cmd = TokenCommand(&args{vaultPath: "/tmp/vault.enc", output: "xml"})
+	cmd.SetArgs([]string{"my-client", "--decode"})
+	cmd.SilenceUsage = true
+	cmd.SilenceErrors = true
+
+	err = cmd.Execute()
+	assert.ErrorContains(t, err, `unknown output format "xml"`)
+}
+
+func TestTokenCommand_InvalidGrant(t *testing.T) {
+	cmd := TokenCommand(&args{vaultPath: "/tmp/vault.enc"})
+	cmd.SetArgs([]string{"my-client", "--grant", "implicit"})
+	cmd.SilenceUsage = true
+	cmd.SilenceErrors = true
+
+	err := cmd.Execute()
+	assert.ErrorContains(t, err, `unknown grant type "implicit"`)
+}
+
+func TestExchangeCommand(t *testing.T) {
+	cmd := ExchangeCommand(&args{vaultPath: "/tmp/vault.enc"})
+
+	assert.Equal(t, "exchange [client-name]", cmd.Use)
+	assert.NotEmpty(t, cmd.Short)
+	assert.NotEmpty(t, cmd.Long)
+	assert.NotNil(t, cmd.RunE)
+
+	for _, flag := range []string{"subject-token", "subject-token-file", "subject-client", "subject-token-type", "actor-token",
+		"actor-token-file", "actor-client", "actor-token-type", "audience", "resource", "requested-token-type"} {
+		assert.NotNil(t, cmd.Flags().Lookup(flag), flag)
+	}
+}
+
+func TestExchangeCommand_SubjectToken(t *testing.T) {
+	tests := []struct {
+		name        string
+		args        []string
+		expectedErr string
+	}{
+		{
+			name:        "missing subject token",
+			args:        []string{"my-client"},
+			expectedErr: "at least one of the flags in the group [subject-token subject-token-file subject-client] is required",
+		},
+		{
+			name:        "two subject tokens",
+			args:        []string{"my-client", "--subject-token", "abc", "--subject-client", "other"},
+			expectedErr: "if any flags in the group [subject-token subject-token-file subject-client] are set none of the others can be",
+		},
+		{
+			name:        "subject and actor token from stdin",
+			args:        []string{"my-client", "--subject-token-file", "-", "--actor-token-file", "-"},
+			expectedErr: "only one token can be read from stdin",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd := ExchangeCommand(&args{vaultPath: "/tmp/vault.enc"})
+			cmd.SetArgs(tt.args)
+			cmd.SilenceUsage = true
+			cmd.SilenceErrors = true
+
+			assert.ErrorContains(t, cmd.Execute(), tt.expectedErr)
+		})
+	}
 }
 
 func TestListCommand(t *testing.T) {
@@ -79,6 +235,28 @@ func TestListCommand(t *testing.T) {
 	assert.NotNil(t, cmd.RunE)
 }
 
+func TestEditCommand(t *testing.T) {
+	args := &args{
+		version:   "1.0.0",
+		vaultPath: "/tmp/vault.enc",
+	}
+
+	cmd := EditCommand(args)
+
+	assert.NotNil(t, cmd)
+	assert.Equal(t, "edit [client-name]", cmd.Use)
+	assert.NotEmpty(t, cmd.Short)
+	assert.NotEmpty(t, cmd.Long)
+	assert.NotNil(t, cmd.RunE)
+
+	for _, flag := range []string{"yes", "client", "client-id", "client-secret", "token-url", "scopes", "grant", "authorization-url", "redirect-url", "device-authorization-url",
+		"assertion-issuer", "assertion-subject", "assertion-audience", "username", "user-password",
+		"http-timeout", "http-proxy", "http-ca-file", "http-tls-min-version", "http-retries", "pinned-pubkey", "insecure-skip-verify",
+		"audience", "resource", "param", "header"} {
+		assert.NotNil(t, cmd.Flags().Lookup(flag), "flag %s should be defined", flag)
+	}
+}
+
 func TestDeleteCommand(t *testing.T) {
 	args := &args{
 		version:   "1.0.0",
@@ -93,3 +271,127 @@ func TestDeleteCommand(t *testing.T) {
 	assert.NotEmpty(t, cmd.Long)
 	assert.NotNil(t, cmd.RunE)
 }
+
+func TestPasswdCommand(t *testing.T) {
+	args := &args{
+		version:   "1.0.0",
+		vaultPath: "/tmp/vault.enc",
+	}
+
+	cmd := PasswdCommand(args)
+
+	assert.NotNil(t, cmd)
+	assert.Equal(t, "passwd", cmd.Use)
+	assert.NotEmpty(t, cmd.Short)
+	assert.NotEmpty(t, cmd.Long)
+	assert.NotNil(t, cmd.RunE)
+}
+
+func TestBackupCommand(t *testing.T) {
+	args := &args{
+		version:   "1.0.0",
+		vaultPath: "/tmp/vault.enc",
+	}
+
+	cmd := BackupCommand(args)
+
+	assert.NotNil(t, cmd)
+	assert.Equal(t, "backup", cmd.Use)
+	assert.NotEmpty(t, cmd.Short)
+	assert.NotEmpty(t, cmd.Long)
+
+	subCommands := make(map[string]bool)
+	for _, sub := range cmd.Commands() {
+		subCommands[sub.Name()] = true
+	}
+
+	assert.True(t, subCommands["list"])
+	assert.True(t, subCommands["restore"])
+}
+
+func TestBackupRestoreCommand_InvalidIndex(t *testing.T) {
+	args := &args{
+		version:   "1.0.0",
+		vaultPath: "/tmp/vault.enc",
+	}
+
+	cmd := BackupRestoreCommand(args)
+
+	assert.Equal(t, "restore <n>", cmd.Use)
+
+	err := cmd.RunE(cmd, []string{"latest"})
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid backup number")
+}
+
+func TestJWTCommand(t *testing.T) {
+	cmd := JWTCommand(&args{vaultPath: "/tmp/vault.enc"})
+
+	assert.Equal(t, "jwt", cmd.Use)
+	assert.NotEmpty(t, cmd.Short)
+	assert.NotEmpty(t, cmd.Long)
+	require.Len(t, cmd.Commands(), 1)
+	assert.Equal(t, "decode", cmd.Commands()[0].Name())
+}
+
+func TestJWTDecodeCommand(t *testing.T) {
+	tokenPath := filepath.Join(t.TempDir(), "token")
+	require.NoError(t, os.WriteFile(tokenPath, []byte("opaque-token\n"), 0600))
+
+	tests := []struct {
+		name    string
+		args    []string
+		wantErr error
+		wantMsg string
+	}{
+		{name: "opaque token", args: []string{"opaque-token"}, wantErr: core.ErrNotJWT},
+		{name: "opaque token from file", args: []string{"--file", tokenPath}, wantErr: core.ErrNotJWT},
+		{name: "argument and file", args: []string{"opaque-token", "--file", tokenPath}, wantMsg: "either as argument or with --file"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd := JWTDecodeCommand(&args{vaultPath: "/tmp/vault.enc", output: "text", passwordFD: -1})
+			cmd.SetArgs(tt.args)
+			cmd.SilenceUsage = true
+			cmd.SilenceErrors = true
+
+			err := cmd.Execute()
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+			} else {
+				assert.ErrorContains(t, err, tt.wantMsg)
+			}
+		})
+	}
+
+	t.Run("stdin is a terminal", func(t *testing.T) {
+		prev := stdinIsTerminal
+		stdinIsTerminal = func() bool { return true }
+		t.Cleanup(func() { stdinIsTerminal = prev })
+
+		cmd := JWTDecodeCommand(&args{vaultPath: "/tmp/vault.enc", output: "text", passwordFD: -1})
+		cmd.SetArgs(nil)
+		cmd.SilenceUsage = true
+		cmd.SilenceErrors = true
+
+		err := cmd.Execute()
+		assert.ErrorContains(t, err, "a token argument or piped input is required")
+		assert.Equal(t, ui.ExitUsage, ui.ExitCode(err))
+	})
+}
+
+func TestInitCLI_InvalidOutputFormat(t *testing.T) {
+	args := &args{
+		version:    "1.0.0",
+		vaultPath:  "/tmp/vault.enc",
+		passwordFD: -1,
+		output:     "xml",
+	}
+
+	cli, err := initCLI(args)
+
+	assert.Error(t, err)
+	assert.Nil(t, cli)
+	assert.Contains(t, err.Error(), "unknown output format")
+}
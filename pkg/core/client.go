@@ -1,15 +1,140 @@
 package core
 
-import "time"
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// AuthMethod is the client authentication method used at the token endpoint
+type AuthMethod string
+
+const (
+	// AuthMethodClientSecretPost sends the client credentials in the request body
+	AuthMethodClientSecretPost AuthMethod = "client_secret_post"
+	// AuthMethodClientSecretBasic sends the client credentials in an HTTP Basic Authorization header
+	AuthMethodClientSecretBasic AuthMethod = "client_secret_basic"
+	// AuthMethodClientSecretJWT sends a client assertion signed with HMAC using the client secret
+	AuthMethodClientSecretJWT AuthMethod = "client_secret_jwt"
+	// AuthMethodPrivateKeyJWT sends a client assertion signed with the client's private key (RFC 7523)
+	AuthMethodPrivateKeyJWT AuthMethod = "private_key_jwt"
+	// AuthMethodTLSClientAuth authenticates with a PKI-issued client certificate (RFC 8705)
+	AuthMethodTLSClientAuth AuthMethod = "tls_client_auth"
+	// AuthMethodSelfSignedTLSClientAuth authenticates with a self-signed client certificate (RFC 8705)
+	AuthMethodSelfSignedTLSClientAuth AuthMethod = "self_signed_tls_client_auth"
+	// AuthMethodNone sends only the client ID, for public clients
+	AuthMethodNone AuthMethod = "none"
+)
+
+// AuthMethods lists all supported token endpoint auth methods
+var AuthMethods = []AuthMethod{
+	AuthMethodClientSecretPost,
+	AuthMethodClientSecretBasic,
+	AuthMethodClientSecretJWT,
+	AuthMethodPrivateKeyJWT,
+	AuthMethodTLSClientAuth,
+	AuthMethodSelfSignedTLSClientAuth,
+	AuthMethodNone,
+}
+
+// ParseAuthMethod validates an auth method name, an empty name selects client_secret_post
+func ParseAuthMethod(name string) (AuthMethod, error) {
+	if name == "" {
+		return AuthMethodClientSecretPost, nil
+	}
+
+	names := make([]string, len(AuthMethods))
+	for i, method := range AuthMethods {
+		if string(method) == name {
+			return method, nil
+		}
+
+		names[i] = string(method)
+	}
+
+	return "", fmt.Errorf("unknown token endpoint auth method %q, expected one of: %s", name, strings.Join(names, ", "))
+}
 
 // Client represents an OIDC/OAuth2 client configuration
 type Client struct {
-	Name         string
-	ClientID     string
-	ClientSecret string
-	TokenURL     string
-	Scopes       []string
-	CreatedAt    time.Time
+	Name                    string
+	ClientID                string
+	ClientSecret            string
+	TokenURL                string
+	Issuer                  string
+	Scopes                  []string
+	TokenEndpointAuthMethod AuthMethod
+	// PrivateKey is the PEM encoded key signing private_key_jwt client assertions
+	PrivateKey string
+	// KeyID is sent as the kid header of client assertions, optional
+	KeyID string
+	// SigningAlgorithm is the JWS algorithm of client assertions, derived from the key
+	// or HS256 for client_secret_jwt when empty
+	SigningAlgorithm string
+	// TLSCertificate and TLSKey are the PEM encoded client certificate and key presented to the token endpoint
+	TLSCertificate string
+	TLSKey         string
+	// GrantType is the grant used when a token is issued without choosing one, client_credentials when empty
+	GrantType GrantType
+	// AuthorizationURL is the authorization endpoint the user signs in at for the authorization code grant
+	AuthorizationURL string
+	// RedirectURL is the loopback redirect registered for the client, DefaultRedirectURL when empty
+	RedirectURL string
+	// DeviceAuthorizationURL is the endpoint issuing device codes for the device code grant
+	DeviceAuthorizationURL string
+	// EndpointsResolvedAt is when the endpoints were last resolved from the issuer's metadata, zero if never
+	EndpointsResolvedAt time.Time
+	// AssertionIssuer, AssertionSubject and AssertionAudience are the iss, sub and aud claims of jwt_bearer
+	// grant assertions. They default to the client ID, no subject and the token URL.
+	AssertionIssuer   string
+	AssertionSubject  string
+	AssertionAudience string
+	// Username and Password are the resource owner credentials of the password grant, optional because
+	// they can be asked for when a token is issued instead
+	Username string
+	Password string
+	// Audience is sent as the audience parameter, which some servers such as Auth0 require to pick the API
+	Audience string
+	// Resource lists the resource indicators (RFC 8707) of the services the tokens are meant for
+	Resource []string
+	// ExtraParams and ExtraHeaders are added to every request to the authorization server, without
+	// replacing the parameters and headers authkeeper sets itself
+	ExtraParams  map[string]string
+	ExtraHeaders map[string]string
+	// HTTP overrides the global HTTP settings for requests of this client
+	HTTP      HTTPSettings
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// AuthMethod returns the client's token endpoint auth method, defaulting to client_secret_post
+// for clients stored before the method was configurable
+func (c Client) AuthMethod() AuthMethod {
+	if c.TokenEndpointAuthMethod == "" {
+		return AuthMethodClientSecretPost
+	}
+
+	return c.TokenEndpointAuthMethod
+}
+
+// Grant returns the client's default grant type, defaulting to client_credentials
+// for clients stored before the grant was configurable
+func (c Client) Grant() GrantType {
+	if c.GrantType == "" {
+		return GrantClientCredentials
+	}
+
+	return c.GrantType
+}
+
+// RequiresSecret reports whether the client's auth method uses the client secret
+func (c Client) RequiresSecret() bool {
+	switch c.AuthMethod() {
+	case AuthMethodNone, AuthMethodPrivateKeyJWT, AuthMethodTLSClientAuth, AuthMethodSelfSignedTLSClientAuth:
+		return false
+	default:
+		return true
+	}
 }
 
 // Token represents an OAuth2 access token response
@@ -18,4 +143,53 @@ type Token struct {
 	TokenType   string
 	ExpiresIn   int
 	Scope       string
+	IssuedAt    time.Time
+	// CertThumbprint is the cnf.x5t#S256 thumbprint of the certificate a token is bound to (RFC 8705)
+	CertThumbprint string
+	// RefreshToken is issued with user tokens, it is stored per client rather than in the token cache
+	RefreshToken string
+	// IssuedTokenType is the type of a token obtained by token exchange (RFC 8693 section 2.2.1)
+	IssuedTokenType string
+	// RequestedScopes are the scopes the token was requested with, they are set by the service and not cached
+	RequestedScopes []string
+}
+
+// TokenOptions controls how a token is obtained
+type TokenOptions struct {
+	// NoCache disables both reading from and writing to the token cache
+	NoCache bool
+	// Refresh ignores a cached token but stores the newly issued one
+	Refresh bool
+	// Grant overrides the client's default grant type when set
+	Grant GrantType
+	// Interactor involves the user in interactive grants
+	Interactor Interactor
+	// PromptUser asks the Interactor for the resource owner credentials of the password grant
+	// even when the client has stored ones
+	PromptUser bool
+	// Scopes replace the client's stored scopes for this request when not nil, AddScopes and RemoveScopes
+	// adjust the requested ones
+	Scopes       []string
+	AddScopes    []string
+	RemoveScopes []string
+	// Audience and Resource replace the client's stored values for this request
+	Audience string
+	Resource []string
+	// ExtraParams and ExtraHeaders are merged with the client's stored ones, replacing those with the same name
+	ExtraParams  map[string]string
+	ExtraHeaders map[string]string
+}
+
+// Backup describes a previous encrypted generation of the repository
+type Backup struct {
+	Index int
+	// SavedAt is when the kept generation was written as the vault, not when it became a backup
+	SavedAt  time.Time
+	Clients  int
+	Readable bool
+}
+
+// UsesMutualTLS reports whether the client authenticates with its TLS certificate
+func (c Client) UsesMutualTLS() bool {
+	return c.AuthMethod() == AuthMethodTLSClientAuth || c.AuthMethod() == AuthMethodSelfSignedTLSClientAuth
 }
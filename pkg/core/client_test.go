@@ -0,0 +1,63 @@
+package core
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseAuthMethod(t *testing.T) {
+	tests := []struct {
+		name        string
+		input       string
+		expected    AuthMethod
+		expectedErr string
+	}{
+		{name: "empty defaults to post", input: "", expected: AuthMethodClientSecretPost},
+		{name: "post", input: "client_secret_post", expected: AuthMethodClientSecretPost},
+		{name: "basic", input: "client_secret_basic", expected: AuthMethodClientSecretBasic},
+		{name: "client secret JWT", input: "client_secret_jwt", expected: AuthMethodClientSecretJWT},
+		{name: "private key JWT", input: "private_key_jwt", expected: AuthMethodPrivateKeyJWT},
+		{name: "none", input: "none", expected: AuthMethodNone},
+		{name: "unknown", input: "basic", expectedErr: "unknown token endpoint auth method"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			method, err := ParseAuthMethod(tt.input)
+
+			if tt.expectedErr != "" {
+				assert.ErrorContains(t, err, tt.expectedErr)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, method)
+		})
+	}
+}
+
+func TestClient_AuthMethod(t *testing.T) {
+	assert.Equal(t, AuthMethodClientSecretPost, Client{}.AuthMethod())
+	assert.Equal(t, AuthMethodNone, Client{TokenEndpointAuthMethod: AuthMethodNone}.AuthMethod())
+}
+
+func TestClient_RequiresSecret(t *testing.T) {
+	assert.True(t, Client{}.RequiresSecret())
+	assert.True(t, Client{TokenEndpointAuthMethod: AuthMethodClientSecretBasic}.RequiresSecret())
+	assert.True(t, Client{TokenEndpointAuthMethod: AuthMethodClientSecretJWT}.RequiresSecret())
+	assert.False(t, Client{TokenEndpointAuthMethod: AuthMethodPrivateKeyJWT}.RequiresSecret())
+	assert.False(t, Client{TokenEndpointAuthMethod: AuthMethodNone}.RequiresSecret())
+	assert.False(t, Client{TokenEndpointAuthMethod: AuthMethodTLSClientAuth}.RequiresSecret())
+}
+
+func TestClient_UsesMutualTLS(t *testing.T) {
+	assert.False(t, Client{}.UsesMutualTLS())
+	assert.True(t, Client{TokenEndpointAuthMethod: AuthMethodTLSClientAuth}.UsesMutualTLS())
+	assert.True(t, Client{TokenEndpointAuthMethod: AuthMethodSelfSignedTLSClientAuth}.UsesMutualTLS())
+}
+
+func TestClient_Grant(t *testing.T) {
+	assert.Equal(t, GrantClientCredentials, Client{}.Grant())
+	assert.Equal(t, GrantAuthorizationCode, Client{GrantType: GrantAuthorizationCode}.Grant())
+}
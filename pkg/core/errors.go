@@ -0,0 +1,69 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+var (
+	// ErrClientNotFound is returned when no client with the requested name is stored
+	ErrClientNotFound = errors.New("client not found")
+	// ErrWrongPassword is returned when the vault cannot be decrypted with the master password
+	ErrWrongPassword = errors.New("wrong master password")
+	// ErrVaultNotFound is returned when no vault exists at the configured path
+	ErrVaultNotFound = errors.New("vault not found")
+	// ErrVaultLocked is returned when another process holds the vault lock for longer than writers wait
+	ErrVaultLocked = errors.New("vault is locked")
+	// ErrInvalidGrant is returned by providers when the authorization server rejects a grant with invalid_grant,
+	// e.g. an expired or revoked refresh token
+	ErrInvalidGrant = errors.New("invalid_grant")
+)
+
+// OAuthError is an error response of an authorization server endpoint (RFC 6749 section 5.2).
+// Responses that are not OAuth errors, such as HTML error pages, only keep their HTTP status.
+type OAuthError struct {
+	StatusCode int
+	// Code is the error code, e.g. invalid_client, empty when the server sent none
+	Code        string
+	Description string
+	// URI points to a page describing the error
+	URI string
+}
+
+func (e *OAuthError) Error() string {
+	if e.Code == "" {
+		return fmt.Sprintf("server responded with HTTP %d %s", e.StatusCode, http.StatusText(e.StatusCode))
+	}
+
+	msg := e.Code
+	if e.Description != "" {
+		msg += ": " + e.Description
+	}
+
+	if e.URI != "" {
+		msg += " (" + e.URI + ")"
+	}
+
+	return msg
+}
+
+// Unwrap lets the service recognize a rejected grant with errors.Is(err, ErrInvalidGrant)
+func (e *OAuthError) Unwrap() error {
+	if e.Code == "invalid_grant" {
+		return ErrInvalidGrant
+	}
+
+	return nil
+}
+
+// isRejectedTarget reports whether the server refused the requested scopes (invalid_scope) or resources
+// (invalid_target, RFC 8707), as it does when a refresh token is redeemed for more than it was issued for
+func isRejectedTarget(err error) bool {
+	var oauthErr *OAuthError
+	if !errors.As(err, &oauthErr) {
+		return false
+	}
+
+	return oauthErr.Code == "invalid_scope" || oauthErr.Code == "invalid_target"
+}
@@ -0,0 +1,21 @@
+package core
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOAuthError(t *testing.T) {
+	err := &OAuthError{StatusCode: 400, Code: "invalid_scope", Description: "Unknown scope admin", URI: "https://idp.example.com/errors"}
+	assert.EqualError(t, err, "invalid_scope: Unknown scope admin (https://idp.example.com/errors)")
+	assert.NotErrorIs(t, err, ErrInvalidGrant)
+
+	err = &OAuthError{StatusCode: 503}
+	assert.EqualError(t, err, "server responded with HTTP 503 Service Unavailable")
+
+	wrapped := fmt.Errorf("failed to get token: %w", &OAuthError{StatusCode: 400, Code: "invalid_grant"})
+	assert.ErrorIs(t, wrapped, ErrInvalidGrant)
+	assert.ErrorAs(t, wrapped, new(*OAuthError))
+}
@@ -0,0 +1,54 @@
+package core
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Token type identifiers used by token exchange (RFC 8693 section 3)
+const (
+	TokenTypeAccessToken  = "urn:ietf:params:oauth:token-type:access_token"
+	TokenTypeRefreshToken = "urn:ietf:params:oauth:token-type:refresh_token"
+	TokenTypeIDToken      = "urn:ietf:params:oauth:token-type:id_token"
+	TokenTypeJWT          = "urn:ietf:params:oauth:token-type:jwt"
+	TokenTypeSAML1        = "urn:ietf:params:oauth:token-type:saml1"
+	TokenTypeSAML2        = "urn:ietf:params:oauth:token-type:saml2"
+)
+
+// tokenTypeNames maps the short names accepted on the command line to token type identifiers
+var tokenTypeNames = map[string]string{
+	"access_token":  TokenTypeAccessToken,
+	"refresh_token": TokenTypeRefreshToken,
+	"id_token":      TokenTypeIDToken,
+	"jwt":           TokenTypeJWT,
+	"saml1":         TokenTypeSAML1,
+	"saml2":         TokenTypeSAML2,
+}
+
+// ParseTokenType resolves a short token type name such as "jwt" to its identifier. Absolute URIs are passed
+// through for token types defined by the authorization server, an empty name stays empty.
+func ParseTokenType(name string) (string, error) {
+	if name == "" || strings.Contains(name, ":") {
+		return name, nil
+	}
+
+	if tokenType, ok := tokenTypeNames[name]; ok {
+		return tokenType, nil
+	}
+
+	return "", fmt.Errorf("unknown token type %q, expected a URI or one of: access_token, refresh_token, id_token, jwt, saml1, saml2", name)
+}
+
+// TokenExchange holds the parameters of a token exchange request (RFC 8693 section 2.1)
+type TokenExchange struct {
+	// SubjectToken represents the party on whose behalf the new token is requested
+	SubjectToken     string
+	SubjectTokenType string
+	// ActorToken represents the acting party for delegation, impersonation when empty
+	ActorToken     string
+	ActorTokenType string
+	// Audience and Resource name the services where the new token will be used
+	Audience           []string
+	Resource           []string
+	RequestedTokenType string
+}
@@ -0,0 +1,37 @@
+package core
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseTokenType(t *testing.T) {
+	tests := []struct {
+		name        string
+		input       string
+		expected    string
+		expectedErr string
+	}{
+		{name: "empty", input: "", expected: ""},
+		{name: "short name", input: "jwt", expected: TokenTypeJWT},
+		{name: "access token", input: "access_token", expected: TokenTypeAccessToken},
+		{name: "registered URI", input: TokenTypeIDToken, expected: TokenTypeIDToken},
+		{name: "server specific URI", input: "urn:example:token-type:session", expected: "urn:example:token-type:session"},
+		{name: "unknown", input: "bearer", expectedErr: `unknown token type "bearer"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tokenType, err := ParseTokenType(tt.input)
+
+			if tt.expectedErr != "" {
+				assert.ErrorContains(t, err, tt.expectedErr)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, tokenType)
+		})
+	}
+}
@@ -0,0 +1,138 @@
+package core
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+)
+
+// DefaultRedirectURL is the loopback redirect used by the authorization code grant when
+// the client has none configured, the port is chosen when the listener starts (RFC 8252)
+const DefaultRedirectURL = "http://127.0.0.1/callback"
+
+// GrantType is the OAuth2 grant used to obtain a token
+type GrantType string
+
+const (
+	// GrantClientCredentials obtains a token for the client itself
+	GrantClientCredentials GrantType = "client_credentials"
+	// GrantAuthorizationCode obtains a token for a user who signs in with a browser, protected with PKCE
+	GrantAuthorizationCode GrantType = "authorization_code"
+	// GrantDeviceCode obtains a token for a user who approves the request on another device (RFC 8628)
+	GrantDeviceCode GrantType = "device_code"
+	// GrantPassword obtains a token with the resource owner's username and password (RFC 6749 section 4.3).
+	// It is deprecated by the OAuth 2.0 Security BCP and only supported for legacy servers.
+	GrantPassword GrantType = "password"
+	// GrantJWTBearer obtains a token with an assertion signed by the client's private key, used by service accounts (RFC 7523)
+	GrantJWTBearer GrantType = "jwt_bearer"
+	// GrantRefreshToken renews a user token with a stored refresh token, it is never a client's default grant
+	GrantRefreshToken GrantType = "refresh_token"
+	// GrantTokenExchange trades a subject token for another token (RFC 8693), it is used by the exchange command only
+	GrantTokenExchange GrantType = "token_exchange"
+)
+
+// GrantTypes lists all supported grant types
+var GrantTypes = []GrantType{
+	GrantClientCredentials,
+	GrantAuthorizationCode,
+	GrantDeviceCode,
+	GrantJWTBearer,
+	GrantPassword,
+}
+
+// grantIdentifiers maps extension grants to their registered grant_type URI
+var grantIdentifiers = map[GrantType]string{
+	GrantDeviceCode:    "urn:ietf:params:oauth:grant-type:device_code",
+	GrantJWTBearer:     "urn:ietf:params:oauth:grant-type:jwt-bearer",
+	GrantTokenExchange: "urn:ietf:params:oauth:grant-type:token-exchange",
+}
+
+// Identifier returns the grant_type value sent to the token endpoint and listed in server metadata
+func (g GrantType) Identifier() string {
+	if id, ok := grantIdentifiers[g]; ok {
+		return id
+	}
+
+	return string(g)
+}
+
+// Interactive reports whether the grant involves the user, only these grants yield refresh tokens worth keeping
+func (g GrantType) Interactive() bool {
+	return g == GrantAuthorizationCode || g == GrantDeviceCode
+}
+
+// Legacy reports whether the grant is deprecated and should only be used with servers that support nothing better
+func (g GrantType) Legacy() bool {
+	return g == GrantPassword
+}
+
+// ParseGrantType validates a grant type name, an empty name selects client_credentials
+func ParseGrantType(name string) (GrantType, error) {
+	if name == "" {
+		return GrantClientCredentials, nil
+	}
+
+	names := make([]string, len(GrantTypes))
+	for i, grant := range GrantTypes {
+		if string(grant) == name {
+			return grant, nil
+		}
+
+		names[i] = string(grant)
+	}
+
+	return "", fmt.Errorf("unknown grant type %q, expected one of: %s", name, strings.Join(names, ", "))
+}
+
+// TokenRequest describes how the provider obtains a token for a client
+type TokenRequest struct {
+	Grant GrantType
+	// Interactor involves the user in interactive grants, such as signing in with a browser
+	Interactor Interactor
+	// RefreshToken is the refresh token redeemed by the refresh_token grant
+	RefreshToken string
+	// Exchange holds the parameters of the token exchange grant
+	Exchange TokenExchange
+	// Username and Password are the resource owner credentials of the password grant
+	Username string
+	Password string
+}
+
+// DeviceAuthorization tells the user where to approve a device code request (RFC 8628)
+type DeviceAuthorization struct {
+	UserCode        string
+	VerificationURI string
+	// VerificationURIComplete includes the user code, so it can be opened without typing the code
+	VerificationURIComplete string
+	ExpiresIn               int
+}
+
+// ParseRedirectURL validates a loopback redirect URL for the authorization code grant,
+// an empty URL selects DefaultRedirectURL
+func ParseRedirectURL(raw string) (*url.URL, error) {
+	if raw == "" {
+		raw = DefaultRedirectURL
+	}
+
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid redirect URL: %w", err)
+	}
+
+	if u.Scheme != "http" {
+		return nil, fmt.Errorf("invalid redirect URL %q: only http loopback redirects are supported", raw)
+	}
+
+	if host := u.Hostname(); host != "localhost" {
+		if ip := net.ParseIP(host); ip == nil || !ip.IsLoopback() {
+			return nil, fmt.Errorf("invalid redirect URL %q: host must be 127.0.0.1, [::1] or localhost", raw)
+		}
+	}
+
+	if u.Path == "" {
+		u.Path = "/"
+	}
+
+	return u, nil
+}
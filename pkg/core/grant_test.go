@@ -0,0 +1,77 @@
+package core
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseGrantType(t *testing.T) {
+	grant, err := ParseGrantType("")
+	require.NoError(t, err)
+	assert.Equal(t, GrantClientCredentials, grant)
+
+	for _, expected := range GrantTypes {
+		grant, err := ParseGrantType(string(expected))
+		require.NoError(t, err)
+		assert.Equal(t, expected, grant)
+	}
+
+	_, err = ParseGrantType("implicit")
+	assert.ErrorContains(t, err, `unknown grant type "implicit"`)
+}
+
+func TestGrantType_Identifier(t *testing.T) {
+	assert.Equal(t, "client_credentials", GrantClientCredentials.Identifier())
+	assert.Equal(t, "authorization_code", GrantAuthorizationCode.Identifier())
+	assert.Equal(t, "urn:ietf:params:oauth:grant-type:device_code", GrantDeviceCode.Identifier())
+	assert.Equal(t, "urn:ietf:params:oauth:grant-type:jwt-bearer", GrantJWTBearer.Identifier())
+}
+
+func TestGrantType_Interactive(t *testing.T) {
+	assert.False(t, GrantClientCredentials.Interactive())
+	assert.True(t, GrantAuthorizationCode.Interactive())
+	assert.True(t, GrantDeviceCode.Interactive())
+	assert.False(t, GrantRefreshToken.Interactive())
+
+	_, err := ParseGrantType("refresh_token")
+	assert.Error(t, err, "refresh_token is never a client's default grant")
+}
+
+func TestGrantType_Legacy(t *testing.T) {
+	assert.True(t, GrantPassword.Legacy())
+	assert.False(t, GrantClientCredentials.Legacy())
+	assert.False(t, GrantAuthorizationCode.Legacy())
+}
+
+func TestParseRedirectURL(t *testing.T) {
+	tests := []struct {
+		name        string
+		input       string
+		expected    string
+		expectedErr string
+	}{
+		{name: "default", input: "", expected: DefaultRedirectURL},
+		{name: "fixed port", input: "http://127.0.0.1:8765/callback", expected: "http://127.0.0.1:8765/callback"},
+		{name: "IPv6 loopback", input: "http://[::1]:8765/cb", expected: "http://[::1]:8765/cb"},
+		{name: "localhost without path", input: "http://localhost:8765", expected: "http://localhost:8765/"},
+		{name: "https", input: "https://127.0.0.1/callback", expectedErr: "only http loopback redirects"},
+		{name: "remote host", input: "http://example.com/callback", expectedErr: "host must be"},
+		{name: "private address", input: "http://192.168.1.10/callback", expectedErr: "host must be"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u, err := ParseRedirectURL(tt.input)
+
+			if tt.expectedErr != "" {
+				assert.ErrorContains(t, err, tt.expectedErr)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, u.String())
+		})
+	}
+}
@@ -0,0 +1,168 @@
+package core
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"net/netip"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// TLS versions accepted as minimum version of the connection to the authorization server
+const (
+	TLSVersion12 = "1.2"
+	TLSVersion13 = "1.3"
+)
+
+// HTTPSettings tunes the HTTP client talking to the authorization server. They are set globally and per client,
+// client settings win. Zero values keep the global setting or the default.
+type HTTPSettings struct {
+	// Timeout limits a single request, retries get their own timeout
+	Timeout time.Duration
+	// ProxyURL is the proxy for all requests, HTTPS_PROXY and friends are used when empty
+	ProxyURL string
+	// CACertificates are PEM encoded certificates trusted in addition to the system roots
+	CACertificates string
+	// TLSMinVersion is the minimum TLS version, 1.2 when empty
+	TLSMinVersion string
+	// Retries is how often a request failing with 429, 5xx or a connection reset is retried, nil for the default
+	Retries *int
+	// PinnedPublicKey is the base64 SHA-256 hash of the server certificate's public key (SPKI), the server is
+	// trusted when it presents this key instead of a certificate signed by a trusted CA
+	PinnedPublicKey string
+	// InsecureSkipVerify disables certificate verification, only for servers on loopback and private addresses
+	InsecureSkipVerify bool
+}
+
+// Merge returns the settings with the fields set in override replacing their values
+func (s HTTPSettings) Merge(override HTTPSettings) HTTPSettings {
+	if override.Timeout > 0 {
+		s.Timeout = override.Timeout
+	}
+	if override.ProxyURL != "" {
+		s.ProxyURL = override.ProxyURL
+	}
+	if override.CACertificates != "" {
+		// Client CAs extend the global ones, a private IdP CA shouldn't untrust the corporate proxy
+		if s.CACertificates != "" {
+			s.CACertificates += "\n"
+		}
+		s.CACertificates += override.CACertificates
+	}
+	if override.TLSMinVersion != "" {
+		s.TLSMinVersion = override.TLSMinVersion
+	}
+	if override.Retries != nil {
+		s.Retries = override.Retries
+	}
+	if override.PinnedPublicKey != "" {
+		s.PinnedPublicKey = override.PinnedPublicKey
+	}
+	if override.InsecureSkipVerify {
+		s.InsecureSkipVerify = true
+	}
+
+	return s
+}
+
+// IsZero reports whether no setting is configured
+func (s HTTPSettings) IsZero() bool {
+	return s.Timeout == 0 && s.ProxyURL == "" && s.CACertificates == "" && s.TLSMinVersion == "" && s.Retries == nil &&
+		s.PinnedPublicKey == "" && !s.InsecureSkipVerify
+}
+
+// Validate checks the proxy URL, CA certificates, TLS version and retry count
+func (s HTTPSettings) Validate() error {
+	if s.Timeout < 0 {
+		return fmt.Errorf("timeout must not be negative")
+	}
+
+	if s.ProxyURL != "" {
+		u, err := url.Parse(s.ProxyURL)
+		if err != nil || u.Host == "" {
+			return fmt.Errorf("invalid proxy URL %q", s.ProxyURL)
+		}
+
+		switch u.Scheme {
+		case "http", "https", "socks5":
+		default:
+			return fmt.Errorf("invalid proxy URL %q: scheme must be http, https or socks5", s.ProxyURL)
+		}
+	}
+
+	if s.CACertificates != "" && !x509.NewCertPool().AppendCertsFromPEM([]byte(s.CACertificates)) {
+		return fmt.Errorf("CA certificates contain no PEM encoded certificate")
+	}
+
+	switch s.TLSMinVersion {
+	case "", TLSVersion12, TLSVersion13:
+	default:
+		return fmt.Errorf("unsupported TLS version %q, expected %s or %s", s.TLSMinVersion, TLSVersion12, TLSVersion13)
+	}
+
+	if s.Retries != nil && *s.Retries < 0 {
+		return fmt.Errorf("retries must not be negative")
+	}
+
+	if s.PinnedPublicKey != "" {
+		if _, err := ParsePublicKeyPin(s.PinnedPublicKey); err != nil {
+			return err
+		}
+
+		if s.InsecureSkipVerify {
+			return fmt.Errorf("a pinned public key and insecure mode can't be used together")
+		}
+	}
+
+	if s.InsecureSkipVerify && s.ProxyURL != "" {
+		return fmt.Errorf("insecure mode can't be used with a proxy, the server address must be checked")
+	}
+
+	return nil
+}
+
+// ParsePublicKeyPin decodes a public key pin, the base64 encoded SHA-256 hash of a DER encoded
+// SubjectPublicKeyInfo. The sha256// prefix used by curl's --pinnedpubkey is accepted.
+func ParsePublicKeyPin(pin string) ([]byte, error) {
+	encoded := strings.TrimPrefix(strings.TrimPrefix(pin, "sha256//"), "sha256/")
+
+	hash, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil || len(hash) != sha256.Size {
+		return nil, fmt.Errorf("invalid public key pin %q, expected the base64 encoded SHA-256 hash of the public key", pin)
+	}
+
+	return hash, nil
+}
+
+// PublicKeyPin returns the pin of a certificate's public key
+func PublicKeyPin(cert *x509.Certificate) string {
+	hash := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+
+	return base64.StdEncoding.EncodeToString(hash[:])
+}
+
+// IsLocalAddress reports whether the address is a loopback, private (RFC 1918, RFC 4193) or link-local address,
+// the only addresses certificate verification may be skipped for
+func IsLocalAddress(addr netip.Addr) bool {
+	addr = addr.Unmap()
+
+	return addr.IsLoopback() || addr.IsPrivate() || addr.IsLinkLocalUnicast()
+}
+
+// checkInsecureURL refuses insecure mode for URLs whose host is a public IP address. Host names are
+// checked by the provider when connecting, after they are resolved.
+func checkInsecureURL(rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return fmt.Errorf("invalid URL %q", rawURL)
+	}
+
+	if addr, err := netip.ParseAddr(u.Hostname()); err == nil && !IsLocalAddress(addr) {
+		return fmt.Errorf("insecure mode is refused for %s, it is not a loopback or private address", u.Hostname())
+	}
+
+	return nil
+}
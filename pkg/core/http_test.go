@@ -0,0 +1,168 @@
+package core
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newCACertificate generates a self-signed CA certificate encoded as PEM
+func newCACertificate(t *testing.T) string {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}))
+}
+
+func TestHTTPSettings_Merge(t *testing.T) {
+	globalRetries, clientRetries := 2, 5
+
+	global := HTTPSettings{
+		Timeout:        30 * time.Second,
+		ProxyURL:       "http://proxy.example.com:3128",
+		CACertificates: "global-ca",
+		Retries:        &globalRetries,
+	}
+
+	assert.Equal(t, global, global.Merge(HTTPSettings{}))
+
+	merged := global.Merge(HTTPSettings{
+		Timeout:        5 * time.Second,
+		CACertificates: "client-ca",
+		TLSMinVersion:  TLSVersion13,
+		Retries:        &clientRetries,
+	})
+
+	assert.Equal(t, 5*time.Second, merged.Timeout)
+	assert.Equal(t, "http://proxy.example.com:3128", merged.ProxyURL)
+	assert.Equal(t, "global-ca\nclient-ca", merged.CACertificates)
+	assert.Equal(t, TLSVersion13, merged.TLSMinVersion)
+	assert.Equal(t, 5, *merged.Retries)
+}
+
+func TestHTTPSettings_IsZero(t *testing.T) {
+	assert.True(t, HTTPSettings{}.IsZero())
+	assert.False(t, HTTPSettings{Retries: new(int)}.IsZero())
+}
+
+func TestHTTPSettings_Validate(t *testing.T) {
+	negative := -1
+
+	tests := []struct {
+		name        string
+		settings    HTTPSettings
+		expectedErr string
+	}{
+		{name: "empty", settings: HTTPSettings{}},
+		{
+			name: "valid",
+			settings: HTTPSettings{
+				Timeout:        10 * time.Second,
+				ProxyURL:       "socks5://127.0.0.1:1080",
+				CACertificates: newCACertificate(t),
+				TLSMinVersion:  TLSVersion12,
+				Retries:        new(int),
+			},
+		},
+		{name: "negative timeout", settings: HTTPSettings{Timeout: -time.Second}, expectedErr: "timeout must not be negative"},
+		{name: "proxy without scheme", settings: HTTPSettings{ProxyURL: "proxy.example.com:3128"}, expectedErr: "invalid proxy URL"},
+		{name: "unsupported proxy scheme", settings: HTTPSettings{ProxyURL: "ftp://proxy.example.com"}, expectedErr: "scheme must be http, https or socks5"},
+		{name: "CA without certificate", settings: HTTPSettings{CACertificates: "not a certificate"}, expectedErr: "no PEM encoded certificate"},
+		{name: "unsupported TLS version", settings: HTTPSettings{TLSMinVersion: "1.1"}, expectedErr: `unsupported TLS version "1.1"`},
+		{name: "negative retries", settings: HTTPSettings{Retries: &negative}, expectedErr: "retries must not be negative"},
+		{name: "pinned public key", settings: HTTPSettings{PinnedPublicKey: "sha256//47DEQpj8HBSa+/TImW+5JCeuQeRkm5NMpJWZG3hSuFU="}},
+		{name: "invalid pin", settings: HTTPSettings{PinnedPublicKey: "c2hvcnQ="}, expectedErr: "invalid public key pin"},
+		{
+			name:        "pin and insecure mode",
+			settings:    HTTPSettings{PinnedPublicKey: "47DEQpj8HBSa+/TImW+5JCeuQeRkm5NMpJWZG3hSuFU=", InsecureSkipVerify: true},
+			expectedErr: "can't be used together",
+		},
+		{
+			name:        "insecure mode with proxy",
+			settings:    HTTPSettings{ProxyURL: "http://proxy.example.com:3128", InsecureSkipVerify: true},
+			expectedErr: "insecure mode can't be used with a proxy",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.settings.Validate()
+
+			if tt.expectedErr != "" {
+				assert.ErrorContains(t, err, tt.expectedErr)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestPublicKeyPin(t *testing.T) {
+	block, _ := pem.Decode([]byte(newCACertificate(t)))
+	cert, err := x509.ParseCertificate(block.Bytes)
+	require.NoError(t, err)
+
+	pin := PublicKeyPin(cert)
+	hash := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+
+	for _, encoded := range []string{pin, "sha256/" + pin, "sha256//" + pin} {
+		decoded, err := ParsePublicKeyPin(encoded)
+		require.NoError(t, err, encoded)
+		assert.Equal(t, hash[:], decoded)
+	}
+
+	_, err = ParsePublicKeyPin("not base64!")
+	assert.ErrorContains(t, err, "invalid public key pin")
+}
+
+func TestIsLocalAddress(t *testing.T) {
+	tests := map[string]bool{
+		"127.0.0.1":        true,
+		"::1":              true,
+		"10.1.2.3":         true,
+		"172.16.0.10":      true,
+		"192.168.1.20":     true,
+		"169.254.10.1":     true,
+		"fd00::1":          true,
+		"::ffff:127.0.0.1": true,
+		"8.8.8.8":          false,
+		"172.32.0.1":       false,
+		"2001:db8::1":      false,
+	}
+
+	for addr, local := range tests {
+		assert.Equal(t, local, IsLocalAddress(netip.MustParseAddr(addr)), addr)
+	}
+}
+
+func TestCheckInsecureURL(t *testing.T) {
+	assert.NoError(t, checkInsecureURL("https://127.0.0.1:8443/token"))
+	assert.NoError(t, checkInsecureURL("https://[::1]:8443/token"))
+	assert.NoError(t, checkInsecureURL("https://keycloak.dev.internal/realms/dev/protocol/openid-connect/token"))
+	assert.ErrorContains(t, checkInsecureURL("https://203.0.113.10/token"), "insecure mode is refused for 203.0.113.10")
+}
@@ -0,0 +1,138 @@
+package core
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
+
+// ErrNotJWT is returned when decoding an opaque token
+var ErrNotJWT = errors.New("token is not a JWT")
+
+// JWTStatus tells whether a JWT is valid at a point in time according to its exp and nbf claims
+type JWTStatus string
+
+const (
+	// JWTValid tokens are neither expired nor used before their nbf claim
+	JWTValid JWTStatus = "valid"
+	// JWTExpired tokens are used at or after their exp claim
+	JWTExpired JWTStatus = "expired"
+	// JWTNotYetValid tokens are used before their nbf claim
+	JWTNotYetValid JWTStatus = "not_yet_valid"
+)
+
+// JWT is a decoded JSON Web Token. The signature is not verified, the claims must not be trusted.
+type JWT struct {
+	Header map[string]any
+	Claims map[string]any
+}
+
+// ParseJWT decodes the header and claims of a signed JWT (RFC 7519) without verifying its signature.
+// A "Bearer " prefix, as copied from an Authorization header, is ignored. Integer claims are decoded as int64.
+func ParseJWT(token string) (*JWT, error) {
+	token = strings.TrimSpace(token)
+	if scheme, rest, found := strings.Cut(token, " "); found && strings.EqualFold(scheme, "bearer") {
+		token = strings.TrimSpace(rest)
+	}
+
+	parts := strings.Split(token, ".")
+
+	switch len(parts) {
+	case 3:
+	case 5:
+		return nil, fmt.Errorf("token is encrypted (JWE), its claims can only be read with the recipient's key")
+	default:
+		return nil, ErrNotJWT
+	}
+
+	header, err := decodeSegment(parts[0])
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid header: %v", ErrNotJWT, err)
+	}
+
+	claims, err := decodeSegment(parts[1])
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid claims: %v", ErrNotJWT, err)
+	}
+
+	return &JWT{Header: header, Claims: claims}, nil
+}
+
+// decodeSegment decodes a base64url encoded JSON object of a JWT
+func decodeSegment(segment string) (map[string]any, error) {
+	// Padding is not allowed by RFC 7515, but some issuers add it anyway
+	data, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(segment, "="))
+	if err != nil {
+		return nil, fmt.Errorf("not base64url encoded")
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+
+	var object map[string]any
+	if err := dec.Decode(&object); err != nil || object == nil {
+		return nil, fmt.Errorf("not a JSON object")
+	}
+
+	normalizeNumbers(object)
+
+	return object, nil
+}
+
+// normalizeNumbers replaces JSON numbers with int64 values where they fit, float64 otherwise,
+// so large integer claims keep all their digits
+func normalizeNumbers(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		for key, value := range v {
+			v[key] = normalizeNumbers(value)
+		}
+	case []any:
+		for i, value := range v {
+			v[i] = normalizeNumbers(value)
+		}
+	case json.Number:
+		if n, err := v.Int64(); err == nil {
+			return n
+		}
+
+		if f, err := v.Float64(); err == nil {
+			return f
+		}
+
+		return v.String()
+	}
+
+	return v
+}
+
+// Time returns a NumericDate claim such as exp, iat or nbf, false when it is missing or not a number
+func (j *JWT) Time(claim string) (time.Time, bool) {
+	switch v := j.Claims[claim].(type) {
+	case int64:
+		return time.Unix(v, 0), true
+	case float64:
+		sec, frac := math.Modf(v)
+		return time.Unix(int64(sec), int64(frac*float64(time.Second))), true
+	default:
+		return time.Time{}, false
+	}
+}
+
+// Status tells whether the token is expired or not yet valid at the given time. Tokens without exp never expire.
+func (j *JWT) Status(now time.Time) JWTStatus {
+	if exp, ok := j.Time("exp"); ok && !now.Before(exp) {
+		return JWTExpired
+	}
+
+	if nbf, ok := j.Time("nbf"); ok && now.Before(nbf) {
+		return JWTNotYetValid
+	}
+
+	return JWTValid
+}
@@ -0,0 +1,91 @@
+package core
+
+import (
+	"encoding/base64"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testJWT builds an unsigned compact JWT from a JSON header and claims
+func testJWT(header, claims string) string {
+	enc := base64.RawURLEncoding
+	return enc.EncodeToString([]byte(header)) + "." + enc.EncodeToString([]byte(claims)) + ".c2lnbmF0dXJl"
+}
+
+func TestParseJWT(t *testing.T) {
+	token := testJWT(`{"alg":"RS256","kid":"key-1"}`, `{"sub":"alice","exp":1767225600,"iat":1767222000.5,"aud":["api"],"big":9007199254740993}`)
+
+	jwt, err := ParseJWT(token)
+	require.NoError(t, err)
+
+	assert.Equal(t, map[string]any{"alg": "RS256", "kid": "key-1"}, jwt.Header)
+	assert.Equal(t, "alice", jwt.Claims["sub"])
+	assert.Equal(t, []any{"api"}, jwt.Claims["aud"])
+	assert.Equal(t, int64(9007199254740993), jwt.Claims["big"], "integers must keep all digits")
+
+	exp, ok := jwt.Time("exp")
+	require.True(t, ok)
+	assert.Equal(t, time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC), exp.UTC())
+
+	iat, ok := jwt.Time("iat")
+	require.True(t, ok)
+	assert.Equal(t, time.Unix(1767222000, 500_000_000), iat)
+
+	_, ok = jwt.Time("nbf")
+	assert.False(t, ok)
+
+	_, ok = jwt.Time("sub")
+	assert.False(t, ok)
+
+	bearer, err := ParseJWT("Bearer " + token + "\n")
+	require.NoError(t, err)
+	assert.Equal(t, jwt, bearer)
+}
+
+func TestParseJWT_Errors(t *testing.T) {
+	tests := []struct {
+		name    string
+		token   string
+		wantErr string
+	}{
+		{name: "opaque", token: "mock_token_1234", wantErr: "token is not a JWT"},
+		{name: "encrypted", token: "a.b.c.d.e", wantErr: "encrypted (JWE)"},
+		{name: "invalid base64", token: "!!.e30.sig", wantErr: "invalid header: not base64url encoded"},
+		{name: "claims not an object", token: testJWT(`{"alg":"none"}`, `[1,2]`), wantErr: "invalid claims: not a JSON object"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			jwt, err := ParseJWT(tt.token)
+			assert.ErrorContains(t, err, tt.wantErr)
+			assert.Nil(t, jwt)
+		})
+	}
+
+	_, err := ParseJWT("a.b")
+	assert.ErrorIs(t, err, ErrNotJWT)
+}
+
+func TestJWT_Status(t *testing.T) {
+	now := time.Unix(1767225600, 0)
+
+	tests := []struct {
+		name   string
+		claims map[string]any
+		want   JWTStatus
+	}{
+		{name: "no time claims", claims: map[string]any{}, want: JWTValid},
+		{name: "valid", claims: map[string]any{"exp": int64(1767225601), "nbf": int64(1767225600)}, want: JWTValid},
+		{name: "expired at exp", claims: map[string]any{"exp": int64(1767225600)}, want: JWTExpired},
+		{name: "not yet valid", claims: map[string]any{"nbf": float64(1767225600.5)}, want: JWTNotYetValid},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, (&JWT{Claims: tt.claims}).Status(now))
+		})
+	}
+}
@@ -0,0 +1,45 @@
+package core
+
+import "slices"
+
+// ServerMetadata describes an authorization server as published by OIDC discovery
+// or OAuth 2.0 Authorization Server Metadata (RFC 8414)
+type ServerMetadata struct {
+	Issuer                      string
+	TokenEndpoint               string
+	AuthorizationEndpoint       string
+	DeviceAuthorizationEndpoint string
+	TokenEndpointAuthMethods    []string
+	GrantTypes                  []string
+}
+
+// SupportsAuthMethod reports whether the server accepts the token endpoint auth method.
+// RFC 8414 defines client_secret_basic as the default when the server doesn't list its methods.
+func (m ServerMetadata) SupportsAuthMethod(method AuthMethod) bool {
+	if len(m.TokenEndpointAuthMethods) == 0 {
+		return method == AuthMethodClientSecretBasic
+	}
+
+	return slices.Contains(m.TokenEndpointAuthMethods, string(method))
+}
+
+// SupportsGrant reports whether the server accepts the grant type.
+// RFC 8414 defines authorization_code and implicit as the default when the server doesn't list its grants.
+func (m ServerMetadata) SupportsGrant(grant string) bool {
+	if len(m.GrantTypes) == 0 {
+		return grant == "authorization_code" || grant == "implicit"
+	}
+
+	return slices.Contains(m.GrantTypes, grant)
+}
+
+// DefaultAuthMethod picks the secret based auth method the server supports, preferring client_secret_post
+func (m ServerMetadata) DefaultAuthMethod() AuthMethod {
+	for _, method := range []AuthMethod{AuthMethodClientSecretPost, AuthMethodClientSecretBasic} {
+		if m.SupportsAuthMethod(method) {
+			return method
+		}
+	}
+
+	return AuthMethodClientSecretPost
+}
@@ -0,0 +1,31 @@
+package core
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServerMetadata_SupportsAuthMethod(t *testing.T) {
+	// RFC 8414 default when the server doesn't list its methods
+	assert.True(t, ServerMetadata{}.SupportsAuthMethod(AuthMethodClientSecretBasic))
+	assert.False(t, ServerMetadata{}.SupportsAuthMethod(AuthMethodClientSecretPost))
+
+	metadata := ServerMetadata{TokenEndpointAuthMethods: []string{"private_key_jwt"}}
+	assert.True(t, metadata.SupportsAuthMethod(AuthMethodPrivateKeyJWT))
+	assert.False(t, metadata.SupportsAuthMethod(AuthMethodClientSecretBasic))
+}
+
+func TestServerMetadata_SupportsGrant(t *testing.T) {
+	assert.True(t, ServerMetadata{}.SupportsGrant("authorization_code"))
+	assert.False(t, ServerMetadata{}.SupportsGrant("client_credentials"))
+	assert.True(t, ServerMetadata{GrantTypes: []string{"client_credentials"}}.SupportsGrant("client_credentials"))
+}
+
+func TestServerMetadata_DefaultAuthMethod(t *testing.T) {
+	assert.Equal(t, AuthMethodClientSecretBasic, ServerMetadata{}.DefaultAuthMethod())
+	assert.Equal(t, AuthMethodClientSecretPost,
+		ServerMetadata{TokenEndpointAuthMethods: []string{"client_secret_basic", "client_secret_post"}}.DefaultAuthMethod())
+	assert.Equal(t, AuthMethodClientSecretPost,
+		ServerMetadata{TokenEndpointAuthMethods: []string{"private_key_jwt"}}.DefaultAuthMethod())
+}
@@ -0,0 +1,154 @@
+package core
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"maps"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+)
+
+// ParseExtraParams reads extra form parameters given as key=value pairs
+func ParseExtraParams(pairs []string) (map[string]string, error) {
+	if len(pairs) == 0 {
+		return nil, nil
+	}
+
+	params := make(map[string]string, len(pairs))
+
+	for _, pair := range pairs {
+		key, value, found := strings.Cut(pair, "=")
+		if !found || strings.TrimSpace(key) == "" {
+			return nil, fmt.Errorf("invalid parameter %q, expected key=value", pair)
+		}
+
+		params[strings.TrimSpace(key)] = value
+	}
+
+	return params, nil
+}
+
+// ParseExtraHeaders reads extra HTTP headers given as "Name: value" lines, as curl's --header takes them
+func ParseExtraHeaders(lines []string) (map[string]string, error) {
+	if len(lines) == 0 {
+		return nil, nil
+	}
+
+	headers := make(map[string]string, len(lines))
+
+	for _, line := range lines {
+		name, value, found := strings.Cut(line, ":")
+		if !found {
+			return nil, fmt.Errorf("invalid header %q, expected \"Name: value\"", line)
+		}
+
+		headers[http.CanonicalHeaderKey(strings.TrimSpace(name))] = strings.TrimSpace(value)
+	}
+
+	if err := validateExtraHeaders(headers); err != nil {
+		return nil, err
+	}
+
+	return headers, nil
+}
+
+// validateTarget checks the audience, resource indicators, extra parameters and headers sent with token requests
+func validateTarget(client Client) error {
+	for _, resource := range client.Resource {
+		// RFC 8707 section 2: an absolute URI without a fragment
+		u, err := url.Parse(resource)
+		if err != nil || !u.IsAbs() || u.Fragment != "" {
+			return fmt.Errorf("invalid resource %q, expected an absolute URI without a fragment", resource)
+		}
+	}
+
+	for key := range client.ExtraParams {
+		if strings.TrimSpace(key) == "" {
+			return fmt.Errorf("extra parameter names must not be empty")
+		}
+	}
+
+	return validateExtraHeaders(client.ExtraHeaders)
+}
+
+// validateExtraHeaders refuses header names and values that would break the request
+func validateExtraHeaders(headers map[string]string) error {
+	for name, value := range headers {
+		if name == "" || strings.ContainsAny(name, " \t\r\n:") {
+			return fmt.Errorf("invalid header name %q", name)
+		}
+
+		if strings.ContainsAny(value, "\r\n") {
+			return fmt.Errorf("value of header %s must not contain line breaks", name)
+		}
+	}
+
+	return nil
+}
+
+// withTokenOverrides returns the client with the audience, resource indicators, extra parameters and headers
+// given for a single token request. Parameters and headers are merged with the stored ones by name.
+func withTokenOverrides(client Client, opts TokenOptions) Client {
+	if opts.Audience != "" {
+		client.Audience = opts.Audience
+	}
+
+	if len(opts.Resource) > 0 {
+		client.Resource = opts.Resource
+	}
+
+	if len(opts.ExtraParams) > 0 {
+		client.ExtraParams = maps.Clone(client.ExtraParams)
+		if client.ExtraParams == nil {
+			client.ExtraParams = make(map[string]string, len(opts.ExtraParams))
+		}
+
+		maps.Copy(client.ExtraParams, opts.ExtraParams)
+	}
+
+	if len(opts.ExtraHeaders) > 0 {
+		client.ExtraHeaders = maps.Clone(client.ExtraHeaders)
+		if client.ExtraHeaders == nil {
+			client.ExtraHeaders = make(map[string]string, len(opts.ExtraHeaders))
+		}
+
+		maps.Copy(client.ExtraHeaders, opts.ExtraHeaders)
+	}
+
+	return client
+}
+
+// targetCacheKey distinguishes cached tokens issued for different audiences, resources, parameters and headers.
+// It is empty for clients without them, so their cache keys stay unchanged. Values are hashed, headers
+// often carry API keys.
+func targetCacheKey(client Client) string {
+	if client.Audience == "" && len(client.Resource) == 0 && len(client.ExtraParams) == 0 && len(client.ExtraHeaders) == 0 {
+		return ""
+	}
+
+	resources := slices.Clone(client.Resource)
+	slices.Sort(resources)
+
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "audience=%q\n", client.Audience)
+
+	for _, resource := range slices.Compact(resources) {
+		fmt.Fprintf(&b, "resource=%q\n", resource)
+	}
+
+	for _, key := range slices.Sorted(maps.Keys(client.ExtraParams)) {
+		fmt.Fprintf(&b, "param=%q=%q\n", key, client.ExtraParams[key])
+	}
+
+	for _, name := range slices.Sorted(maps.Keys(client.ExtraHeaders)) {
+		fmt.Fprintf(&b, "header=%q=%q\n", name, client.ExtraHeaders[name])
+	}
+
+	sum := sha256.Sum256([]byte(b.String()))
+
+	return "|" + hex.EncodeToString(sum[:8])
+}
@@ -0,0 +1,84 @@
+package core
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseExtraParams(t *testing.T) {
+	params, err := ParseExtraParams([]string{"tenant=acme", "filter=a=b", "empty="})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"tenant": "acme", "filter": "a=b", "empty": ""}, params)
+
+	params, err = ParseExtraParams(nil)
+	require.NoError(t, err)
+	assert.Nil(t, params)
+
+	_, err = ParseExtraParams([]string{"tenant"})
+	assert.ErrorContains(t, err, `invalid parameter "tenant", expected key=value`)
+
+	_, err = ParseExtraParams([]string{"=acme"})
+	assert.ErrorContains(t, err, "invalid parameter")
+}
+
+func TestParseExtraHeaders(t *testing.T) {
+	headers, err := ParseExtraHeaders([]string{"x-gateway-key: secret", "X-Trace:abc:def"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"X-Gateway-Key": "secret", "X-Trace": "abc:def"}, headers)
+
+	_, err = ParseExtraHeaders([]string{"X-Gateway-Key=secret"})
+	assert.ErrorContains(t, err, `expected "Name: value"`)
+
+	_, err = ParseExtraHeaders([]string{"X Gateway: secret"})
+	assert.ErrorContains(t, err, "invalid header name")
+}
+
+func TestValidateTarget(t *testing.T) {
+	assert.NoError(t, validateTarget(Client{Resource: []string{"https://api.example.com/orders", "urn:example:billing"}}))
+	assert.ErrorContains(t, validateTarget(Client{Resource: []string{"orders"}}), `invalid resource "orders"`)
+	assert.ErrorContains(t, validateTarget(Client{Resource: []string{"https://api.example.com#orders"}}), "without a fragment")
+	assert.ErrorContains(t, validateTarget(Client{ExtraParams: map[string]string{" ": "x"}}), "must not be empty")
+	assert.ErrorContains(t, validateTarget(Client{ExtraHeaders: map[string]string{"X-Key": "a\r\nb"}}), "line breaks")
+}
+
+func TestWithTokenOverrides(t *testing.T) {
+	client := Client{
+		Audience:     "https://api.example.com",
+		Resource:     []string{"https://api.example.com/orders"},
+		ExtraParams:  map[string]string{"tenant": "acme"},
+		ExtraHeaders: map[string]string{"X-Gateway-Key": "stored"},
+	}
+
+	assert.Equal(t, client, withTokenOverrides(client, TokenOptions{}))
+
+	got := withTokenOverrides(client, TokenOptions{
+		Resource:     []string{"https://api.example.com/billing"},
+		ExtraParams:  map[string]string{"region": "eu"},
+		ExtraHeaders: map[string]string{"X-Gateway-Key": "override"},
+	})
+
+	assert.Equal(t, "https://api.example.com", got.Audience)
+	assert.Equal(t, []string{"https://api.example.com/billing"}, got.Resource)
+	assert.Equal(t, map[string]string{"tenant": "acme", "region": "eu"}, got.ExtraParams)
+	assert.Equal(t, map[string]string{"X-Gateway-Key": "override"}, got.ExtraHeaders)
+	assert.Equal(t, map[string]string{"tenant": "acme"}, client.ExtraParams, "stored parameters must not change")
+
+	got = withTokenOverrides(Client{}, TokenOptions{ExtraParams: map[string]string{"region": "eu"}})
+	assert.Equal(t, map[string]string{"region": "eu"}, got.ExtraParams)
+}
+
+func TestTargetCacheKey(t *testing.T) {
+	assert.Empty(t, targetCacheKey(Client{}))
+
+	a := targetCacheKey(Client{Resource: []string{"https://a.example.com", "https://b.example.com"}})
+	b := targetCacheKey(Client{Resource: []string{"https://b.example.com", "https://a.example.com"}})
+	assert.Equal(t, a, b, "resource order must not matter")
+
+	assert.NotEqual(t, targetCacheKey(Client{Audience: "https://a.example.com"}), targetCacheKey(Client{Audience: "https://b.example.com"}))
+	assert.NotEqual(t, targetCacheKey(Client{ExtraParams: map[string]string{"tenant": "acme"}}), targetCacheKey(Client{ExtraParams: map[string]string{"tenant": "globex"}}))
+
+	key := targetCacheKey(Client{ExtraHeaders: map[string]string{"X-Gateway-Key": "secret-key"}})
+	assert.NotContains(t, key, "secret-key")
+}
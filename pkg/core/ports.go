@@ -9,6 +9,10 @@ type Repository interface {
 	Load(ctx context.Context, password string) error
 	// Save stores a client
 	Save(ctx context.Context, client Client) error
+	// Update replaces an existing client with the same name, preserving its creation time
+	Update(ctx context.Context, client Client) error
+	// UpdateEndpoints stores the client's endpoint URLs re-resolved from its issuer, keeping its tokens
+	UpdateEndpoints(ctx context.Context, client Client) error
 
 	// Get retrieves a client by name
 	Get(ctx context.Context, name string) (*Client, error)
@@ -21,6 +25,20 @@ type Repository interface {
 
 	// Delete removes a client by name
 	Delete(ctx context.Context, name string) error
+	// ChangePassword re-encrypts the repository with a new password
+	ChangePassword(ctx context.Context, oldPassword, newPassword string) error
+	// GetCachedToken returns the cached token for the client and cache key, or nil when there is none
+	GetCachedToken(ctx context.Context, clientName, key string) (*Token, error)
+	// SaveCachedToken stores a token in the cache for the client and cache key
+	SaveCachedToken(ctx context.Context, clientName, key string, token Token) error
+	// GetRefreshToken returns the stored refresh token of the client, or an empty string when there is none
+	GetRefreshToken(ctx context.Context, clientName string) (string, error)
+	// SaveRefreshToken stores the refresh token of the client, an empty token removes it
+	SaveRefreshToken(ctx context.Context, clientName, refreshToken string) error
+	// ListBackups returns previous generations of the repository, newest first
+	ListBackups(ctx context.Context) ([]Backup, error)
+	// RestoreBackup replaces the repository content with the given backup generation
+	RestoreBackup(ctx context.Context, index int) error
 
 	// Exists checks if repository is initialized
 	Exists() bool
@@ -29,6 +47,19 @@ type Repository interface {
 // Provider defines the interface for OAuth2 token operations
 // Interface is defined on consumer side (core) following hexagonal architecture
 type Provider interface {
-	// GetToken obtains an access token using client credentials
-	GetToken(ctx context.Context, client Client) (*Token, error)
+	// GetToken obtains an access token for the client using the requested grant
+	GetToken(ctx context.Context, client Client, req TokenRequest) (*Token, error)
+	// Discover fetches the authorization server metadata for an issuer, connecting with the given HTTP settings
+	Discover(ctx context.Context, issuer string, settings HTTPSettings) (*ServerMetadata, error)
+}
+
+// Interactor lets the service and provider involve the user in interactive grants
+// Interface is defined on consumer side (core) following hexagonal architecture
+type Interactor interface {
+	// Authorize sends the user to the authorization URL to sign in and approve the request
+	Authorize(ctx context.Context, authURL string) error
+	// ShowDeviceCode tells the user where to enter the user code to approve a device code request
+	ShowDeviceCode(ctx context.Context, auth DeviceAuthorization) error
+	// Credentials asks the user for the resource owner username and password of the password grant
+	Credentials(ctx context.Context, clientName string) (username, password string, err error)
 }
@@ -23,9 +23,69 @@ func (_m *MockProvider) EXPECT() *MockProvider_Expecter {
 	return &MockProvider_Expecter{mock: &_m.Mock}
 }
 
-// GetToken provides a mock function with given fields: ctx, client
-func (_m *MockProvider) GetToken(ctx context.Context, client Client) (*Token, error) {
-	ret := _m.Called(ctx, client)
+// Discover provides a mock function with given fields: ctx, issuer, settings
+func (_m *MockProvider) Discover(ctx context.Context, issuer string, settings HTTPSettings) (*ServerMetadata, error) {
+	ret := _m.Called(ctx, issuer, settings)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Discover")
+	}
+
+	var r0 *ServerMetadata
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, HTTPSettings) (*ServerMetadata, error)); ok {
+		return rf(ctx, issuer, settings)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string, HTTPSettings) *ServerMetadata); ok {
+		r0 = rf(ctx, issuer, settings)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*ServerMetadata)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string, HTTPSettings) error); ok {
+		r1 = rf(ctx, issuer, settings)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// MockProvider_Discover_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Discover'
+type MockProvider_Discover_Call struct {
+	*mock.Call
+}
+
+// Discover is a helper method to define mock.On call
+//   - ctx context.Context
+//   - issuer string
+//   - settings HTTPSettings
+func (_e *MockProvider_Expecter) Discover(ctx interface{}, issuer interface{}, settings interface{}) *MockProvider_Discover_Call {
+	return &MockProvider_Discover_Call{Call: _e.mock.On("Discover", ctx, issuer, settings)}
+}
+
+func (_c *MockProvider_Discover_Call) Run(run func(ctx context.Context, issuer string, settings HTTPSettings)) *MockProvider_Discover_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(string), args[2].(HTTPSettings))
+	})
+	return _c
+}
+
+func (_c *MockProvider_Discover_Call) Return(_a0 *ServerMetadata, _a1 error) *MockProvider_Discover_Call {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
+func (_c *MockProvider_Discover_Call) RunAndReturn(run func(context.Context, string, HTTPSettings) (*ServerMetadata, error)) *MockProvider_Discover_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// GetToken provides a mock function with given fields: ctx, client, req
+func (_m *MockProvider) GetToken(ctx context.Context, client Client, req TokenRequest) (*Token, error) {
+	ret := _m.Called(ctx, client, req)
 
 	if len(ret) == 0 {
 		panic("no return value specified for GetToken")
@@ -33,19 +93,19 @@ func (_m *MockProvider) GetToken(ctx context.Context, client Client) (*Token, er
 
 	var r0 *Token
 	var r1 error
-	if rf, ok := ret.Get(0).(func(context.Context, Client) (*Token, error)); ok {
-		return rf(ctx, client)
+	if rf, ok := ret.Get(0).(func(context.Context, Client, TokenRequest) (*Token, error)); ok {
+		return rf(ctx, client, req)
 	}
-	if rf, ok := ret.Get(0).(func(context.Context, Client) *Token); ok {
-		r0 = rf(ctx, client)
+	if rf, ok := ret.Get(0).(func(context.Context, Client, TokenRequest) *Token); ok {
+		r0 = rf(ctx, client, req)
 	} else {
 		if ret.Get(0) != nil {
 			r0 = ret.Get(0).(*Token)
 		}
 	}
 
-	if rf, ok := ret.Get(1).(func(context.Context, Client) error); ok {
-		r1 = rf(ctx, client)
+	if rf, ok := ret.Get(1).(func(context.Context, Client, TokenRequest) error); ok {
+		r1 = rf(ctx, client, req)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -61,13 +121,14 @@ type MockProvider_GetToken_Call struct {
 // GetToken is a helper method to define mock.On call
 //   - ctx context.Context
 //   - client Client
-func (_e *MockProvider_Expecter) GetToken(ctx interface{}, client interface{}) *MockProvider_GetToken_Call {
-	return &MockProvider_GetToken_Call{Call: _e.mock.On("GetToken", ctx, client)}
+//   - req TokenRequest
+func (_e *MockProvider_Expecter) GetToken(ctx interface{}, client interface{}, req interface{}) *MockProvider_GetToken_Call {
+	return &MockProvider_GetToken_Call{Call: _e.mock.On("GetToken", ctx, client, req)}
 }
 
-func (_c *MockProvider_GetToken_Call) Run(run func(ctx context.Context, client Client)) *MockProvider_GetToken_Call {
+func (_c *MockProvider_GetToken_Call) Run(run func(ctx context.Context, client Client, req TokenRequest)) *MockProvider_GetToken_Call {
 	_c.Call.Run(func(args mock.Arguments) {
-		run(args[0].(context.Context), args[1].(Client))
+		run(args[0].(context.Context), args[1].(Client), args[2].(TokenRequest))
 	})
 	return _c
 }
@@ -77,7 +138,7 @@ func (_c *MockProvider_GetToken_Call) Return(_a0 *Token, _a1 error) *MockProvide
 	return _c
 }
 
-func (_c *MockProvider_GetToken_Call) RunAndReturn(run func(context.Context, Client) (*Token, error)) *MockProvider_GetToken_Call {
+func (_c *MockProvider_GetToken_Call) RunAndReturn(run func(context.Context, Client, TokenRequest) (*Token, error)) *MockProvider_GetToken_Call {
 	_c.Call.Return(run)
 	return _c
 }
@@ -23,6 +23,54 @@ func (_m *MockRepository) EXPECT() *MockRepository_Expecter {
 	return &MockRepository_Expecter{mock: &_m.Mock}
 }
 
+// ChangePassword provides a mock function with given fields: ctx, oldPassword, newPassword
+func (_m *MockRepository) ChangePassword(ctx context.Context, oldPassword string, newPassword string) error {
+	ret := _m.Called(ctx, oldPassword, newPassword)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ChangePassword")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) error); ok {
+		r0 = rf(ctx, oldPassword, newPassword)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// MockRepository_ChangePassword_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'ChangePassword'
+type MockRepository_ChangePassword_Call struct {
+	*mock.Call
+}
+
+// ChangePassword is a helper method to define mock.On call
+//   - ctx context.Context
+//   - oldPassword string
+//   - newPassword string
+func (_e *MockRepository_Expecter) ChangePassword(ctx interface{}, oldPassword interface{}, newPassword interface{}) *MockRepository_ChangePassword_Call {
+	return &MockRepository_ChangePassword_Call{Call: _e.mock.On("ChangePassword", ctx, oldPassword, newPassword)}
+}
+
+func (_c *MockRepository_ChangePassword_Call) Run(run func(ctx context.Context, oldPassword string, newPassword string)) *MockRepository_ChangePassword_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(string), args[2].(string))
+	})
+	return _c
+}
+
+func (_c *MockRepository_ChangePassword_Call) Return(_a0 error) *MockRepository_ChangePassword_Call {
+	_c.Call.Return(_a0)
+	return _c
+}
+
+func (_c *MockRepository_ChangePassword_Call) RunAndReturn(run func(context.Context, string, string) error) *MockRepository_ChangePassword_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
 // Delete provides a mock function with given fields: ctx, name
 func (_m *MockRepository) Delete(ctx context.Context, name string) error {
 	ret := _m.Called(ctx, name)
@@ -232,6 +280,123 @@ func (_c *MockRepository_GetAll_Call) RunAndReturn(run func(context.Context) ([]
 	return _c
 }
 
+// GetCachedToken provides a mock function with given fields: ctx, clientName, key
+func (_m *MockRepository) GetCachedToken(ctx context.Context, clientName string, key string) (*Token, error) {
+	ret := _m.Called(ctx, clientName, key)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetCachedToken")
+	}
+
+	var r0 *Token
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) (*Token, error)); ok {
+		return rf(ctx, clientName, key)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) *Token); ok {
+		r0 = rf(ctx, clientName, key)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*Token)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string, string) error); ok {
+		r1 = rf(ctx, clientName, key)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// MockRepository_GetCachedToken_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'GetCachedToken'
+type MockRepository_GetCachedToken_Call struct {
+	*mock.Call
+}
+
+// GetCachedToken is a helper method to define mock.On call
+//   - ctx context.Context
+//   - clientName string
+//   - key string
+func (_e *MockRepository_Expecter) GetCachedToken(ctx interface{}, clientName interface{}, key interface{}) *MockRepository_GetCachedToken_Call {
+	return &MockRepository_GetCachedToken_Call{Call: _e.mock.On("GetCachedToken", ctx, clientName, key)}
+}
+
+func (_c *MockRepository_GetCachedToken_Call) Run(run func(ctx context.Context, clientName string, key string)) *MockRepository_GetCachedToken_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(string), args[2].(string))
+	})
+	return _c
+}
+
+func (_c *MockRepository_GetCachedToken_Call) Return(_a0 *Token, _a1 error) *MockRepository_GetCachedToken_Call {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
+func (_c *MockRepository_GetCachedToken_Call) RunAndReturn(run func(context.Context, string, string) (*Token, error)) *MockRepository_GetCachedToken_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// GetRefreshToken provides a mock function with given fields: ctx, clientName
+func (_m *MockRepository) GetRefreshToken(ctx context.Context, clientName string) (string, error) {
+	ret := _m.Called(ctx, clientName)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetRefreshToken")
+	}
+
+	var r0 string
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) (string, error)); ok {
+		return rf(ctx, clientName)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string) string); ok {
+		r0 = rf(ctx, clientName)
+	} else {
+		r0 = ret.Get(0).(string)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, clientName)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// MockRepository_GetRefreshToken_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'GetRefreshToken'
+type MockRepository_GetRefreshToken_Call struct {
+	*mock.Call
+}
+
+// GetRefreshToken is a helper method to define mock.On call
+//   - ctx context.Context
+//   - clientName string
+func (_e *MockRepository_Expecter) GetRefreshToken(ctx interface{}, clientName interface{}) *MockRepository_GetRefreshToken_Call {
+	return &MockRepository_GetRefreshToken_Call{Call: _e.mock.On("GetRefreshToken", ctx, clientName)}
+}
+
+func (_c *MockRepository_GetRefreshToken_Call) Run(run func(ctx context.Context, clientName string)) *MockRepository_GetRefreshToken_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(string))
+	})
+	return _c
+}
+
+func (_c *MockRepository_GetRefreshToken_Call) Return(_a0 string, _a1 error) *MockRepository_GetRefreshToken_Call {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
+func (_c *MockRepository_GetRefreshToken_Call) RunAndReturn(run func(context.Context, string) (string, error)) *MockRepository_GetRefreshToken_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
 // List provides a mock function with given fields: ctx
 func (_m *MockRepository) List(ctx context.Context) ([]string, error) {
 	ret := _m.Called(ctx)
@@ -290,6 +455,64 @@ func (_c *MockRepository_List_Call) RunAndReturn(run func(context.Context) ([]st
 	return _c
 }
 
+// ListBackups provides a mock function with given fields: ctx
+func (_m *MockRepository) ListBackups(ctx context.Context) ([]Backup, error) {
+	ret := _m.Called(ctx)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ListBackups")
+	}
+
+	var r0 []Backup
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context) ([]Backup, error)); ok {
+		return rf(ctx)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context) []Backup); ok {
+		r0 = rf(ctx)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]Backup)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = rf(ctx)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// MockRepository_ListBackups_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'ListBackups'
+type MockRepository_ListBackups_Call struct {
+	*mock.Call
+}
+
+// ListBackups is a helper method to define mock.On call
+//   - ctx context.Context
+func (_e *MockRepository_Expecter) ListBackups(ctx interface{}) *MockRepository_ListBackups_Call {
+	return &MockRepository_ListBackups_Call{Call: _e.mock.On("ListBackups", ctx)}
+}
+
+func (_c *MockRepository_ListBackups_Call) Run(run func(ctx context.Context)) *MockRepository_ListBackups_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context))
+	})
+	return _c
+}
+
+func (_c *MockRepository_ListBackups_Call) Return(_a0 []Backup, _a1 error) *MockRepository_ListBackups_Call {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
+func (_c *MockRepository_ListBackups_Call) RunAndReturn(run func(context.Context) ([]Backup, error)) *MockRepository_ListBackups_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
 // Load provides a mock function with given fields: ctx, password
 func (_m *MockRepository) Load(ctx context.Context, password string) error {
 	ret := _m.Called(ctx, password)
@@ -337,6 +560,53 @@ func (_c *MockRepository_Load_Call) RunAndReturn(run func(context.Context, strin
 	return _c
 }
 
+// RestoreBackup provides a mock function with given fields: ctx, index
+func (_m *MockRepository) RestoreBackup(ctx context.Context, index int) error {
+	ret := _m.Called(ctx, index)
+
+	if len(ret) == 0 {
+		panic("no return value specified for RestoreBackup")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, int) error); ok {
+		r0 = rf(ctx, index)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// MockRepository_RestoreBackup_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'RestoreBackup'
+type MockRepository_RestoreBackup_Call struct {
+	*mock.Call
+}
+
+// RestoreBackup is a helper method to define mock.On call
+//   - ctx context.Context
+//   - index int
+func (_e *MockRepository_Expecter) RestoreBackup(ctx interface{}, index interface{}) *MockRepository_RestoreBackup_Call {
+	return &MockRepository_RestoreBackup_Call{Call: _e.mock.On("RestoreBackup", ctx, index)}
+}
+
+func (_c *MockRepository_RestoreBackup_Call) Run(run func(ctx context.Context, index int)) *MockRepository_RestoreBackup_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(int))
+	})
+	return _c
+}
+
+func (_c *MockRepository_RestoreBackup_Call) Return(_a0 error) *MockRepository_RestoreBackup_Call {
+	_c.Call.Return(_a0)
+	return _c
+}
+
+func (_c *MockRepository_RestoreBackup_Call) RunAndReturn(run func(context.Context, int) error) *MockRepository_RestoreBackup_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
 // Save provides a mock function with given fields: ctx, client
 func (_m *MockRepository) Save(ctx context.Context, client Client) error {
 	ret := _m.Called(ctx, client)
@@ -384,6 +654,197 @@ func (_c *MockRepository_Save_Call) RunAndReturn(run func(context.Context, Clien
 	return _c
 }
 
+// SaveCachedToken provides a mock function with given fields: ctx, clientName, key, token
+func (_m *MockRepository) SaveCachedToken(ctx context.Context, clientName string, key string, token Token) error {
+	ret := _m.Called(ctx, clientName, key, token)
+
+	if len(ret) == 0 {
+		panic("no return value specified for SaveCachedToken")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string, Token) error); ok {
+		r0 = rf(ctx, clientName, key, token)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// MockRepository_SaveCachedToken_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'SaveCachedToken'
+type MockRepository_SaveCachedToken_Call struct {
+	*mock.Call
+}
+
+// SaveCachedToken is a helper method to define mock.On call
+//   - ctx context.Context
+//   - clientName string
+//   - key string
+//   - token Token
+func (_e *MockRepository_Expecter) SaveCachedToken(ctx interface{}, clientName interface{}, key interface{}, token interface{}) *MockRepository_SaveCachedToken_Call {
+	return &MockRepository_SaveCachedToken_Call{Call: _e.mock.On("SaveCachedToken", ctx, clientName, key, token)}
+}
+
+func (_c *MockRepository_SaveCachedToken_Call) Run(run func(ctx context.Context, clientName string, key string, token Token)) *MockRepository_SaveCachedToken_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(string), args[2].(string), args[3].(Token))
+	})
+	return _c
+}
+
+func (_c *MockRepository_SaveCachedToken_Call) Return(_a0 error) *MockRepository_SaveCachedToken_Call {
+	_c.Call.Return(_a0)
+	return _c
+}
+
+func (_c *MockRepository_SaveCachedToken_Call) RunAndReturn(run func(context.Context, string, string, Token) error) *MockRepository_SaveCachedToken_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// SaveRefreshToken provides a mock function with given fields: ctx, clientName, refreshToken
+func (_m *MockRepository) SaveRefreshToken(ctx context.Context, clientName string, refreshToken string) error {
+	ret := _m.Called(ctx, clientName, refreshToken)
+
+	if len(ret) == 0 {
+		panic("no return value specified for SaveRefreshToken")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) error); ok {
+		r0 = rf(ctx, clientName, refreshToken)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// MockRepository_SaveRefreshToken_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'SaveRefreshToken'
+type MockRepository_SaveRefreshToken_Call struct {
+	*mock.Call
+}
+
+// SaveRefreshToken is a helper method to define mock.On call
+//   - ctx context.Context
+//   - clientName string
+//   - refreshToken string
+func (_e *MockRepository_Expecter) SaveRefreshToken(ctx interface{}, clientName interface{}, refreshToken interface{}) *MockRepository_SaveRefreshToken_Call {
+	return &MockRepository_SaveRefreshToken_Call{Call: _e.mock.On("SaveRefreshToken", ctx, clientName, refreshToken)}
+}
+
+func (_c *MockRepository_SaveRefreshToken_Call) Run(run func(ctx context.Context, clientName string, refreshToken string)) *MockRepository_SaveRefreshToken_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(string), args[2].(string))
+	})
+	return _c
+}
+
+func (_c *MockRepository_SaveRefreshToken_Call) Return(_a0 error) *MockRepository_SaveRefreshToken_Call {
+	_c.Call.Return(_a0)
+	return _c
+}
+
+func (_c *MockRepository_SaveRefreshToken_Call) RunAndReturn(run func(context.Context, string, string) error) *MockRepository_SaveRefreshToken_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// Update provides a mock function with given fields: ctx, client
+func (_m *MockRepository) Update(ctx context.Context, client Client) error {
+	ret := _m.Called(ctx, client)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Update")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, Client) error); ok {
+		r0 = rf(ctx, client)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// MockRepository_Update_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Update'
+type MockRepository_Update_Call struct {
+	*mock.Call
+}
+
+// Update is a helper method to define mock.On call
+//   - ctx context.Context
+//   - client Client
+func (_e *MockRepository_Expecter) Update(ctx interface{}, client interface{}) *MockRepository_Update_Call {
+	return &MockRepository_Update_Call{Call: _e.mock.On("Update", ctx, client)}
+}
+
+func (_c *MockRepository_Update_Call) Run(run func(ctx context.Context, client Client)) *MockRepository_Update_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(Client))
+	})
+	return _c
+}
+
+func (_c *MockRepository_Update_Call) Return(_a0 error) *MockRepository_Update_Call {
+	_c.Call.Return(_a0)
+	return _c
+}
+
+func (_c *MockRepository_Update_Call) RunAndReturn(run func(context.Context, Client) error) *MockRepository_Update_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// UpdateEndpoints provides a mock function with given fields: ctx, client
+func (_m *MockRepository) UpdateEndpoints(ctx context.Context, client Client) error {
+	ret := _m.Called(ctx, client)
+
+	if len(ret) == 0 {
+		panic("no return value specified for UpdateEndpoints")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, Client) error); ok {
+		r0 = rf(ctx, client)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// MockRepository_UpdateEndpoints_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'UpdateEndpoints'
+type MockRepository_UpdateEndpoints_Call struct {
+	*mock.Call
+}
+
+// UpdateEndpoints is a helper method to define mock.On call
+//   - ctx context.Context
+//   - client Client
+func (_e *MockRepository_Expecter) UpdateEndpoints(ctx interface{}, client interface{}) *MockRepository_UpdateEndpoints_Call {
+	return &MockRepository_UpdateEndpoints_Call{Call: _e.mock.On("UpdateEndpoints", ctx, client)}
+}
+
+func (_c *MockRepository_UpdateEndpoints_Call) Run(run func(ctx context.Context, client Client)) *MockRepository_UpdateEndpoints_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(Client))
+	})
+	return _c
+}
+
+func (_c *MockRepository_UpdateEndpoints_Call) Return(_a0 error) *MockRepository_UpdateEndpoints_Call {
+	_c.Call.Return(_a0)
+	return _c
+}
+
+func (_c *MockRepository_UpdateEndpoints_Call) RunAndReturn(run func(context.Context, Client) error) *MockRepository_UpdateEndpoints_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
 // NewMockRepository creates a new instance of MockRepository. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
 // The first argument is typically a *testing.T value.
 func NewMockRepository(t interface {
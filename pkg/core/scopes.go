@@ -0,0 +1,63 @@
+package core
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// requestedScopes returns the scopes to request: the client's stored scopes, or those given with the request,
+// with the added ones appended and the removed ones dropped. Removing a scope that is not requested is
+// an error, a mistyped name would silently request more than intended.
+func requestedScopes(stored []string, opts TokenOptions) ([]string, error) {
+	scopes := stored
+	if opts.Scopes != nil {
+		scopes = opts.Scopes
+	}
+
+	if len(opts.AddScopes) == 0 && len(opts.RemoveScopes) == 0 {
+		return scopes, nil
+	}
+
+	scopes = slices.Clone(scopes)
+
+	for _, scope := range opts.AddScopes {
+		if !slices.Contains(scopes, scope) {
+			scopes = append(scopes, scope)
+		}
+	}
+
+	for _, scope := range opts.RemoveScopes {
+		if !slices.Contains(scopes, scope) {
+			return nil, fmt.Errorf("scope %q is not requested, it cannot be removed", scope)
+		}
+
+		scopes = slices.DeleteFunc(scopes, func(s string) bool { return s == scope })
+	}
+
+	return scopes, nil
+}
+
+// ScopeMismatch compares the granted scope with the requested scopes. Servers omit the scope when they grant
+// the requested one (RFC 6749 section 5.1), and choose their default when none is requested, so both count as a match.
+func (t Token) ScopeMismatch() (missing, extra []string) {
+	if t.Scope == "" || len(t.RequestedScopes) == 0 {
+		return nil, nil
+	}
+
+	granted := strings.Fields(t.Scope)
+
+	for _, scope := range t.RequestedScopes {
+		if !slices.Contains(granted, scope) {
+			missing = append(missing, scope)
+		}
+	}
+
+	for _, scope := range granted {
+		if !slices.Contains(t.RequestedScopes, scope) && !slices.Contains(extra, scope) {
+			extra = append(extra, scope)
+		}
+	}
+
+	return missing, extra
+}
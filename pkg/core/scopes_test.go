@@ -0,0 +1,69 @@
+package core
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequestedScopes(t *testing.T) {
+	stored := []string{"read", "write"}
+
+	scopes, err := requestedScopes(stored, TokenOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"read", "write"}, scopes)
+
+	scopes, err = requestedScopes(stored, TokenOptions{Scopes: []string{"admin"}, AddScopes: []string{"read", "admin"}})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"admin", "read"}, scopes)
+
+	scopes, err = requestedScopes(stored, TokenOptions{RemoveScopes: []string{"read", "write"}})
+	require.NoError(t, err)
+	assert.Empty(t, scopes)
+	assert.Equal(t, []string{"read", "write"}, stored, "stored scopes must not change")
+
+	_, err = requestedScopes(stored, TokenOptions{RemoveScopes: []string{"admin"}})
+	assert.ErrorContains(t, err, `scope "admin" is not requested`)
+}
+
+func TestToken_ScopeMismatch(t *testing.T) {
+	tests := []struct {
+		name        string
+		token       Token
+		wantMissing []string
+		wantExtra   []string
+	}{
+		{
+			name:  "granted as requested",
+			token: Token{Scope: "write read", RequestedScopes: []string{"read", "write"}},
+		},
+		{
+			name:  "scope omitted in the response",
+			token: Token{RequestedScopes: []string{"read"}},
+		},
+		{
+			name:  "server default scopes",
+			token: Token{Scope: "read write"},
+		},
+		{
+			name:        "down-scoped by the server",
+			token:       Token{Scope: "read", RequestedScopes: []string{"read", "write"}},
+			wantMissing: []string{"write"},
+		},
+		{
+			name:        "different scopes granted",
+			token:       Token{Scope: "read admin", RequestedScopes: []string{"read", "write"}},
+			wantMissing: []string{"write"},
+			wantExtra:   []string{"admin"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			missing, extra := tt.token.ScopeMismatch()
+			assert.Equal(t, tt.wantMissing, missing)
+			assert.Equal(t, tt.wantExtra, extra)
+		})
+	}
+}
@@ -2,39 +2,178 @@ package core
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"slices"
+	"strings"
+	"time"
 )
 
+// DefaultRefreshSkew is how long before expiry a cached token is considered stale
+const DefaultRefreshSkew = 30 * time.Second
+
+// endpointsTTL is how long endpoints resolved from an issuer are used before its metadata is fetched again
+const endpointsTTL = 15 * time.Minute
+
 // Service implements the core business logic for managing OIDC clients
 type Service struct {
-	repo Repository
-	prov Provider
+	repo        Repository
+	prov        Provider
+	refreshSkew time.Duration
+	now         func() time.Time
+}
+
+// Option configures a Service
+type Option func(*Service)
+
+// WithRefreshSkew sets how long before expiry a cached token is refreshed
+func WithRefreshSkew(skew time.Duration) Option {
+	return func(s *Service) {
+		s.refreshSkew = skew
+	}
 }
 
 // NewService creates a new core service
-func NewService(repo Repository, prov Provider) *Service {
-	return &Service{
-		repo: repo,
-		prov: prov,
+func NewService(repo Repository, prov Provider, opts ...Option) *Service {
+	s := &Service{
+		repo:        repo,
+		prov:        prov,
+		refreshSkew: DefaultRefreshSkew,
+		now:         time.Now,
+	}
+
+	for _, opt := range opts {
+		opt(s)
 	}
+
+	return s
 }
 
-// AddClient adds a new OIDC client to the repository
+// AddClient adds a new OIDC client to the repository.
+// Clients with an issuer get missing endpoints from the issuer's metadata, unless the caller
+// already resolved them with DiscoverIssuer and set EndpointsResolvedAt.
 func (s *Service) AddClient(ctx context.Context, client Client) error {
+	needsAuthorizationURL := client.Grant() == GrantAuthorizationCode && client.AuthorizationURL == ""
+	needsDeviceURL := client.Grant() == GrantDeviceCode && client.DeviceAuthorizationURL == ""
+	needsEndpoints := client.TokenURL == "" || needsAuthorizationURL || needsDeviceURL
+	if client.Issuer != "" && client.EndpointsResolvedAt.IsZero() && needsEndpoints {
+		metadata, err := s.prov.Discover(ctx, client.Issuer, client.HTTP)
+		if err != nil {
+			return fmt.Errorf("failed to discover issuer: %w", err)
+		}
+
+		if client.TokenURL == "" {
+			client.TokenURL = metadata.TokenEndpoint
+		}
+		if client.AuthorizationURL == "" {
+			client.AuthorizationURL = metadata.AuthorizationEndpoint
+		}
+		if client.DeviceAuthorizationURL == "" {
+			client.DeviceAuthorizationURL = metadata.DeviceAuthorizationEndpoint
+		}
+
+		client.EndpointsResolvedAt = s.now()
+	}
+
+	if err := ValidateClient(client); err != nil {
+		return err
+	}
+
+	return s.repo.Save(ctx, client)
+}
+
+// DiscoverIssuer fetches the authorization server metadata for an issuer, connecting with the
+// HTTP settings of the client being set up
+func (s *Service) DiscoverIssuer(ctx context.Context, issuer string, settings HTTPSettings) (*ServerMetadata, error) {
+	if issuer == "" {
+		return nil, fmt.Errorf("issuer is required")
+	}
+
+	return s.prov.Discover(ctx, issuer, settings)
+}
+
+// UpdateClient modifies an existing OIDC client in the repository
+func (s *Service) UpdateClient(ctx context.Context, client Client) error {
+	if err := ValidateClient(client); err != nil {
+		return err
+	}
+
+	return s.repo.Update(ctx, client)
+}
+
+// ValidateClient checks that all required client fields are set, so a client can be checked before it is stored
+func ValidateClient(client Client) error {
 	if client.Name == "" {
 		return fmt.Errorf("client name is required")
 	}
 	if client.ClientID == "" {
 		return fmt.Errorf("client ID is required")
 	}
-	if client.ClientSecret == "" {
+	if _, err := ParseAuthMethod(string(client.TokenEndpointAuthMethod)); err != nil {
+		return err
+	}
+	if client.ClientSecret == "" && client.RequiresSecret() {
 		return fmt.Errorf("client secret is required")
 	}
+	if client.PrivateKey == "" && client.AuthMethod() == AuthMethodPrivateKeyJWT {
+		return fmt.Errorf("private key is required for %s", AuthMethodPrivateKeyJWT)
+	}
+	if client.TLSCertificate == "" && client.UsesMutualTLS() {
+		return fmt.Errorf("client certificate is required for %s", client.AuthMethod())
+	}
+	if (client.TLSCertificate == "") != (client.TLSKey == "") {
+		return fmt.Errorf("client certificate and key must be set together")
+	}
+	if (client.Username == "") != (client.Password == "") {
+		return fmt.Errorf("username and password must be set together")
+	}
 	if client.TokenURL == "" {
 		return fmt.Errorf("token URL is required")
 	}
+	if _, err := ParseGrantType(string(client.GrantType)); err != nil {
+		return err
+	}
+	if err := checkGrantEndpoints(client, client.Grant()); err != nil {
+		return err
+	}
+	if client.RedirectURL != "" {
+		if _, err := ParseRedirectURL(client.RedirectURL); err != nil {
+			return err
+		}
+	}
+	if err := validateTarget(client); err != nil {
+		return err
+	}
+	if err := client.HTTP.Validate(); err != nil {
+		return err
+	}
+	if client.HTTP.InsecureSkipVerify {
+		for _, endpoint := range []string{client.Issuer, client.TokenURL, client.DeviceAuthorizationURL} {
+			if endpoint == "" {
+				continue
+			}
 
-	return s.repo.Save(ctx, client)
+			if err := checkInsecureURL(endpoint); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// checkGrantEndpoints checks that the client has the endpoints or key a grant needs besides the token URL
+func checkGrantEndpoints(client Client, grant GrantType) error {
+	switch {
+	case grant == GrantJWTBearer && client.PrivateKey == "":
+		return fmt.Errorf("private key is required for %s", GrantJWTBearer)
+	case grant == GrantAuthorizationCode && client.AuthorizationURL == "":
+		return fmt.Errorf("authorization URL is required for %s", GrantAuthorizationCode)
+	case grant == GrantDeviceCode && client.DeviceAuthorizationURL == "":
+		return fmt.Errorf("device authorization URL is required for %s", GrantDeviceCode)
+	default:
+		return nil
+	}
 }
 
 // GetClient retrieves a client by name
@@ -57,21 +196,276 @@ func (s *Service) DeleteClient(ctx context.Context, name string) error {
 	return s.repo.Delete(ctx, name)
 }
 
-// IssueToken obtains an access token for the specified client
-func (s *Service) IssueToken(ctx context.Context, clientName string) (*Token, error) {
+// IssueToken obtains an access token for the specified client.
+// A cached token is returned while it is still valid for longer than the refresh skew.
+func (s *Service) IssueToken(ctx context.Context, clientName string, opts TokenOptions) (*Token, error) {
 	client, err := s.repo.Get(ctx, clientName)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get client: %w", err)
 	}
 
-	token, err := s.prov.GetToken(ctx, *client)
+	grant := client.Grant()
+	if opts.Grant != "" {
+		grant = opts.Grant
+	}
+
+	target := withTokenOverrides(*client, opts)
+	if err := validateTarget(target); err != nil {
+		return nil, err
+	}
+
+	scopes, err := requestedScopes(client.Scopes, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	key := tokenCacheKey(client.Name, grant, scopes) + targetCacheKey(target)
+
+	var username, password string
+	if grant == GrantPassword {
+		if username, password, err = resourceOwner(ctx, client, opts); err != nil {
+			return nil, err
+		}
+
+		// Tokens belong to the resource owner, so users entered at the prompt don't share cached tokens
+		key += "|" + username
+	}
+
+	if !opts.NoCache && !opts.Refresh {
+		// Cache failures are not fatal, the token is simply requested again
+		cached, err := s.repo.GetCachedToken(ctx, client.Name, key)
+		if err == nil && cached != nil {
+			if remaining := s.remaining(cached); remaining > s.refreshSkew {
+				cached.ExpiresIn = int(remaining.Seconds())
+				cached.RequestedScopes = scopes
+
+				return cached, nil
+			}
+		}
+	}
+
+	s.resolveEndpoints(ctx, client)
+
+	if err := checkGrantEndpoints(*client, grant); err != nil {
+		return nil, err
+	}
+
+	// Set only after resolveEndpoints, which may store the client, so prompted credentials and
+	// per-request overrides never reach the vault
+	if grant == GrantPassword {
+		client.Username, client.Password = username, password
+	}
+
+	client.Scopes = scopes
+	client.Audience, client.Resource = target.Audience, target.Resource
+	client.ExtraParams, client.ExtraHeaders = target.ExtraParams, target.ExtraHeaders
+
+	issuedAt := s.now()
+
+	token, err := s.obtainToken(ctx, client, grant, opts)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get token: %w", err)
 	}
 
+	if token.IssuedAt.IsZero() {
+		token.IssuedAt = issuedAt
+	}
+
+	if !opts.NoCache && token.ExpiresIn > 0 {
+		// Caching is best effort, a token that could not be stored is still valid
+		_ = s.repo.SaveCachedToken(ctx, client.Name, key, *token)
+	}
+
+	token.RequestedScopes = scopes
+
+	return token, nil
+}
+
+// ExchangeToken trades a subject token for a new token with the client (RFC 8693). Exchanged tokens
+// depend on the subject token, so they are never cached. Token types default to access tokens.
+func (s *Service) ExchangeToken(ctx context.Context, clientName string, exchange TokenExchange) (*Token, error) {
+	if exchange.SubjectToken == "" {
+		return nil, fmt.Errorf("subject token is required")
+	}
+
+	if exchange.SubjectTokenType == "" {
+		exchange.SubjectTokenType = TokenTypeAccessToken
+	}
+
+	if exchange.ActorToken != "" && exchange.ActorTokenType == "" {
+		exchange.ActorTokenType = TokenTypeAccessToken
+	}
+
+	client, err := s.repo.Get(ctx, clientName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get client: %w", err)
+	}
+
+	s.resolveEndpoints(ctx, client)
+
+	issuedAt := s.now()
+
+	token, err := s.prov.GetToken(ctx, *client, TokenRequest{Grant: GrantTokenExchange, Exchange: exchange})
+	if err != nil {
+		return nil, fmt.Errorf("failed to exchange token: %w", err)
+	}
+
+	if token.IssuedAt.IsZero() {
+		token.IssuedAt = issuedAt
+	}
+
 	return token, nil
 }
 
+// obtainToken requests a token with the grant. For interactive grants the client's stored refresh token is
+// redeemed first, so the user only signs in again when there is none or the server rejects it with invalid_grant.
+// The refresh token is kept per client, a request wider than it was issued for is refused with invalid_scope or
+// invalid_target and the user signs in for the wider request, keeping the refresh token for it.
+// Refresh tokens are kept in the vault like cached tokens, so NoCache skips them too.
+func (s *Service) obtainToken(ctx context.Context, client *Client, grant GrantType, opts TokenOptions) (*Token, error) {
+	req := TokenRequest{Grant: grant, Interactor: opts.Interactor, Username: client.Username, Password: client.Password}
+
+	if !grant.Interactive() || opts.NoCache {
+		return s.prov.GetToken(ctx, *client, req)
+	}
+
+	// A refresh token that cannot be read is treated as missing, the user signs in again
+	refreshToken, _ := s.repo.GetRefreshToken(ctx, client.Name)
+	if refreshToken != "" {
+		token, err := s.prov.GetToken(ctx, *client, TokenRequest{Grant: GrantRefreshToken, RefreshToken: refreshToken})
+
+		switch {
+		case err == nil:
+			// Servers that don't rotate refresh tokens keep the current one valid (RFC 6749 section 6)
+			if token.RefreshToken == "" {
+				token.RefreshToken = refreshToken
+			}
+
+			if token.RefreshToken != refreshToken {
+				_ = s.repo.SaveRefreshToken(ctx, client.Name, token.RefreshToken)
+			}
+
+			return token, nil
+		case errors.Is(err, ErrInvalidGrant):
+			_ = s.repo.SaveRefreshToken(ctx, client.Name, "")
+		case isRejectedTarget(err):
+		default:
+			return nil, err
+		}
+	}
+
+	token, err := s.prov.GetToken(ctx, *client, req)
+	if err != nil {
+		return nil, err
+	}
+
+	if token.RefreshToken != "" {
+		_ = s.repo.SaveRefreshToken(ctx, client.Name, token.RefreshToken)
+	}
+
+	return token, nil
+}
+
+// resourceOwner returns the username and password for the password grant, asking the user for them
+// when the client has none stored or PromptUser is set
+func resourceOwner(ctx context.Context, client *Client, opts TokenOptions) (username, password string, err error) {
+	username, password = client.Username, client.Password
+
+	if opts.PromptUser || username == "" {
+		if opts.Interactor == nil {
+			return "", "", fmt.Errorf("username and password are required for %s", GrantPassword)
+		}
+
+		if username, password, err = opts.Interactor.Credentials(ctx, client.Name); err != nil {
+			return "", "", fmt.Errorf("failed to read user credentials: %w", err)
+		}
+	}
+
+	if username == "" || password == "" {
+		return "", "", fmt.Errorf("username and password are required for %s", GrantPassword)
+	}
+
+	return username, password, nil
+}
+
+// resolveEndpoints re-reads the client's endpoints from the issuer's metadata, so a moved endpoint doesn't
+// break stored clients. The endpoints are stored with the time they were resolved, so the metadata is fetched
+// at most once per endpointsTTL across runs. Discovery is best effort, the stored endpoints are used when
+// the issuer cannot be reached.
+func (s *Service) resolveEndpoints(ctx context.Context, client *Client) {
+	if client.Issuer == "" || s.now().Sub(client.EndpointsResolvedAt) < endpointsTTL {
+		return
+	}
+
+	metadata, err := s.prov.Discover(ctx, client.Issuer, client.HTTP)
+	if err != nil {
+		return
+	}
+
+	if metadata.TokenEndpoint != "" {
+		client.TokenURL = metadata.TokenEndpoint
+	}
+
+	if metadata.AuthorizationEndpoint != "" {
+		client.AuthorizationURL = metadata.AuthorizationEndpoint
+	}
+
+	if metadata.DeviceAuthorizationEndpoint != "" {
+		client.DeviceAuthorizationURL = metadata.DeviceAuthorizationEndpoint
+	}
+
+	client.EndpointsResolvedAt = s.now()
+
+	// Only the endpoints are written, a full update would drop the refresh token about to be redeemed
+	_ = s.repo.UpdateEndpoints(ctx, *client)
+}
+
+// remaining returns how long the token stays valid, zero for tokens without a known lifetime
+func (s *Service) remaining(token *Token) time.Duration {
+	if token.ExpiresIn <= 0 || token.IssuedAt.IsZero() {
+		return 0
+	}
+
+	expiresAt := token.IssuedAt.Add(time.Duration(token.ExpiresIn) * time.Second)
+
+	return expiresAt.Sub(s.now())
+}
+
+// tokenCacheKey identifies a cached token by client name, grant and requested scopes, independent of scope order.
+// The grant is part of the key so a user token is never returned where a client token was asked for.
+func tokenCacheKey(clientName string, grant GrantType, scopes []string) string {
+	sorted := slices.Clone(scopes)
+	slices.Sort(sorted)
+
+	return clientName + "|" + string(grant) + "|" + strings.Join(slices.Compact(sorted), " ")
+}
+
+// ChangePassword re-encrypts the repository with a new master password
+func (s *Service) ChangePassword(ctx context.Context, oldPassword, newPassword string) error {
+	if newPassword == "" {
+		return fmt.Errorf("new password is required")
+	}
+	if newPassword == oldPassword {
+		return fmt.Errorf("new password must differ from the current one")
+	}
+
+	return s.repo.ChangePassword(ctx, oldPassword, newPassword)
+}
+
+// ListBackups returns the available repository backups, newest first
+func (s *Service) ListBackups(ctx context.Context) ([]Backup, error) {
+	return s.repo.ListBackups(ctx)
+}
+
+// RestoreBackup restores the repository from the backup with the given index
+func (s *Service) RestoreBackup(ctx context.Context, index int) error {
+	if index < 1 {
+		return fmt.Errorf("backup index must be a positive number")
+	}
+
+	return s.repo.RestoreBackup(ctx, index)
+}
+
 // IsRepositoryInitialized checks if the repository is initialized
 func (s *Service) IsRepositoryInitialized() bool {
 	return s.repo.Exists()
@@ -0,0 +1,257 @@
+package prov
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/ksysoev/authkeeper/pkg/core"
+)
+
+// authorizationTimeout bounds how long the loopback listener waits for the user to sign in
+const authorizationTimeout = 5 * time.Minute
+
+// callbackPage is shown in the browser once the authorization response was received
+const callbackPage = `<!DOCTYPE html>
+<html><head><title>AuthKeeper</title></head>
+<body><p>%s</p><p>You can close this window and return to the terminal.</p></body></html>
+`
+
+// callbackResult is the outcome of the authorization response received by the loopback listener
+type callbackResult struct {
+	code string
+	err  error
+}
+
+// authorizationCode runs the authorization code grant with PKCE (RFC 7636). The user signs in with a
+// browser and is redirected to an ephemeral loopback listener (RFC 8252) which receives the code.
+func (p *OAuthProvider) authorizationCode(ctx context.Context, client core.Client, interactor core.Interactor) (*core.Token, error) {
+	if interactor == nil {
+		return nil, fmt.Errorf("%s grant requires an interactive session", core.GrantAuthorizationCode)
+	}
+
+	redirect, err := core.ParseRedirectURL(client.RedirectURL)
+	if err != nil {
+		return nil, err
+	}
+
+	verifier, err := randomToken(32)
+	if err != nil {
+		return nil, err
+	}
+
+	state, err := randomToken(16)
+	if err != nil {
+		return nil, err
+	}
+
+	listeners, err := listenLoopback(redirect)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, authorizationTimeout)
+	defer cancel()
+
+	results := make(chan callbackResult, 1)
+	server := &http.Server{
+		Handler:           callbackHandler(redirect.Path, state, results),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	for _, listener := range listeners {
+		go func() { _ = server.Serve(listener) }()
+	}
+	defer func() { _ = server.Close() }()
+
+	authURL, err := authorizationURL(client, redirect.String(), state, pkceChallenge(verifier))
+	if err != nil {
+		return nil, err
+	}
+
+	if err := interactor.Authorize(ctx, authURL); err != nil {
+		return nil, err
+	}
+
+	var code string
+
+	select {
+	case <-ctx.Done():
+		return nil, fmt.Errorf("authorization was not completed: %w", ctx.Err())
+	case result := <-results:
+		if result.err != nil {
+			return nil, result.err
+		}
+
+		code = result.code
+	}
+
+	data := url.Values{}
+	data.Set("grant_type", string(core.GrantAuthorizationCode))
+	data.Set("code", code)
+	data.Set("redirect_uri", redirect.String())
+	data.Set("code_verifier", verifier)
+
+	return p.requestToken(ctx, client, data)
+}
+
+// listenLoopback starts listening on the redirect URL's loopback address. Without a port in the
+// redirect URL an ephemeral port is used and written back into the redirect URL. A localhost redirect
+// is served on 127.0.0.1 and ::1 on the same port, browsers resolve the name to either.
+func listenLoopback(redirect *url.URL) ([]net.Listener, error) {
+	port := redirect.Port()
+	if port == "" {
+		port = "0"
+	}
+
+	var listeners []net.Listener
+	var err error
+
+	if redirect.Hostname() == "localhost" {
+		// An ephemeral port free on 127.0.0.1 can be taken on ::1, another one is picked then
+		for range 3 {
+			if listeners, err = listenLocalhost(port); err == nil || port != "0" {
+				break
+			}
+		}
+	} else {
+		var listener net.Listener
+		if listener, err = net.Listen("tcp", net.JoinHostPort(redirect.Hostname(), port)); err == nil {
+			listeners = []net.Listener{listener}
+		}
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to start loopback listener: %w", err)
+	}
+
+	_, actualPort, _ := net.SplitHostPort(listeners[0].Addr().String())
+	redirect.Host = net.JoinHostPort(redirect.Hostname(), actualPort)
+
+	return listeners, nil
+}
+
+// listenLocalhost listens on the IP literals localhost resolves to, so the name never reaches an
+// interface we don't listen on. ::1 is skipped on hosts without IPv6.
+func listenLocalhost(port string) ([]net.Listener, error) {
+	v4, err := net.Listen("tcp", net.JoinHostPort("127.0.0.1", port))
+	if err != nil {
+		return nil, err
+	}
+
+	_, port, _ = net.SplitHostPort(v4.Addr().String())
+
+	v6, err := net.Listen("tcp", net.JoinHostPort("::1", port))
+
+	switch {
+	case err == nil:
+		return []net.Listener{v4, v6}, nil
+	case errors.Is(err, syscall.EADDRINUSE):
+		// Another process would receive the callbacks of browsers resolving localhost to ::1
+		_ = v4.Close()
+		return nil, err
+	default:
+		return []net.Listener{v4}, nil
+	}
+}
+
+// callbackHandler receives the authorization response on the redirect path, validates the state and
+// reports the first response to results. Requests without the expected state are refused and the
+// handler keeps waiting, a stray local request must not end the sign in.
+func callbackHandler(path, state string, results chan<- callbackResult) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != path {
+			http.NotFound(w, r)
+			return
+		}
+
+		query := r.URL.Query()
+
+		if query.Get("state") != state {
+			http.Error(w, "authorization response has an invalid state", http.StatusBadRequest)
+			return
+		}
+
+		var result callbackResult
+
+		switch {
+		case query.Get("error") != "" && query.Get("error_description") != "":
+			result.err = fmt.Errorf("authorization failed: %s: %s", query.Get("error"), query.Get("error_description"))
+		case query.Get("error") != "":
+			result.err = fmt.Errorf("authorization failed: %s", query.Get("error"))
+		case query.Get("code") == "":
+			result.err = errors.New("authorization response has no code")
+		default:
+			result.code = query.Get("code")
+		}
+
+		message := "Authorization complete."
+		status := http.StatusOK
+
+		if result.err != nil {
+			message = "Authorization failed."
+			status = http.StatusBadRequest
+		}
+
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.WriteHeader(status)
+		_, _ = fmt.Fprintf(w, callbackPage, message)
+
+		select {
+		case results <- result:
+		default:
+		}
+	})
+}
+
+// authorizationURL adds the authorization request parameters to the client's authorization endpoint
+func authorizationURL(client core.Client, redirectURL, state, challenge string) (string, error) {
+	u, err := url.Parse(client.AuthorizationURL)
+	if err != nil {
+		return "", fmt.Errorf("invalid authorization URL: %w", err)
+	}
+
+	query := u.Query()
+	query.Set("response_type", "code")
+	query.Set("client_id", client.ClientID)
+	query.Set("redirect_uri", redirectURL)
+	query.Set("state", state)
+	query.Set("code_challenge", challenge)
+	query.Set("code_challenge_method", "S256")
+
+	if len(client.Scopes) > 0 {
+		query.Set("scope", strings.Join(client.Scopes, " "))
+	}
+
+	// Servers like Auth0 pick the API from the audience when the user signs in, not when the code is redeemed
+	addTarget(client, query)
+
+	u.RawQuery = query.Encode()
+
+	return u.String(), nil
+}
+
+// pkceChallenge derives the S256 code challenge from a code verifier
+func pkceChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// randomToken returns n random bytes encoded as unpadded base64url
+func randomToken(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate random value: %w", err)
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
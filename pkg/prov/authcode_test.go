@@ -0,0 +1,282 @@
+package prov
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/ksysoev/authkeeper/pkg/core"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeInteractor stands in for the UI in interactive grants
+type fakeInteractor struct {
+	authorize      func(ctx context.Context, authURL string) error
+	showDeviceCode func(ctx context.Context, auth core.DeviceAuthorization) error
+	credentials    func(ctx context.Context, clientName string) (string, string, error)
+}
+
+func (f fakeInteractor) Authorize(ctx context.Context, authURL string) error {
+	return f.authorize(ctx, authURL)
+}
+
+func (f fakeInteractor) ShowDeviceCode(ctx context.Context, auth core.DeviceAuthorization) error {
+	return f.showDeviceCode(ctx, auth)
+}
+
+func (f fakeInteractor) Credentials(ctx context.Context, clientName string) (string, string, error) {
+	return f.credentials(ctx, clientName)
+}
+
+// redirectBack plays the browser: it follows the authorization URL straight back to the redirect URI
+// with the given response parameters, state is echoed unless overridden
+func redirectBack(t *testing.T, params url.Values) core.Interactor {
+	t.Helper()
+
+	return fakeInteractor{authorize: func(ctx context.Context, authURL string) error {
+		u, err := url.Parse(authURL)
+		require.NoError(t, err)
+
+		query := u.Query()
+		if !params.Has("state") {
+			params.Set("state", query.Get("state"))
+		}
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, query.Get("redirect_uri")+"?"+params.Encode(), http.NoBody)
+		require.NoError(t, err)
+
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+
+		return resp.Body.Close()
+	}}
+}
+
+func TestOAuthProvider_GetToken_AuthorizationCode(t *testing.T) {
+	var authURL *url.URL
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, r.ParseForm())
+		assert.Equal(t, "authorization_code", r.FormValue("grant_type"))
+		assert.Equal(t, "the-code", r.FormValue("code"))
+		assert.Equal(t, "public-client", r.FormValue("client_id"))
+		assert.Empty(t, r.FormValue("client_secret"))
+
+		query := authURL.Query()
+		assert.Equal(t, query.Get("redirect_uri"), r.FormValue("redirect_uri"))
+		assert.Equal(t, query.Get("code_challenge"), pkceChallenge(r.FormValue("code_verifier")))
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"access_token": "user-token",
+			"token_type":   "Bearer",
+			"expires_in":   300,
+		})
+	}))
+	defer server.Close()
+
+	browser := redirectBack(t, url.Values{"code": {"the-code"}})
+	interactor := fakeInteractor{authorize: func(ctx context.Context, raw string) error {
+		var err error
+		authURL, err = url.Parse(raw)
+		require.NoError(t, err)
+
+		return browser.Authorize(ctx, raw)
+	}}
+
+	token, err := NewOAuthProvider().GetToken(context.Background(), core.Client{
+		ClientID:                "public-client",
+		TokenURL:                server.URL,
+		AuthorizationURL:        "https://idp.example.com/authorize?prompt=login",
+		Scopes:                  []string{"openid", "profile"},
+		Audience:                "https://api.example.com",
+		TokenEndpointAuthMethod: core.AuthMethodNone,
+	}, core.TokenRequest{Grant: core.GrantAuthorizationCode, Interactor: interactor})
+
+	require.NoError(t, err)
+	assert.Equal(t, "user-token", token.AccessToken)
+
+	query := authURL.Query()
+	assert.Equal(t, "idp.example.com", authURL.Host)
+	assert.Equal(t, "login", query.Get("prompt"), "existing query parameters must be kept")
+	assert.Equal(t, "code", query.Get("response_type"))
+	assert.Equal(t, "public-client", query.Get("client_id"))
+	assert.Equal(t, "openid profile", query.Get("scope"))
+	assert.Equal(t, "https://api.example.com", query.Get("audience"))
+	assert.Equal(t, "S256", query.Get("code_challenge_method"))
+	assert.NotEmpty(t, query.Get("state"))
+	assert.True(t, strings.HasPrefix(query.Get("redirect_uri"), "http://127.0.0.1:"))
+	assert.True(t, strings.HasSuffix(query.Get("redirect_uri"), "/callback"))
+}
+
+func TestOAuthProvider_GetToken_AuthorizationCode_Errors(t *testing.T) {
+	client := core.Client{
+		ClientID:         "public-client",
+		TokenURL:         "https://idp.example.com/token",
+		AuthorizationURL: "https://idp.example.com/authorize",
+	}
+
+	tests := []struct {
+		name        string
+		interactor  func(t *testing.T) core.Interactor
+		expectedErr string
+	}{
+		{
+			name:        "no interactive session",
+			interactor:  func(*testing.T) core.Interactor { return nil },
+			expectedErr: "requires an interactive session",
+		},
+		{
+			name: "access denied",
+			interactor: func(t *testing.T) core.Interactor {
+				return redirectBack(t, url.Values{"error": {"access_denied"}, "error_description": {"User cancelled"}})
+			},
+			expectedErr: "authorization failed: access_denied: User cancelled",
+		},
+		{
+			name: "missing code",
+			interactor: func(t *testing.T) core.Interactor {
+				return redirectBack(t, url.Values{})
+			},
+			expectedErr: "has no code",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token, err := NewOAuthProvider().GetToken(context.Background(), client, core.TokenRequest{
+				Grant:      core.GrantAuthorizationCode,
+				Interactor: tt.interactor(t),
+			})
+
+			assert.ErrorContains(t, err, tt.expectedErr)
+			assert.Nil(t, token)
+		})
+	}
+}
+
+func TestOAuthProvider_GetToken_AuthorizationCode_BadState(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, r.ParseForm())
+		assert.Equal(t, "the-code", r.FormValue("code"))
+
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"access_token":"user-token","token_type":"Bearer"}`))
+	}))
+	defer server.Close()
+
+	browser := redirectBack(t, url.Values{"code": {"the-code"}})
+	interactor := fakeInteractor{authorize: func(ctx context.Context, authURL string) error {
+		u, err := url.Parse(authURL)
+		require.NoError(t, err)
+
+		// A stray local request with a forged state arrives before the real callback
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.Query().Get("redirect_uri")+"?state=forged&code=forged-code", http.NoBody)
+		require.NoError(t, err)
+
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+		require.NoError(t, resp.Body.Close())
+
+		return browser.Authorize(ctx, authURL)
+	}}
+
+	token, err := NewOAuthProvider().GetToken(context.Background(), core.Client{
+		ClientID:                "public-client",
+		TokenURL:                server.URL,
+		AuthorizationURL:        "https://idp.example.com/authorize",
+		TokenEndpointAuthMethod: core.AuthMethodNone,
+	}, core.TokenRequest{Grant: core.GrantAuthorizationCode, Interactor: interactor})
+
+	require.NoError(t, err)
+	assert.Equal(t, "user-token", token.AccessToken)
+}
+
+func TestOAuthProvider_GetToken_AuthorizationCode_Cancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	// The user never returns from the browser
+	interactor := fakeInteractor{authorize: func(context.Context, string) error {
+		cancel()
+		return nil
+	}}
+
+	token, err := NewOAuthProvider().GetToken(ctx, core.Client{
+		ClientID:         "public-client",
+		TokenURL:         "https://idp.example.com/token",
+		AuthorizationURL: "https://idp.example.com/authorize",
+	}, core.TokenRequest{Grant: core.GrantAuthorizationCode, Interactor: interactor})
+
+	assert.ErrorContains(t, err, "authorization was not completed")
+	assert.Nil(t, token)
+}
+
+func TestOAuthProvider_GetToken_UnsupportedGrant(t *testing.T) {
+	token, err := NewOAuthProvider().GetToken(context.Background(), core.Client{}, core.TokenRequest{Grant: "implicit"})
+
+	assert.ErrorContains(t, err, `unsupported grant type "implicit"`)
+	assert.Nil(t, token)
+}
+
+func TestListenLoopback_Localhost(t *testing.T) {
+	redirect, err := url.Parse("http://localhost/callback")
+	require.NoError(t, err)
+
+	listeners, err := listenLoopback(redirect)
+	require.NoError(t, err)
+
+	for _, listener := range listeners {
+		t.Cleanup(func() { _ = listener.Close() })
+	}
+
+	assert.Equal(t, "localhost", redirect.Hostname(), "the registered redirect URL must be kept")
+	assert.NotEmpty(t, redirect.Port())
+
+	// Whichever address the browser resolves localhost to reaches the listener
+	for _, host := range []string{"127.0.0.1", "::1"} {
+		conn, err := net.Dial("tcp", net.JoinHostPort(host, redirect.Port()))
+		if host == "::1" && len(listeners) == 1 {
+			t.Log("IPv6 loopback is not available")
+			continue
+		}
+
+		require.NoError(t, err, host)
+		_ = conn.Close()
+	}
+}
+
+func TestCallbackHandler_IgnoresOtherPaths(t *testing.T) {
+	results := make(chan callbackResult, 1)
+	handler := callbackHandler("/callback", "state", results)
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/favicon.ico", http.NoBody))
+
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	assert.Empty(t, results)
+}
+
+func TestCallbackHandler_RefusesInvalidState(t *testing.T) {
+	results := make(chan callbackResult, 1)
+	handler := callbackHandler("/callback", "state", results)
+
+	for _, target := range []string{"/callback", "/callback?code=the-code", "/callback?code=the-code&state=forged"} {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, http.NoBody))
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code, target)
+		assert.Empty(t, results, "a request without the state must not end the sign in")
+	}
+}
+
+func TestPKCEChallenge(t *testing.T) {
+	// Test vector from RFC 7636 appendix B
+	assert.Equal(t, "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM", pkceChallenge("dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"))
+}
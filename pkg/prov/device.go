@@ -0,0 +1,147 @@
+package prov
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/ksysoev/authkeeper/pkg/core"
+)
+
+const (
+	// defaultPollInterval is used when the device authorization response has no interval (RFC 8628 section 3.2)
+	defaultPollInterval = 5
+	// slowDownIncrease is added to the polling interval on slow_down responses (RFC 8628 section 3.5)
+	slowDownIncrease = 5
+)
+
+// intervalUnit is the unit of the polling interval, shortened in tests
+var intervalUnit = time.Second
+
+// deviceAuthorizationResponse is the device authorization endpoint response (RFC 8628 section 3.2)
+type deviceAuthorizationResponse struct {
+	DeviceCode              string `json:"device_code"`
+	UserCode                string `json:"user_code"`
+	VerificationURI         string `json:"verification_uri"`
+	VerificationURIComplete string `json:"verification_uri_complete"`
+	ExpiresIn               int    `json:"expires_in"`
+	Interval                int    `json:"interval"`
+}
+
+// deviceCode runs the device authorization grant (RFC 8628). The user approves the request on another
+// device while the token endpoint is polled until the request is approved, denied or expires.
+func (p *OAuthProvider) deviceCode(ctx context.Context, client core.Client, interactor core.Interactor) (*core.Token, error) {
+	if interactor == nil {
+		return nil, fmt.Errorf("%s grant requires an interactive session", core.GrantDeviceCode)
+	}
+
+	auth, err := p.requestDeviceCode(ctx, client)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := interactor.ShowDeviceCode(ctx, core.DeviceAuthorization{
+		UserCode:                auth.UserCode,
+		VerificationURI:         auth.VerificationURI,
+		VerificationURIComplete: auth.VerificationURIComplete,
+		ExpiresIn:               auth.ExpiresIn,
+	}); err != nil {
+		return nil, err
+	}
+
+	if auth.ExpiresIn > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(auth.ExpiresIn)*intervalUnit)
+		defer cancel()
+	}
+
+	return p.pollDeviceToken(ctx, client, auth)
+}
+
+// requestDeviceCode obtains the device and user codes from the device authorization endpoint
+func (p *OAuthProvider) requestDeviceCode(ctx context.Context, client core.Client) (*deviceAuthorizationResponse, error) {
+	data := url.Values{}
+	if len(client.Scopes) > 0 {
+		data.Set("scope", strings.Join(client.Scopes, " "))
+	}
+
+	body, err := p.postForm(ctx, client, client.DeviceAuthorizationURL, data)
+	if err != nil {
+		return nil, fmt.Errorf("device authorization request failed: %w", err)
+	}
+
+	var auth deviceAuthorizationResponse
+	if err := json.Unmarshal(body, &auth); err != nil {
+		return nil, fmt.Errorf("failed to parse device authorization response: %w", err)
+	}
+
+	if auth.DeviceCode == "" || auth.UserCode == "" || auth.VerificationURI == "" {
+		return nil, fmt.Errorf("device authorization response is missing device_code, user_code or verification_uri")
+	}
+
+	if auth.Interval <= 0 {
+		auth.Interval = defaultPollInterval
+	}
+
+	return &auth, nil
+}
+
+// pollDeviceToken polls the token endpoint at the server's interval until the user has acted on the request
+func (p *OAuthProvider) pollDeviceToken(ctx context.Context, client core.Client, auth *deviceAuthorizationResponse) (*core.Token, error) {
+	interval := auth.Interval
+
+	for {
+		timer := time.NewTimer(time.Duration(interval) * intervalUnit)
+
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, pollingStopped(ctx)
+		case <-timer.C:
+		}
+
+		data := url.Values{}
+		data.Set("grant_type", core.GrantDeviceCode.Identifier())
+		data.Set("device_code", auth.DeviceCode)
+
+		token, err := p.requestToken(ctx, client, data)
+		if err == nil {
+			return token, nil
+		}
+
+		if ctx.Err() != nil {
+			return nil, pollingStopped(ctx)
+		}
+
+		var oauthErr *core.OAuthError
+		if !errors.As(err, &oauthErr) {
+			return nil, err
+		}
+
+		switch oauthErr.Code {
+		case "authorization_pending":
+		case "slow_down":
+			interval += slowDownIncrease
+		case "expired_token":
+			return nil, fmt.Errorf("device code expired before the request was approved")
+		case "access_denied":
+			return nil, fmt.Errorf("device authorization was denied")
+		default:
+			return nil, err
+		}
+	}
+}
+
+// pollingStopped explains why polling ended when its context is done, the device code
+// expiring is reported separately from the user cancelling
+func pollingStopped(ctx context.Context) error {
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("device code expired before the request was approved")
+	}
+
+	return fmt.Errorf("device authorization was cancelled: %w", ctx.Err())
+}
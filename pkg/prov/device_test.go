@@ -0,0 +1,201 @@
+package prov
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/ksysoev/authkeeper/pkg/core"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fastPolling shortens the device code polling interval for the duration of the test
+func fastPolling(t *testing.T) {
+	t.Helper()
+
+	unit := intervalUnit
+	intervalUnit = time.Millisecond
+
+	t.Cleanup(func() { intervalUnit = unit })
+}
+
+// newDeviceServer serves the device authorization endpoint at /device and answers token polls
+// with the given error codes in order, issuing a token once they are used up
+func newDeviceServer(t *testing.T, expiresIn int, pollErrors ...string) (*httptest.Server, *int) {
+	t.Helper()
+
+	polls := 0
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, r.ParseForm())
+		assert.Equal(t, "device-client", r.FormValue("client_id"))
+		w.Header().Set("Content-Type", "application/json")
+
+		if r.URL.Path == "/device" {
+			assert.Equal(t, "openid", r.FormValue("scope"))
+
+			_ = json.NewEncoder(w).Encode(map[string]any{
+				"device_code":               "the-device-code",
+				"user_code":                 "ABCD-EFGH",
+				"verification_uri":          "https://idp.example.com/device",
+				"verification_uri_complete": "https://idp.example.com/device?user_code=ABCD-EFGH",
+				"expires_in":                expiresIn,
+				"interval":                  1,
+			})
+
+			return
+		}
+
+		assert.Equal(t, "urn:ietf:params:oauth:grant-type:device_code", r.FormValue("grant_type"))
+		assert.Equal(t, "the-device-code", r.FormValue("device_code"))
+
+		polls++
+		if polls <= len(pollErrors) {
+			w.WriteHeader(http.StatusBadRequest)
+			_ = json.NewEncoder(w).Encode(map[string]string{"error": pollErrors[polls-1]})
+
+			return
+		}
+
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"access_token": "device-token",
+			"token_type":   "Bearer",
+			"expires_in":   3600,
+		})
+	}))
+
+	t.Cleanup(server.Close)
+
+	return server, &polls
+}
+
+func deviceClient(server *httptest.Server) core.Client {
+	return core.Client{
+		ClientID:                "device-client",
+		TokenURL:                server.URL + "/token",
+		DeviceAuthorizationURL:  server.URL + "/device",
+		Scopes:                  []string{"openid"},
+		TokenEndpointAuthMethod: core.AuthMethodNone,
+	}
+}
+
+func TestOAuthProvider_GetToken_DeviceCode(t *testing.T) {
+	fastPolling(t)
+
+	server, polls := newDeviceServer(t, 600, "authorization_pending", "slow_down", "authorization_pending")
+
+	var shown core.DeviceAuthorization
+	interactor := fakeInteractor{showDeviceCode: func(_ context.Context, auth core.DeviceAuthorization) error {
+		shown = auth
+		return nil
+	}}
+
+	token, err := NewOAuthProvider().GetToken(context.Background(), deviceClient(server), core.TokenRequest{
+		Grant:      core.GrantDeviceCode,
+		Interactor: interactor,
+	})
+
+	require.NoError(t, err)
+	assert.Equal(t, "device-token", token.AccessToken)
+	assert.Equal(t, 4, *polls)
+	assert.Equal(t, core.DeviceAuthorization{
+		UserCode:                "ABCD-EFGH",
+		VerificationURI:         "https://idp.example.com/device",
+		VerificationURIComplete: "https://idp.example.com/device?user_code=ABCD-EFGH",
+		ExpiresIn:               600,
+	}, shown)
+}
+
+func TestOAuthProvider_GetToken_DeviceCode_Errors(t *testing.T) {
+	tests := []struct {
+		name        string
+		expiresIn   int
+		pollErrors  []string
+		expectedErr string
+	}{
+		{
+			name:        "access denied",
+			expiresIn:   600,
+			pollErrors:  []string{"authorization_pending", "access_denied"},
+			expectedErr: "device authorization was denied",
+		},
+		{
+			name:        "expired token",
+			expiresIn:   600,
+			pollErrors:  []string{"expired_token"},
+			expectedErr: "device code expired",
+		},
+		{
+			name:        "expires while pending",
+			expiresIn:   20,
+			pollErrors:  slices.Repeat([]string{"authorization_pending"}, 1000),
+			expectedErr: "device code expired",
+		},
+		{
+			name:        "other error",
+			expiresIn:   600,
+			pollErrors:  []string{"invalid_client"},
+			expectedErr: "token request failed: invalid_client",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fastPolling(t)
+
+			server, _ := newDeviceServer(t, tt.expiresIn, tt.pollErrors...)
+			interactor := fakeInteractor{showDeviceCode: func(context.Context, core.DeviceAuthorization) error { return nil }}
+
+			token, err := NewOAuthProvider().GetToken(context.Background(), deviceClient(server), core.TokenRequest{
+				Grant:      core.GrantDeviceCode,
+				Interactor: interactor,
+			})
+
+			assert.ErrorContains(t, err, tt.expectedErr)
+			assert.Nil(t, token)
+		})
+	}
+}
+
+func TestOAuthProvider_GetToken_DeviceCode_Cancelled(t *testing.T) {
+	server, polls := newDeviceServer(t, 600)
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	// Ctrl-C while the user code is shown
+	interactor := fakeInteractor{showDeviceCode: func(context.Context, core.DeviceAuthorization) error {
+		cancel()
+		return nil
+	}}
+
+	token, err := NewOAuthProvider().GetToken(ctx, deviceClient(server), core.TokenRequest{
+		Grant:      core.GrantDeviceCode,
+		Interactor: interactor,
+	})
+
+	assert.ErrorContains(t, err, "device authorization was cancelled")
+	assert.Nil(t, token)
+	assert.Zero(t, *polls)
+}
+
+func TestOAuthProvider_GetToken_DeviceCode_AuthorizationFailed(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+		_, _ = w.Write([]byte(`{"error":"invalid_client"}`))
+	}))
+	defer server.Close()
+
+	token, err := NewOAuthProvider().GetToken(context.Background(), deviceClient(server), core.TokenRequest{
+		Grant:      core.GrantDeviceCode,
+		Interactor: fakeInteractor{},
+	})
+
+	assert.EqualError(t, err, "device authorization request failed: invalid_client")
+	assert.ErrorAs(t, err, new(*core.OAuthError))
+	assert.Nil(t, token)
+}
@@ -0,0 +1,109 @@
+package prov
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/ksysoev/authkeeper/pkg/core"
+)
+
+// serverMetadata is the subset of the discovery document used by authkeeper
+type serverMetadata struct {
+	Issuer                            string   `json:"issuer"`
+	TokenEndpoint                     string   `json:"token_endpoint"`
+	AuthorizationEndpoint             string   `json:"authorization_endpoint"`
+	DeviceAuthorizationEndpoint       string   `json:"device_authorization_endpoint"`
+	TokenEndpointAuthMethodsSupported []string `json:"token_endpoint_auth_methods_supported"`
+	GrantTypesSupported               []string `json:"grant_types_supported"`
+}
+
+// Discover fetches the issuer's OpenID Connect discovery document, falling back to
+// OAuth 2.0 Authorization Server Metadata (RFC 8414)
+func (p *OAuthProvider) Discover(ctx context.Context, issuer string, settings core.HTTPSettings) (*core.ServerMetadata, error) {
+	issuer = strings.TrimSuffix(issuer, "/")
+
+	urls, err := discoveryURLs(issuer)
+	if err != nil {
+		return nil, err
+	}
+
+	var errs []string
+
+	for _, u := range urls {
+		metadata, err := p.fetchMetadata(ctx, u, settings)
+		if err != nil {
+			errs = append(errs, err.Error())
+			continue
+		}
+
+		if strings.TrimSuffix(metadata.Issuer, "/") != issuer {
+			return nil, fmt.Errorf("issuer mismatch: metadata at %s is for %q", u, metadata.Issuer)
+		}
+
+		if metadata.TokenEndpoint == "" {
+			return nil, fmt.Errorf("metadata at %s has no token_endpoint", u)
+		}
+
+		result := core.ServerMetadata{
+			Issuer:                      metadata.Issuer,
+			TokenEndpoint:               metadata.TokenEndpoint,
+			AuthorizationEndpoint:       metadata.AuthorizationEndpoint,
+			DeviceAuthorizationEndpoint: metadata.DeviceAuthorizationEndpoint,
+			TokenEndpointAuthMethods:    metadata.TokenEndpointAuthMethodsSupported,
+			GrantTypes:                  metadata.GrantTypesSupported,
+		}
+
+		return &result, nil
+	}
+
+	return nil, fmt.Errorf("failed to discover issuer %s: %s", issuer, strings.Join(errs, "; "))
+}
+
+// discoveryURLs returns the OIDC discovery URL and the RFC 8414 metadata URL for an issuer
+func discoveryURLs(issuer string) ([]string, error) {
+	u, err := url.Parse(issuer)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return nil, fmt.Errorf("invalid issuer URL %q", issuer)
+	}
+
+	if u.RawQuery != "" || u.Fragment != "" {
+		return nil, fmt.Errorf("issuer URL %q must not contain a query or fragment", issuer)
+	}
+
+	// RFC 8414 inserts the well-known path between the host and the issuer's path
+	oauth := *u
+	oauth.Path = "/.well-known/oauth-authorization-server" + u.Path
+
+	return []string{issuer + "/.well-known/openid-configuration", oauth.String()}, nil
+}
+
+func (p *OAuthProvider) fetchMetadata(ctx context.Context, metadataURL string, settings core.HTTPSettings) (*serverMetadata, error) {
+	resp, body, err := p.send(ctx, core.Client{HTTP: settings}, true, func() (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, metadataURL, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create request: %w", err)
+		}
+
+		req.Header.Set("Accept", "application/json")
+
+		return req, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s returned status %d", metadataURL, resp.StatusCode)
+	}
+
+	var metadata serverMetadata
+	if err := json.Unmarshal(body, &metadata); err != nil {
+		return nil, fmt.Errorf("failed to parse metadata from %s: %w", metadataURL, err)
+	}
+
+	return &metadata, nil
+}
@@ -0,0 +1,117 @@
+package prov
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ksysoev/authkeeper/pkg/core"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOAuthProvider_Discover_OpenIDConfiguration(t *testing.T) {
+	requests := 0
+
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+
+		assert.Equal(t, "/.well-known/openid-configuration", r.URL.Path)
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"issuer":                                server.URL,
+			"token_endpoint":                        server.URL + "/oauth/token",
+			"authorization_endpoint":                server.URL + "/authorize",
+			"token_endpoint_auth_methods_supported": []string{"client_secret_basic", "private_key_jwt"},
+			"grant_types_supported":                 []string{"client_credentials", "authorization_code"},
+		})
+	}))
+	defer server.Close()
+
+	provider := NewOAuthProvider()
+
+	metadata, err := provider.Discover(context.Background(), server.URL+"/", core.HTTPSettings{})
+	require.NoError(t, err)
+
+	assert.Equal(t, server.URL, metadata.Issuer)
+	assert.Equal(t, server.URL+"/oauth/token", metadata.TokenEndpoint)
+	assert.Equal(t, server.URL+"/authorize", metadata.AuthorizationEndpoint)
+	assert.Equal(t, []string{"client_secret_basic", "private_key_jwt"}, metadata.TokenEndpointAuthMethods)
+	assert.Equal(t, []string{"client_credentials", "authorization_code"}, metadata.GrantTypes)
+	assert.Equal(t, 1, requests)
+}
+
+func TestOAuthProvider_Discover_FallsBackToOAuthMetadata(t *testing.T) {
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/.well-known/oauth-authorization-server/tenant" {
+			http.NotFound(w, r)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"issuer":         server.URL + "/tenant",
+			"token_endpoint": server.URL + "/tenant/token",
+		})
+	}))
+	defer server.Close()
+
+	metadata, err := NewOAuthProvider().Discover(context.Background(), server.URL+"/tenant", core.HTTPSettings{})
+	require.NoError(t, err)
+	assert.Equal(t, server.URL+"/tenant/token", metadata.TokenEndpoint)
+}
+
+func TestOAuthProvider_Discover_Errors(t *testing.T) {
+	tests := []struct {
+		name        string
+		metadata    map[string]any
+		status      int
+		expectedErr string
+	}{
+		{
+			name:        "issuer mismatch",
+			metadata:    map[string]any{"issuer": "https://evil.example.com", "token_endpoint": "https://evil.example.com/token"},
+			status:      http.StatusOK,
+			expectedErr: "issuer mismatch",
+		},
+		{
+			name:        "not found",
+			status:      http.StatusNotFound,
+			expectedErr: "returned status 404",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+				_ = json.NewEncoder(w).Encode(tt.metadata)
+			}))
+			defer server.Close()
+
+			metadata, err := NewOAuthProvider().Discover(context.Background(), server.URL, core.HTTPSettings{})
+			assert.ErrorContains(t, err, tt.expectedErr)
+			assert.Nil(t, metadata)
+		})
+	}
+}
+
+func TestDiscoveryURLs(t *testing.T) {
+	urls, err := discoveryURLs("https://idp.example.com/realms/test")
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"https://idp.example.com/realms/test/.well-known/openid-configuration",
+		"https://idp.example.com/.well-known/oauth-authorization-server/realms/test",
+	}, urls)
+
+	_, err = discoveryURLs("idp.example.com")
+	assert.ErrorContains(t, err, "invalid issuer URL")
+
+	_, err = discoveryURLs("https://idp.example.com?tenant=1")
+	assert.ErrorContains(t, err, "must not contain a query")
+}
@@ -0,0 +1,94 @@
+package prov
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/ksysoev/authkeeper/pkg/core"
+)
+
+// newOAuthError reads an error response (RFC 6749 section 5.2). Servers that reject client authentication
+// with 401 may only describe the error in the WWW-Authenticate header (RFC 6750 section 3), which is used
+// when the body has no error code. Other bodies, such as HTML error pages, are dropped.
+func newOAuthError(status int, header http.Header, body []byte) *core.OAuthError {
+	var resp struct {
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+		ErrorURI         string `json:"error_uri"`
+	}
+
+	if json.Unmarshal(body, &resp) != nil || resp.Error == "" {
+		for _, challenge := range header.Values("WWW-Authenticate") {
+			params := challengeParams(challenge)
+			if params["error"] != "" {
+				resp.Error, resp.ErrorDescription, resp.ErrorURI = params["error"], params["error_description"], params["error_uri"]
+				break
+			}
+		}
+	}
+
+	return &core.OAuthError{
+		StatusCode:  status,
+		Code:        resp.Error,
+		Description: resp.ErrorDescription,
+		URI:         resp.ErrorURI,
+	}
+}
+
+// challengeParams parses the auth-params of a WWW-Authenticate challenge such as
+// Bearer realm="example", error="invalid_token", error_description="The token expired"
+func challengeParams(challenge string) map[string]string {
+	params := map[string]string{}
+
+	// Skip the auth scheme
+	_, rest, found := strings.Cut(strings.TrimSpace(challenge), " ")
+	if !found {
+		return params
+	}
+
+	for {
+		rest = strings.TrimLeft(rest, " ,")
+		if rest == "" {
+			return params
+		}
+
+		name, value, found := strings.Cut(rest, "=")
+		if !found {
+			return params
+		}
+
+		name = strings.ToLower(strings.TrimSpace(name))
+		value = strings.TrimLeft(value, " ")
+
+		if strings.HasPrefix(value, `"`) {
+			value, rest = quotedString(value[1:])
+		} else {
+			value, rest, _ = strings.Cut(value, ",")
+			value = strings.TrimSpace(value)
+		}
+
+		params[name] = value
+	}
+}
+
+// quotedString reads a quoted-string up to its closing quote, s starts after the opening quote
+func quotedString(s string) (value, rest string) {
+	var b strings.Builder
+
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			if i+1 < len(s) {
+				i++
+				b.WriteByte(s[i])
+			}
+		case '"':
+			return b.String(), s[i+1:]
+		default:
+			b.WriteByte(s[i])
+		}
+	}
+
+	return b.String(), ""
+}
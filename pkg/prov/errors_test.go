@@ -0,0 +1,62 @@
+package prov
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/ksysoev/authkeeper/pkg/core"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewOAuthError(t *testing.T) {
+	tests := []struct {
+		name     string
+		status   int
+		header   http.Header
+		body     string
+		expected core.OAuthError
+	}{
+		{
+			name:   "error response",
+			status: http.StatusBadRequest,
+			body:   `{"error":"invalid_scope","error_description":"Unknown scope admin","error_uri":"https://idp.example.com/errors/scope"}`,
+			expected: core.OAuthError{
+				StatusCode:  http.StatusBadRequest,
+				Code:        "invalid_scope",
+				Description: "Unknown scope admin",
+				URI:         "https://idp.example.com/errors/scope",
+			},
+		},
+		{
+			name:   "WWW-Authenticate challenge",
+			status: http.StatusUnauthorized,
+			header: http.Header{"Www-Authenticate": {`Basic realm="idp"`, `Bearer realm="idp", error="invalid_client", error_description="Client \"app\" is disabled"`}},
+			body:   "Unauthorized",
+			expected: core.OAuthError{
+				StatusCode:  http.StatusUnauthorized,
+				Code:        "invalid_client",
+				Description: `Client "app" is disabled`,
+			},
+		},
+		{
+			name:     "HTML error page",
+			status:   http.StatusBadGateway,
+			body:     "<html><body>Bad Gateway</body></html>",
+			expected: core.OAuthError{StatusCode: http.StatusBadGateway},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := newOAuthError(tt.status, tt.header, []byte(tt.body))
+			assert.Equal(t, tt.expected, *err)
+			assert.NotContains(t, err.Error(), "<html>")
+		})
+	}
+}
+
+func TestChallengeParams(t *testing.T) {
+	assert.Equal(t, map[string]string{"realm": "example", "error": "invalid_token", "scope": "read"},
+		challengeParams(`Bearer realm="example",error=invalid_token, scope="read"`))
+	assert.Empty(t, challengeParams("Negotiate"))
+}
@@ -0,0 +1,41 @@
+package prov
+
+import (
+	"context"
+	"net/url"
+	"strings"
+
+	"github.com/ksysoev/authkeeper/pkg/core"
+)
+
+// tokenExchange trades the subject token for a new token (RFC 8693 section 2.1). Audience and resource
+// may be repeated to name several target services.
+func (p *OAuthProvider) tokenExchange(ctx context.Context, client core.Client, exchange core.TokenExchange) (*core.Token, error) {
+	data := url.Values{}
+	data.Set("grant_type", core.GrantTokenExchange.Identifier())
+	data.Set("subject_token", exchange.SubjectToken)
+	data.Set("subject_token_type", exchange.SubjectTokenType)
+
+	if exchange.ActorToken != "" {
+		data.Set("actor_token", exchange.ActorToken)
+		data.Set("actor_token_type", exchange.ActorTokenType)
+	}
+
+	for _, audience := range exchange.Audience {
+		data.Add("audience", audience)
+	}
+
+	for _, resource := range exchange.Resource {
+		data.Add("resource", resource)
+	}
+
+	if exchange.RequestedTokenType != "" {
+		data.Set("requested_token_type", exchange.RequestedTokenType)
+	}
+
+	if len(client.Scopes) > 0 {
+		data.Set("scope", strings.Join(client.Scopes, " "))
+	}
+
+	return p.requestToken(ctx, client, data)
+}
@@ -0,0 +1,99 @@
+package prov
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ksysoev/authkeeper/pkg/core"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOAuthProvider_GetToken_TokenExchange(t *testing.T) {
+	tests := []struct {
+		name           string
+		exchange       core.TokenExchange
+		expectedParams map[string][]string
+	}{
+		{
+			name: "impersonation",
+			exchange: core.TokenExchange{
+				SubjectToken:     "user-token",
+				SubjectTokenType: core.TokenTypeAccessToken,
+			},
+			expectedParams: map[string][]string{
+				"subject_token":      {"user-token"},
+				"subject_token_type": {core.TokenTypeAccessToken},
+			},
+		},
+		{
+			name: "delegation to several targets",
+			exchange: core.TokenExchange{
+				SubjectToken:       "user-token",
+				SubjectTokenType:   core.TokenTypeJWT,
+				ActorToken:         "service-token",
+				ActorTokenType:     core.TokenTypeAccessToken,
+				Audience:           []string{"orders", "billing"},
+				Resource:           []string{"https://api.example.com/orders"},
+				RequestedTokenType: core.TokenTypeJWT,
+			},
+			expectedParams: map[string][]string{
+				"subject_token":        {"user-token"},
+				"subject_token_type":   {core.TokenTypeJWT},
+				"actor_token":          {"service-token"},
+				"actor_token_type":     {core.TokenTypeAccessToken},
+				"audience":             {"orders", "billing"},
+				"resource":             {"https://api.example.com/orders"},
+				"requested_token_type": {core.TokenTypeJWT},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				require.NoError(t, r.ParseForm())
+				assert.Equal(t, "urn:ietf:params:oauth:grant-type:token-exchange", r.FormValue("grant_type"))
+				assert.Equal(t, "exchange-client", r.FormValue("client_id"))
+
+				for _, param := range []string{"actor_token", "actor_token_type", "audience", "resource", "requested_token_type"} {
+					if _, ok := tt.expectedParams[param]; !ok {
+						assert.NotContains(t, r.PostForm, param)
+					}
+				}
+
+				for param, values := range tt.expectedParams {
+					assert.Equal(t, values, r.PostForm[param], param)
+				}
+
+				w.Header().Set("Content-Type", "application/json")
+				_ = json.NewEncoder(w).Encode(map[string]any{
+					"access_token":      "exchanged-token",
+					"issued_token_type": core.TokenTypeJWT,
+					"token_type":        "N_A",
+					"expires_in":        60,
+				})
+			}))
+			defer server.Close()
+
+			client := core.Client{
+				ClientID:     "exchange-client",
+				ClientSecret: "secret",
+				TokenURL:     server.URL,
+			}
+
+			token, err := NewOAuthProvider().GetToken(context.Background(), client, core.TokenRequest{
+				Grant:    core.GrantTokenExchange,
+				Exchange: tt.exchange,
+			})
+
+			require.NoError(t, err)
+			assert.Equal(t, "exchanged-token", token.AccessToken)
+			assert.Equal(t, core.TokenTypeJWT, token.IssuedTokenType)
+			assert.Equal(t, "N_A", token.TokenType)
+		})
+	}
+}
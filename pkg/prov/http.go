@@ -0,0 +1,288 @@
+package prov
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"net/netip"
+	"net/url"
+	"strconv"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/ksysoev/authkeeper/pkg/core"
+)
+
+// DefaultTimeout limits a single request unless configured otherwise
+const DefaultTimeout = 30 * time.Second
+
+// DefaultRetries is how often a failing request is retried unless configured otherwise
+const DefaultRetries = 2
+
+// maxRetryDelay caps the backoff, a longer Retry-After fails the request instead of waiting
+const maxRetryDelay = 30 * time.Second
+
+// retryBaseDelay is the backoff before the first retry, it doubles with every attempt and is shortened in tests
+var retryBaseDelay = 500 * time.Millisecond
+
+// httpClientFor returns the HTTP client for the client's requests, configured with the global HTTP settings
+// and the client's overrides. Clients with a certificate present it during the TLS handshake (RFC 8705).
+func (p *OAuthProvider) httpClientFor(client core.Client) (*http.Client, error) {
+	settings := p.settings.Merge(client.HTTP)
+
+	timeout := p.httpClient.Timeout
+	if settings.Timeout > 0 {
+		timeout = settings.Timeout
+	}
+
+	if client.TLSCertificate == "" && settings.ProxyURL == "" && settings.CACertificates == "" && settings.TLSMinVersion == "" &&
+		settings.PinnedPublicKey == "" && !settings.InsecureSkipVerify {
+		if timeout == p.httpClient.Timeout {
+			return p.httpClient, nil
+		}
+
+		return &http.Client{Timeout: timeout, Transport: p.httpClient.Transport}, nil
+	}
+
+	transport, ok := p.httpClient.Transport.(*http.Transport)
+	if !ok || transport == nil {
+		transport = http.DefaultTransport.(*http.Transport)
+	}
+
+	transport = transport.Clone()
+	if transport.TLSClientConfig == nil {
+		transport.TLSClientConfig = &tls.Config{MinVersion: tls.VersionTLS12}
+	}
+
+	if settings.TLSMinVersion == core.TLSVersion13 {
+		transport.TLSClientConfig.MinVersion = tls.VersionTLS13
+	}
+
+	if settings.ProxyURL != "" {
+		proxyURL, err := url.Parse(settings.ProxyURL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid proxy URL: %w", err)
+		}
+
+		transport.Proxy = http.ProxyURL(proxyURL)
+	}
+
+	if settings.CACertificates != "" {
+		roots, err := rootCAs(transport.TLSClientConfig.RootCAs, settings.CACertificates)
+		if err != nil {
+			return nil, err
+		}
+
+		transport.TLSClientConfig.RootCAs = roots
+	}
+
+	if client.TLSCertificate != "" {
+		cert, err := tls.X509KeyPair([]byte(client.TLSCertificate), []byte(client.TLSKey))
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %w", err)
+		}
+
+		transport.TLSClientConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	switch {
+	case settings.PinnedPublicKey != "":
+		pin, err := core.ParsePublicKeyPin(settings.PinnedPublicKey)
+		if err != nil {
+			return nil, err
+		}
+
+		// The pin replaces the CA and host name checks, it is verified in VerifyConnection
+		transport.TLSClientConfig.InsecureSkipVerify = true
+		transport.TLSClientConfig.VerifyConnection = verifyPin(pin)
+	case settings.InsecureSkipVerify:
+		transport.TLSClientConfig.InsecureSkipVerify = true
+		// Connections go straight to the server, so the dialer sees and checks its address
+		transport.Proxy = nil
+		transport.DialContext = (&net.Dialer{
+			Timeout:   30 * time.Second,
+			KeepAlive: 30 * time.Second,
+			Control:   localOnly,
+		}).DialContext
+	}
+
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: transport,
+	}, nil
+}
+
+// rootCAs adds the PEM encoded certificates to the trusted roots, the system roots when none are configured
+func rootCAs(base *x509.CertPool, pemData string) (*x509.CertPool, error) {
+	var roots *x509.CertPool
+	if base != nil {
+		roots = base.Clone()
+	} else if system, err := x509.SystemCertPool(); err == nil {
+		roots = system
+	} else {
+		roots = x509.NewCertPool()
+	}
+
+	if !roots.AppendCertsFromPEM([]byte(pemData)) {
+		return nil, fmt.Errorf("failed to load CA certificates: no PEM encoded certificate found")
+	}
+
+	return roots, nil
+}
+
+// verifyPin accepts the connection when the hash of the server certificate's public key matches the pin.
+// Only the leaf certificate is checked, the chain is unverified and any server can send a pinned CA with it.
+func verifyPin(pin []byte) func(tls.ConnectionState) error {
+	return func(cs tls.ConnectionState) error {
+		if len(cs.PeerCertificates) == 0 {
+			return fmt.Errorf("server sent no certificate")
+		}
+
+		hash := sha256.Sum256(cs.PeerCertificates[0].RawSubjectPublicKeyInfo)
+		if subtle.ConstantTimeCompare(hash[:], pin) != 1 {
+			return fmt.Errorf("server public key %s does not match the pinned public key", core.PublicKeyPin(cs.PeerCertificates[0]))
+		}
+
+		return nil
+	}
+}
+
+// localOnly refuses connections to addresses other than loopback and private ones, it runs after
+// host names are resolved. Clients that skip certificate verification must not leave the local network.
+func localOnly(_, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return err
+	}
+
+	if !core.IsLocalAddress(addr) {
+		return fmt.Errorf("refusing insecure connection to %s, certificate verification can only be skipped for loopback and private addresses", host)
+	}
+
+	return nil
+}
+
+// send performs a request for the client, retrying on 429, 5xx and connection resets with exponential backoff
+// or the delay the server asks for with Retry-After. newRequest is called for every attempt, so request
+// bodies and client assertions are never reused. The response body is read and closed.
+// Requests that are not idempotent are only retried when the server cannot have processed them: on 429,
+// 503, and connection resets before the request was written.
+func (p *OAuthProvider) send(ctx context.Context, client core.Client, idempotent bool, newRequest func() (*http.Request, error)) (*http.Response, []byte, error) {
+	httpClient, err := p.httpClientFor(client)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	retries := DefaultRetries
+	if settings := p.settings.Merge(client.HTTP); settings.Retries != nil {
+		retries = *settings.Retries
+	}
+
+	for attempt := 0; ; attempt++ {
+		req, err := newRequest()
+		if err != nil {
+			return nil, nil, err
+		}
+
+		var written atomic.Bool
+		req = req.WithContext(httptrace.WithClientTrace(req.Context(), &httptrace.ClientTrace{
+			WroteRequest: func(httptrace.WroteRequestInfo) { written.Store(true) },
+		}))
+
+		resp, body, err := do(httpClient, req)
+
+		delay, retry := time.Duration(0), false
+		switch {
+		case err != nil:
+			delay, retry = backoff(attempt), isConnectionReset(err) && (idempotent || !written.Load())
+		case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable:
+			delay, retry = retryDelay(resp.Header, attempt)
+		case resp.StatusCode >= http.StatusInternalServerError && idempotent:
+			delay, retry = retryDelay(resp.Header, attempt)
+		}
+
+		if !retry || attempt >= retries {
+			return resp, body, err
+		}
+
+		select {
+		case <-ctx.Done():
+			if err == nil {
+				err = ctx.Err()
+			}
+
+			return nil, nil, err
+		case <-time.After(delay):
+		}
+	}
+}
+
+// do sends the request and reads the whole response body
+func do(httpClient *http.Client, req *http.Request) (*http.Response, []byte, error) {
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	return resp, body, nil
+}
+
+// isConnectionReset reports whether the server dropped the connection, which load balancers do while
+// instances restart. Timeouts are not retried, the request may still be processed.
+func isConnectionReset(err error) bool {
+	return errors.Is(err, syscall.ECONNRESET) || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+// retryDelay returns how long to wait before retrying a rejected request, honouring Retry-After given
+// in seconds or as an HTTP date. Requests asked to wait longer than maxRetryDelay are not retried.
+func retryDelay(header http.Header, attempt int) (time.Duration, bool) {
+	value := header.Get("Retry-After")
+	if value == "" {
+		return backoff(attempt), true
+	}
+
+	var delay time.Duration
+	if seconds, err := strconv.Atoi(value); err == nil {
+		delay = time.Duration(seconds) * time.Second
+	} else if date, err := http.ParseTime(value); err == nil {
+		delay = time.Until(date)
+	} else {
+		return backoff(attempt), true
+	}
+
+	if delay > maxRetryDelay {
+		return 0, false
+	}
+
+	return max(delay, 0), true
+}
+
+// backoff returns the exponential delay before the given retry attempt
+func backoff(attempt int) time.Duration {
+	delay := retryBaseDelay << min(attempt, 16)
+	if delay <= 0 {
+		return maxRetryDelay
+	}
+
+	return min(delay, maxRetryDelay)
+}
@@ -0,0 +1,380 @@
+package prov
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ksysoev/authkeeper/pkg/core"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fastRetries shortens the retry backoff for the duration of the test
+func fastRetries(t *testing.T) {
+	t.Helper()
+
+	delay := retryBaseDelay
+	retryBaseDelay = time.Millisecond
+
+	t.Cleanup(func() { retryBaseDelay = delay })
+}
+
+// newFlakyServer fails the first requests with the given handler and issues a token afterwards
+func newFlakyServer(t *testing.T, failures int, fail http.HandlerFunc) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+
+	var calls atomic.Int32
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if int(calls.Add(1)) <= failures {
+			fail(w, r)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"access_token":"test-token","token_type":"Bearer","expires_in":3600}`))
+	}))
+	t.Cleanup(server.Close)
+
+	return server, &calls
+}
+
+func TestOAuthProvider_GetToken_Retries(t *testing.T) {
+	fastRetries(t)
+
+	tests := []struct {
+		name      string
+		fail      http.HandlerFunc
+		failures  int
+		retries   *int
+		wantCalls int32
+		wantErr   string
+	}{
+		{
+			name: "retries 503 until success",
+			fail: func(w http.ResponseWriter, _ *http.Request) {
+				w.WriteHeader(http.StatusServiceUnavailable)
+			},
+			failures:  2,
+			wantCalls: 3,
+		},
+		{
+			name: "honours Retry-After on 429",
+			fail: func(w http.ResponseWriter, _ *http.Request) {
+				w.Header().Set("Retry-After", "0")
+				w.WriteHeader(http.StatusTooManyRequests)
+			},
+			failures:  1,
+			wantCalls: 2,
+		},
+		{
+			name: "retries a connection reset",
+			fail: func(w http.ResponseWriter, _ *http.Request) {
+				conn, _, err := w.(http.Hijacker).Hijack()
+				require.NoError(t, err)
+				_ = conn.Close()
+			},
+			failures:  1,
+			wantCalls: 2,
+		},
+		{
+			name: "gives up after the configured retries",
+			fail: func(w http.ResponseWriter, _ *http.Request) {
+				w.WriteHeader(http.StatusBadGateway)
+			},
+			failures:  5,
+			retries:   new(int),
+			wantCalls: 1,
+			wantErr:   "HTTP 502 Bad Gateway",
+		},
+		{
+			name: "does not wait for a long Retry-After",
+			fail: func(w http.ResponseWriter, _ *http.Request) {
+				w.Header().Set("Retry-After", "3600")
+				w.WriteHeader(http.StatusServiceUnavailable)
+			},
+			failures:  1,
+			wantCalls: 1,
+			wantErr:   "HTTP 503 Service Unavailable",
+		},
+		{
+			name: "does not retry client errors",
+			fail: func(w http.ResponseWriter, _ *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusBadRequest)
+				_, _ = w.Write([]byte(`{"error":"invalid_client"}`))
+			},
+			failures:  1,
+			wantCalls: 1,
+			wantErr:   "invalid_client",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, calls := newFlakyServer(t, tt.failures, tt.fail)
+
+			provider := NewOAuthProvider()
+			token, err := provider.GetToken(context.Background(), core.Client{
+				ClientID:     "client-id",
+				ClientSecret: "client-secret",
+				TokenURL:     server.URL,
+				HTTP:         core.HTTPSettings{Retries: tt.retries},
+			}, core.TokenRequest{})
+
+			assert.Equal(t, tt.wantCalls, calls.Load())
+
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, "test-token", token.AccessToken)
+		})
+	}
+}
+
+func TestOAuthProvider_PostForm_SingleUseGrants(t *testing.T) {
+	fastRetries(t)
+
+	tests := []struct {
+		name      string
+		grant     string
+		fail      http.HandlerFunc
+		wantCalls int32
+		wantErr   string
+	}{
+		{
+			name:  "authorization code is not resent after 500",
+			grant: "authorization_code",
+			fail: func(w http.ResponseWriter, _ *http.Request) {
+				w.WriteHeader(http.StatusInternalServerError)
+			},
+			wantCalls: 1,
+			wantErr:   "HTTP 500 Internal Server Error",
+		},
+		{
+			name:  "authorization code is not resent after a reset response",
+			grant: "authorization_code",
+			fail: func(w http.ResponseWriter, _ *http.Request) {
+				conn, _, err := w.(http.Hijacker).Hijack()
+				require.NoError(t, err)
+				_ = conn.Close()
+			},
+			wantCalls: 1,
+			wantErr:   "failed to send request",
+		},
+		{
+			name:  "authorization code is resent after 503",
+			grant: "authorization_code",
+			fail: func(w http.ResponseWriter, _ *http.Request) {
+				w.WriteHeader(http.StatusServiceUnavailable)
+			},
+			wantCalls: 2,
+		},
+		{
+			name:  "refresh token is not resent after 502",
+			grant: "refresh_token",
+			fail: func(w http.ResponseWriter, _ *http.Request) {
+				w.WriteHeader(http.StatusBadGateway)
+			},
+			wantCalls: 1,
+			wantErr:   "HTTP 502 Bad Gateway",
+		},
+		{
+			name:  "device code is resent after 429",
+			grant: core.GrantDeviceCode.Identifier(),
+			fail: func(w http.ResponseWriter, _ *http.Request) {
+				w.Header().Set("Retry-After", "0")
+				w.WriteHeader(http.StatusTooManyRequests)
+			},
+			wantCalls: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, calls := newFlakyServer(t, 1, tt.fail)
+
+			client := core.Client{ClientID: "client-id", ClientSecret: "client-secret", TokenURL: server.URL}
+			data := url.Values{"grant_type": {tt.grant}, "code": {"one-time-code"}}
+
+			_, err := NewOAuthProvider().postForm(context.Background(), client, server.URL, data)
+
+			assert.Equal(t, tt.wantCalls, calls.Load())
+
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestOAuthProvider_GetToken_RetryCancelled(t *testing.T) {
+	server, _ := newFlakyServer(t, 5, func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Retry-After", "10")
+		w.WriteHeader(http.StatusServiceUnavailable)
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	provider := NewOAuthProvider()
+	_, err := provider.GetToken(ctx, core.Client{ClientID: "client-id", ClientSecret: "client-secret", TokenURL: server.URL}, core.TokenRequest{})
+
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestRetryDelay(t *testing.T) {
+	fastRetries(t)
+
+	delay, retry := retryDelay(http.Header{}, 2)
+	assert.True(t, retry)
+	assert.Equal(t, 4*time.Millisecond, delay)
+
+	delay, retry = retryDelay(http.Header{"Retry-After": []string{"5"}}, 0)
+	assert.True(t, retry)
+	assert.Equal(t, 5*time.Second, delay)
+
+	date := time.Now().Add(10 * time.Second).UTC().Format(http.TimeFormat)
+	delay, retry = retryDelay(http.Header{"Retry-After": []string{date}}, 0)
+	assert.True(t, retry)
+	assert.InDelta(t, 10*time.Second, delay, float64(2*time.Second))
+
+	_, retry = retryDelay(http.Header{"Retry-After": []string{"120"}}, 0)
+	assert.False(t, retry)
+
+	assert.Equal(t, maxRetryDelay, backoff(100))
+}
+
+func TestHTTPClientFor_Settings(t *testing.T) {
+	certPEM, keyPEM, _ := newClientCertificate(t)
+
+	provider := NewOAuthProvider(WithHTTPSettings(core.HTTPSettings{
+		Timeout:        10 * time.Second,
+		ProxyURL:       "http://global-proxy.example.com:3128",
+		CACertificates: certPEM,
+	}))
+
+	httpClient, err := provider.httpClientFor(core.Client{
+		TLSCertificate: certPEM,
+		TLSKey:         keyPEM,
+		HTTP: core.HTTPSettings{
+			Timeout:       5 * time.Second,
+			ProxyURL:      "http://client-proxy.example.com:3128",
+			TLSMinVersion: core.TLSVersion13,
+		},
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, 5*time.Second, httpClient.Timeout)
+
+	transport, ok := httpClient.Transport.(*http.Transport)
+	require.True(t, ok)
+
+	proxyURL, err := transport.Proxy(&http.Request{URL: &url.URL{Scheme: "https", Host: "idp.example.com"}})
+	require.NoError(t, err)
+	assert.Equal(t, "client-proxy.example.com:3128", proxyURL.Host)
+
+	assert.Equal(t, uint16(tls.VersionTLS13), transport.TLSClientConfig.MinVersion)
+	assert.NotNil(t, transport.TLSClientConfig.RootCAs)
+	assert.Len(t, transport.TLSClientConfig.Certificates, 1)
+}
+
+func TestHTTPClientFor_TimeoutOnly(t *testing.T) {
+	provider := NewOAuthProvider()
+
+	httpClient, err := provider.httpClientFor(core.Client{HTTP: core.HTTPSettings{Timeout: time.Second}})
+	require.NoError(t, err)
+
+	assert.NotSame(t, provider.httpClient, httpClient)
+	assert.Equal(t, time.Second, httpClient.Timeout)
+}
+
+func TestOAuthProvider_GetToken_CustomCA(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"access_token":"test-token","token_type":"Bearer"}`))
+	}))
+	defer server.Close()
+
+	client := core.Client{ClientID: "client-id", ClientSecret: "client-secret", TokenURL: server.URL}
+
+	_, err := NewOAuthProvider().GetToken(context.Background(), client, core.TokenRequest{})
+	assert.ErrorContains(t, err, "certificate")
+
+	client.HTTP.CACertificates = string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}))
+
+	token, err := NewOAuthProvider().GetToken(context.Background(), client, core.TokenRequest{})
+	require.NoError(t, err)
+	assert.Equal(t, "test-token", token.AccessToken)
+}
+
+// newSelfSignedServer starts a TLS server issuing tokens with a certificate no CA vouches for
+func newSelfSignedServer(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"access_token":"test-token","token_type":"Bearer"}`))
+	}))
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+func TestOAuthProvider_GetToken_PinnedPublicKey(t *testing.T) {
+	server := newSelfSignedServer(t)
+	otherCert, _, _ := newClientCertificate(t)
+	block, _ := pem.Decode([]byte(otherCert))
+	other, err := x509.ParseCertificate(block.Bytes)
+	require.NoError(t, err)
+
+	client := core.Client{ClientID: "client-id", ClientSecret: "client-secret", TokenURL: server.URL}
+
+	client.HTTP.PinnedPublicKey = core.PublicKeyPin(server.Certificate())
+	token, err := NewOAuthProvider().GetToken(context.Background(), client, core.TokenRequest{})
+	require.NoError(t, err)
+	assert.Equal(t, "test-token", token.AccessToken)
+
+	client.HTTP.PinnedPublicKey = "sha256//" + core.PublicKeyPin(other)
+	_, err = NewOAuthProvider().GetToken(context.Background(), client, core.TokenRequest{})
+	assert.ErrorContains(t, err, "does not match the pinned public key")
+}
+
+func TestOAuthProvider_GetToken_InsecureSkipVerify(t *testing.T) {
+	server := newSelfSignedServer(t)
+
+	client := core.Client{
+		ClientID:     "client-id",
+		ClientSecret: "client-secret",
+		TokenURL:     server.URL,
+		HTTP:         core.HTTPSettings{InsecureSkipVerify: true},
+	}
+
+	// A global proxy is bypassed, the dialer must see the server's address
+	provider := NewOAuthProvider(WithHTTPSettings(core.HTTPSettings{ProxyURL: "http://127.0.0.1:1"}))
+
+	token, err := provider.GetToken(context.Background(), client, core.TokenRequest{})
+	require.NoError(t, err)
+	assert.Equal(t, "test-token", token.AccessToken)
+}
+
+func TestLocalOnly(t *testing.T) {
+	assert.NoError(t, localOnly("tcp", "127.0.0.1:8443", nil))
+	assert.NoError(t, localOnly("tcp", "[fd00::1]:443", nil))
+	assert.NoError(t, localOnly("tcp", "192.168.1.20:443", nil))
+	assert.ErrorContains(t, localOnly("tcp", "203.0.113.10:443", nil), "refusing insecure connection to 203.0.113.10")
+}
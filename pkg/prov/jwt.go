@@ -0,0 +1,313 @@
+package prov
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	_ "crypto/sha256" // registers SHA-256 for crypto.Hash
+	_ "crypto/sha512" // registers SHA-384 and SHA-512 for crypto.Hash
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ksysoev/authkeeper/pkg/core"
+)
+
+// clientAssertionType is the client_assertion_type for JWT client authentication (RFC 7523)
+const clientAssertionType = "urn:ietf:params:oauth:client-assertion-type:jwt-bearer"
+
+// assertionLifetime keeps client assertions short-lived, they are used for a single request
+const assertionLifetime = time.Minute
+
+// jwtHeader is the JOSE header of a signed JWT
+type jwtHeader struct {
+	Alg string `json:"alg"`
+	Typ string `json:"typ"`
+	Kid string `json:"kid,omitempty"`
+}
+
+// assertionClaims are the claims of a client authentication assertion
+type assertionClaims struct {
+	Iss string `json:"iss"`
+	Sub string `json:"sub"`
+	Aud string `json:"aud"`
+	Jti string `json:"jti"`
+	Iat int64  `json:"iat"`
+	Exp int64  `json:"exp"`
+}
+
+// privateKeyAssertion builds a client_assertion signed with the client's private key
+func privateKeyAssertion(client core.Client, now time.Time) (string, error) {
+	key, err := parsePrivateKey(client.PrivateKey)
+	if err != nil {
+		return "", err
+	}
+
+	alg, err := signingAlgorithm(key, client.SigningAlgorithm)
+	if err != nil {
+		return "", err
+	}
+
+	claims, err := newAssertionClaims(client, now)
+	if err != nil {
+		return "", err
+	}
+
+	return signJWT(jwtHeader{Alg: alg, Typ: "JWT", Kid: client.KeyID}, claims, key)
+}
+
+// secretAssertion builds a client_assertion signed with HMAC using the client secret as the key
+func secretAssertion(client core.Client, now time.Time) (string, error) {
+	alg := client.SigningAlgorithm
+	switch alg {
+	case "":
+		alg = "HS256"
+	case "HS256", "HS384", "HS512":
+	default:
+		return "", fmt.Errorf("signing algorithm %s cannot be used with %s, expected HS256, HS384 or HS512",
+			alg, core.AuthMethodClientSecretJWT)
+	}
+
+	claims, err := newAssertionClaims(client, now)
+	if err != nil {
+		return "", err
+	}
+
+	return signJWT(jwtHeader{Alg: alg, Typ: "JWT", Kid: client.KeyID}, claims, []byte(client.ClientSecret))
+}
+
+// newAssertionClaims returns the claims identifying the client to its token endpoint
+func newAssertionClaims(client core.Client, now time.Time) (assertionClaims, error) {
+	jti := make([]byte, 16)
+	if _, err := rand.Read(jti); err != nil {
+		return assertionClaims{}, fmt.Errorf("failed to generate assertion ID: %w", err)
+	}
+
+	return assertionClaims{
+		Iss: client.ClientID,
+		Sub: client.ClientID,
+		Aud: client.TokenURL,
+		Jti: hex.EncodeToString(jti),
+		Iat: now.Unix(),
+		Exp: now.Add(assertionLifetime).Unix(),
+	}, nil
+}
+
+// grantClaims are the claims of a jwt_bearer authorization grant assertion (RFC 7523 section 3).
+// Google service accounts expect the requested scopes in the scope claim.
+type grantClaims struct {
+	Iss   string `json:"iss"`
+	Sub   string `json:"sub,omitempty"`
+	Aud   string `json:"aud"`
+	Scope string `json:"scope,omitempty"`
+	Jti   string `json:"jti"`
+	Iat   int64  `json:"iat"`
+	Exp   int64  `json:"exp"`
+}
+
+// grantAssertion builds the assertion of the jwt_bearer grant, signed with the client's private key
+func grantAssertion(client core.Client, now time.Time) (string, error) {
+	key, err := parsePrivateKey(client.PrivateKey)
+	if err != nil {
+		return "", err
+	}
+
+	alg, err := signingAlgorithm(key, client.SigningAlgorithm)
+	if err != nil {
+		return "", err
+	}
+
+	id, err := newAssertionClaims(client, now)
+	if err != nil {
+		return "", err
+	}
+
+	claims := grantClaims{
+		Iss:   client.ClientID,
+		Sub:   client.AssertionSubject,
+		Aud:   client.TokenURL,
+		Scope: strings.Join(client.Scopes, " "),
+		Jti:   id.Jti,
+		Iat:   id.Iat,
+		Exp:   id.Exp,
+	}
+
+	if client.AssertionIssuer != "" {
+		claims.Iss = client.AssertionIssuer
+	}
+
+	if client.AssertionAudience != "" {
+		claims.Aud = client.AssertionAudience
+	}
+
+	return signJWT(jwtHeader{Alg: alg, Typ: "JWT", Kid: client.KeyID}, claims, key)
+}
+
+// parsePrivateKey decodes a PEM encoded PKCS#8, PKCS#1 or SEC 1 private key
+func parsePrivateKey(pemData string) (crypto.Signer, error) {
+	block, _ := pem.Decode([]byte(pemData))
+	if block == nil {
+		return nil, fmt.Errorf("failed to parse private key: no PEM block found")
+	}
+
+	if key, err := x509.ParsePKCS8PrivateKey(block.Bytes); err == nil {
+		signer, ok := key.(crypto.Signer)
+		if !ok {
+			return nil, fmt.Errorf("failed to parse private key: unsupported key type %T", key)
+		}
+
+		return signer, nil
+	}
+
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+
+	if key, err := x509.ParseECPrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+
+	return nil, fmt.Errorf("failed to parse private key: unsupported PEM block %q", block.Type)
+}
+
+// signingAlgorithm validates the configured JWS algorithm against the key type,
+// choosing the conventional algorithm for the key when none is configured
+func signingAlgorithm(key crypto.Signer, alg string) (string, error) {
+	var allowed []string
+
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		allowed = []string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512"}
+	case *ecdsa.PrivateKey:
+		switch k.Curve {
+		case elliptic.P256():
+			allowed = []string{"ES256"}
+		case elliptic.P384():
+			allowed = []string{"ES384"}
+		case elliptic.P521():
+			allowed = []string{"ES512"}
+		default:
+			return "", fmt.Errorf("unsupported ECDSA curve %s", k.Curve.Params().Name)
+		}
+	case ed25519.PrivateKey:
+		allowed = []string{"EdDSA"}
+	default:
+		return "", fmt.Errorf("unsupported private key type %T", key)
+	}
+
+	if alg == "" {
+		return allowed[0], nil
+	}
+
+	for _, a := range allowed {
+		if a == alg {
+			return alg, nil
+		}
+	}
+
+	return "", fmt.Errorf("signing algorithm %s cannot be used with a %T key", alg, key)
+}
+
+// signJWT encodes the header and claims and signs them according to header.Alg.
+// The key is a crypto.Signer for asymmetric algorithms or a []byte secret for HMAC.
+func signJWT(header jwtHeader, claims any, key any) (string, error) {
+	signingInput, err := encodeSigningInput(header, claims)
+	if err != nil {
+		return "", err
+	}
+
+	signature, err := sign(header.Alg, key, []byte(signingInput))
+	if err != nil {
+		return "", fmt.Errorf("failed to sign assertion: %w", err)
+	}
+
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+// encodeSigningInput returns the base64url encoded header and claims joined by a dot
+func encodeSigningInput(header jwtHeader, claims any) (string, error) {
+	headerJSON, err := json.Marshal(header)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode JWT header: %w", err)
+	}
+
+	claimsJSON, err := json.Marshal(claims)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode JWT claims: %w", err)
+	}
+
+	return base64.RawURLEncoding.EncodeToString(headerJSON) + "." + base64.RawURLEncoding.EncodeToString(claimsJSON), nil
+}
+
+func sign(alg string, key any, input []byte) ([]byte, error) {
+	if k, ok := key.(ed25519.PrivateKey); ok && alg == "EdDSA" {
+		return k.Sign(rand.Reader, input, crypto.Hash(0))
+	}
+
+	hash, err := algorithmHash(alg)
+	if err != nil {
+		return nil, err
+	}
+
+	if secret, ok := key.([]byte); ok {
+		mac := hmac.New(hash.New, secret)
+		mac.Write(input)
+
+		return mac.Sum(nil), nil
+	}
+
+	h := hash.New()
+	h.Write(input)
+	digest := h.Sum(nil)
+
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		if alg[0] == 'P' {
+			return rsa.SignPSS(rand.Reader, k, hash, digest, &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash})
+		}
+
+		return rsa.SignPKCS1v15(rand.Reader, k, hash, digest)
+	case *ecdsa.PrivateKey:
+		r, s, err := ecdsa.Sign(rand.Reader, k, digest)
+		if err != nil {
+			return nil, err
+		}
+
+		// JWS uses the fixed size R || S encoding instead of ASN.1
+		size := (k.Curve.Params().BitSize + 7) / 8
+		signature := make([]byte, 2*size)
+		r.FillBytes(signature[:size])
+		s.FillBytes(signature[size:])
+
+		return signature, nil
+	default:
+		return nil, fmt.Errorf("unsupported private key type %T", key)
+	}
+}
+
+// algorithmHash returns the hash function used by a JWS algorithm
+func algorithmHash(alg string) (crypto.Hash, error) {
+	if len(alg) != 5 {
+		return 0, fmt.Errorf("unsupported signing algorithm %q", alg)
+	}
+
+	switch alg[2:] {
+	case "256":
+		return crypto.SHA256, nil
+	case "384":
+		return crypto.SHA384, nil
+	case "512":
+		return crypto.SHA512, nil
+	default:
+		return 0, fmt.Errorf("unsupported signing algorithm %q", alg)
+	}
+}
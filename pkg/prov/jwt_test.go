@@ -0,0 +1,326 @@
+package prov
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"hash"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ksysoev/authkeeper/pkg/core"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// encodePKCS8 returns the PEM encoded PKCS#8 form of a private key
+func encodePKCS8(t *testing.T, key crypto.Signer) string {
+	t.Helper()
+
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	require.NoError(t, err)
+
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}))
+}
+
+// decodeJWT splits a compact JWT and decodes its header and claims
+func decodeJWT(t *testing.T, token string, header, claims any) (signingInput string, signature []byte) {
+	t.Helper()
+
+	parts := strings.Split(token, ".")
+	require.Len(t, parts, 3)
+
+	headerJSON, err := base64.RawURLEncoding.DecodeString(parts[0])
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(headerJSON, header))
+
+	claimsJSON, err := base64.RawURLEncoding.DecodeString(parts[1])
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(claimsJSON, claims))
+
+	signature, err = base64.RawURLEncoding.DecodeString(parts[2])
+	require.NoError(t, err)
+
+	return parts[0] + "." + parts[1], signature
+}
+
+func TestPrivateKeyAssertion(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name        string
+		key         crypto.Signer
+		alg         string
+		expectedAlg string
+		verify      func(t *testing.T, input string, signature []byte)
+	}{
+		{
+			name:        "RSA defaults to RS256",
+			key:         rsaKey,
+			expectedAlg: "RS256",
+			verify: func(t *testing.T, input string, signature []byte) {
+				digest := sha256.Sum256([]byte(input))
+				assert.NoError(t, rsa.VerifyPKCS1v15(&rsaKey.PublicKey, crypto.SHA256, digest[:], signature))
+			},
+		},
+		{
+			name:        "RSA with PS256",
+			key:         rsaKey,
+			alg:         "PS256",
+			expectedAlg: "PS256",
+			verify: func(t *testing.T, input string, signature []byte) {
+				digest := sha256.Sum256([]byte(input))
+				assert.NoError(t, rsa.VerifyPSS(&rsaKey.PublicKey, crypto.SHA256, digest[:], signature, nil))
+			},
+		},
+		{
+			name:        "ECDSA P-256 uses ES256",
+			key:         ecKey,
+			expectedAlg: "ES256",
+			verify: func(t *testing.T, input string, signature []byte) {
+				require.Len(t, signature, 64)
+				digest := sha256.Sum256([]byte(input))
+				r := new(big.Int).SetBytes(signature[:32])
+				s := new(big.Int).SetBytes(signature[32:])
+				assert.True(t, ecdsa.Verify(&ecKey.PublicKey, digest[:], r, s))
+			},
+		},
+		{
+			name:        "Ed25519 uses EdDSA",
+			key:         edKey,
+			expectedAlg: "EdDSA",
+			verify: func(t *testing.T, input string, signature []byte) {
+				assert.True(t, ed25519.Verify(edKey.Public().(ed25519.PublicKey), []byte(input), signature))
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			now := time.Now()
+			client := core.Client{
+				ClientID:         "client-id",
+				TokenURL:         "https://example.com/token",
+				PrivateKey:       encodePKCS8(t, tt.key),
+				KeyID:            "key-1",
+				SigningAlgorithm: tt.alg,
+			}
+
+			assertion, err := privateKeyAssertion(client, now)
+			require.NoError(t, err)
+
+			var header jwtHeader
+			var claims assertionClaims
+			input, signature := decodeJWT(t, assertion, &header, &claims)
+
+			assert.Equal(t, jwtHeader{Alg: tt.expectedAlg, Typ: "JWT", Kid: "key-1"}, header)
+			assert.Equal(t, "client-id", claims.Iss)
+			assert.Equal(t, "client-id", claims.Sub)
+			assert.Equal(t, "https://example.com/token", claims.Aud)
+			assert.NotEmpty(t, claims.Jti)
+			assert.Equal(t, now.Unix(), claims.Iat)
+			assert.Equal(t, now.Add(assertionLifetime).Unix(), claims.Exp)
+
+			tt.verify(t, input, signature)
+		})
+	}
+}
+
+func TestPrivateKeyAssertion_UniqueJTI(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	client := core.Client{ClientID: "client-id", TokenURL: "https://example.com/token", PrivateKey: encodePKCS8(t, key)}
+
+	first, err := privateKeyAssertion(client, time.Now())
+	require.NoError(t, err)
+	second, err := privateKeyAssertion(client, time.Now())
+	require.NoError(t, err)
+
+	var header jwtHeader
+	var claims1, claims2 assertionClaims
+	decodeJWT(t, first, &header, &claims1)
+	decodeJWT(t, second, &header, &claims2)
+
+	assert.NotEqual(t, claims1.Jti, claims2.Jti)
+}
+
+func TestParsePrivateKey(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	require.NoError(t, err)
+
+	ecDER, err := x509.MarshalECPrivateKey(ecKey)
+	require.NoError(t, err)
+
+	t.Run("PKCS#1 RSA", func(t *testing.T) {
+		data := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(rsaKey)})
+
+		key, err := parsePrivateKey(string(data))
+		require.NoError(t, err)
+		assert.IsType(t, &rsa.PrivateKey{}, key)
+	})
+
+	t.Run("SEC 1 EC", func(t *testing.T) {
+		data := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: ecDER})
+
+		key, err := parsePrivateKey(string(data))
+		require.NoError(t, err)
+
+		alg, err := signingAlgorithm(key, "")
+		require.NoError(t, err)
+		assert.Equal(t, "ES384", alg)
+	})
+
+	t.Run("not PEM", func(t *testing.T) {
+		_, err := parsePrivateKey("not a key")
+		assert.ErrorContains(t, err, "no PEM block found")
+	})
+
+	t.Run("certificate instead of key", func(t *testing.T) {
+		data := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: []byte("garbage")})
+
+		_, err := parsePrivateKey(string(data))
+		assert.ErrorContains(t, err, "unsupported PEM block")
+	})
+}
+
+func TestSigningAlgorithm_Mismatch(t *testing.T) {
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	_, err = signingAlgorithm(ecKey, "RS256")
+	assert.ErrorContains(t, err, "cannot be used")
+
+	_, err = signingAlgorithm(ecKey, "ES384")
+	assert.ErrorContains(t, err, "cannot be used")
+}
+
+func TestSecretAssertion(t *testing.T) {
+	tests := []struct {
+		name        string
+		alg         string
+		expectedAlg string
+		hash        func() hash.Hash
+		expectedErr string
+	}{
+		{name: "defaults to HS256", expectedAlg: "HS256", hash: sha256.New},
+		{name: "HS384", alg: "HS384", expectedAlg: "HS384", hash: sha512.New384},
+		{name: "HS512", alg: "HS512", expectedAlg: "HS512", hash: sha512.New},
+		{name: "asymmetric algorithm", alg: "RS256", expectedErr: "cannot be used with client_secret_jwt"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			now := time.Now()
+			client := core.Client{
+				ClientID:         "client-id",
+				ClientSecret:     "client-secret",
+				TokenURL:         "https://example.com/token",
+				SigningAlgorithm: tt.alg,
+			}
+
+			assertion, err := secretAssertion(client, now)
+			if tt.expectedErr != "" {
+				assert.ErrorContains(t, err, tt.expectedErr)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.NotContains(t, assertion, "client-secret")
+
+			var header jwtHeader
+			var claims assertionClaims
+			input, signature := decodeJWT(t, assertion, &header, &claims)
+
+			assert.Equal(t, tt.expectedAlg, header.Alg)
+			assert.Equal(t, "client-id", claims.Iss)
+			assert.Equal(t, "https://example.com/token", claims.Aud)
+
+			mac := hmac.New(tt.hash, []byte("client-secret"))
+			mac.Write([]byte(input))
+			assert.True(t, hmac.Equal(mac.Sum(nil), signature))
+		})
+	}
+}
+
+func TestGrantAssertion(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	now := time.Now()
+	client := core.Client{
+		ClientID:   "sa@project.iam.gserviceaccount.com",
+		TokenURL:   "https://oauth2.googleapis.com/token",
+		Scopes:     []string{"https://www.googleapis.com/auth/cloud-platform", "openid"},
+		PrivateKey: encodePKCS8(t, key),
+		KeyID:      "key-1",
+	}
+
+	t.Run("defaults", func(t *testing.T) {
+		assertion, err := grantAssertion(client, now)
+		require.NoError(t, err)
+
+		var header jwtHeader
+		var claims map[string]any
+		input, signature := decodeJWT(t, assertion, &header, &claims)
+
+		assert.Equal(t, jwtHeader{Alg: "ES256", Typ: "JWT", Kid: "key-1"}, header)
+		assert.Equal(t, "sa@project.iam.gserviceaccount.com", claims["iss"])
+		assert.NotContains(t, claims, "sub", "the subject is only sent when impersonating a user")
+		assert.Equal(t, "https://oauth2.googleapis.com/token", claims["aud"])
+		assert.Equal(t, "https://www.googleapis.com/auth/cloud-platform openid", claims["scope"])
+		assert.Equal(t, float64(now.Add(assertionLifetime).Unix()), claims["exp"])
+
+		digest := sha256.Sum256([]byte(input))
+		r := new(big.Int).SetBytes(signature[:32])
+		s := new(big.Int).SetBytes(signature[32:])
+		assert.True(t, ecdsa.Verify(&key.PublicKey, digest[:], r, s))
+	})
+
+	t.Run("configured claims", func(t *testing.T) {
+		configured := client
+		configured.AssertionIssuer = "issuer@example.com"
+		configured.AssertionSubject = "admin@example.com"
+		configured.AssertionAudience = "https://idp.example.com"
+		configured.Scopes = nil
+
+		assertion, err := grantAssertion(configured, now)
+		require.NoError(t, err)
+
+		var header jwtHeader
+		var claims map[string]any
+		decodeJWT(t, assertion, &header, &claims)
+
+		assert.Equal(t, "issuer@example.com", claims["iss"])
+		assert.Equal(t, "admin@example.com", claims["sub"])
+		assert.Equal(t, "https://idp.example.com", claims["aud"])
+		assert.NotContains(t, claims, "scope")
+	})
+
+	t.Run("invalid key", func(t *testing.T) {
+		invalid := client
+		invalid.PrivateKey = "not a key"
+
+		_, err := grantAssertion(invalid, now)
+		assert.Error(t, err)
+	})
+}
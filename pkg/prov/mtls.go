@@ -0,0 +1,33 @@
+package prov
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+)
+
+// certificateThumbprint returns the cnf.x5t#S256 claim of a JWT access token bound to a client certificate,
+// or an empty string when the token is opaque or not certificate-bound
+func certificateThumbprint(accessToken string) string {
+	parts := strings.Split(accessToken, ".")
+	if len(parts) != 3 {
+		return ""
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return ""
+	}
+
+	var claims struct {
+		Cnf struct {
+			X5tS256 string `json:"x5t#S256"`
+		} `json:"cnf"`
+	}
+
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return ""
+	}
+
+	return claims.Cnf.X5tS256
+}
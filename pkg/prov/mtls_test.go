@@ -0,0 +1,123 @@
+package prov
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ksysoev/authkeeper/pkg/core"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newClientCertificate generates a self-signed client certificate and returns it and its key as PEM
+func newClientCertificate(t *testing.T) (certPEM, keyPEM string, der []byte) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "client-id"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+
+	der, err = x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+
+	certPEM = string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}))
+
+	return certPEM, encodePKCS8(t, key), der
+}
+
+func TestOAuthProvider_GetToken_MutualTLS(t *testing.T) {
+	certPEM, keyPEM, der := newClientCertificate(t)
+
+	digest := sha256.Sum256(der)
+	thumbprint := base64.RawURLEncoding.EncodeToString(digest[:])
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Len(t, r.TLS.PeerCertificates, 1, "client certificate must be presented")
+		assert.Equal(t, "client-id", r.TLS.PeerCertificates[0].Subject.CommonName)
+
+		err := r.ParseForm()
+		require.NoError(t, err)
+		assert.Equal(t, "client-id", r.FormValue("client_id"))
+		assert.False(t, r.Form.Has("client_secret"))
+
+		claims, _ := json.Marshal(map[string]any{"sub": "client-id", "cnf": map[string]string{"x5t#S256": thumbprint}})
+		accessToken := "eyJhbGciOiJub25lIn0." + base64.RawURLEncoding.EncodeToString(claims) + ".sig"
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"access_token": accessToken,
+			"token_type":   "Bearer",
+			"expires_in":   3600,
+		})
+	}))
+	server.TLS = &tls.Config{ClientAuth: tls.RequireAnyClientCert}
+	server.StartTLS()
+	defer server.Close()
+
+	provider := NewOAuthProvider()
+	provider.httpClient.Transport = server.Client().Transport
+
+	token, err := provider.GetToken(context.Background(), core.Client{
+		ClientID:                "client-id",
+		TokenURL:                server.URL,
+		TokenEndpointAuthMethod: core.AuthMethodSelfSignedTLSClientAuth,
+		TLSCertificate:          certPEM,
+		TLSKey:                  keyPEM,
+	}, core.TokenRequest{})
+
+	require.NoError(t, err)
+	assert.Equal(t, thumbprint, token.CertThumbprint)
+}
+
+func TestOAuthProvider_GetToken_MutualTLS_InvalidCertificate(t *testing.T) {
+	provider := NewOAuthProvider()
+
+	token, err := provider.GetToken(context.Background(), core.Client{
+		ClientID:                "client-id",
+		TokenURL:                "https://127.0.0.1:1/token",
+		TokenEndpointAuthMethod: core.AuthMethodTLSClientAuth,
+		TLSCertificate:          "not a certificate",
+		TLSKey:                  "not a key",
+	}, core.TokenRequest{})
+
+	assert.ErrorContains(t, err, "failed to load client certificate")
+	assert.Nil(t, token)
+}
+
+func TestHTTPClientFor_WithoutCertificate(t *testing.T) {
+	provider := NewOAuthProvider()
+
+	httpClient, err := provider.httpClientFor(core.Client{})
+	require.NoError(t, err)
+	assert.Same(t, provider.httpClient, httpClient)
+}
+
+func TestCertificateThumbprint(t *testing.T) {
+	claims := base64.RawURLEncoding.EncodeToString([]byte(`{"cnf":{"x5t#S256":"abc"}}`))
+
+	assert.Equal(t, "abc", certificateThumbprint("h."+claims+".s"))
+	assert.Empty(t, certificateThumbprint("opaque-token"))
+	assert.Empty(t, certificateThumbprint("h."+base64.RawURLEncoding.EncodeToString([]byte(`{"sub":"x"}`))+".s"))
+	assert.Empty(t, certificateThumbprint("h.!!!.s"))
+}
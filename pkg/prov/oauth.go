@@ -2,9 +2,9 @@ package prov
 
 import (
 	"context"
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
-	"io"
 	"net/http"
 	"net/url"
 	"strings"
@@ -16,66 +16,240 @@ import (
 // OAuthProvider implements core.Provider interface for OAuth2 operations
 type OAuthProvider struct {
 	httpClient *http.Client
+	settings   core.HTTPSettings
+}
+
+// Option configures an OAuthProvider
+type Option func(*OAuthProvider)
+
+// WithHTTPSettings sets the global HTTP settings, clients can override them
+func WithHTTPSettings(settings core.HTTPSettings) Option {
+	return func(p *OAuthProvider) {
+		p.settings = settings
+	}
 }
 
 // NewOAuthProvider creates a new OAuth provider
-func NewOAuthProvider() *OAuthProvider {
-	return &OAuthProvider{
+func NewOAuthProvider(opts ...Option) *OAuthProvider {
+	p := &OAuthProvider{
 		httpClient: &http.Client{
-			Timeout: 30 * time.Second,
+			Timeout: DefaultTimeout,
 		},
 	}
+
+	for _, opt := range opts {
+		opt(p)
+	}
+
+	return p
 }
 
-// GetToken obtains an access token using client credentials flow
-func (p *OAuthProvider) GetToken(ctx context.Context, client core.Client) (*core.Token, error) {
-	data := url.Values{}
-	data.Set("grant_type", "client_credentials")
-	data.Set("client_id", client.ClientID)
-	data.Set("client_secret", client.ClientSecret)
+// GetToken obtains an access token for the client using the requested grant
+func (p *OAuthProvider) GetToken(ctx context.Context, client core.Client, req core.TokenRequest) (*core.Token, error) {
+	switch req.Grant {
+	case "", core.GrantClientCredentials:
+		data := url.Values{}
+		data.Set("grant_type", string(core.GrantClientCredentials))
+
+		if len(client.Scopes) > 0 {
+			data.Set("scope", strings.Join(client.Scopes, " "))
+		}
+
+		return p.requestToken(ctx, client, data)
+	case core.GrantAuthorizationCode:
+		return p.authorizationCode(ctx, client, req.Interactor)
+	case core.GrantDeviceCode:
+		return p.deviceCode(ctx, client, req.Interactor)
+	case core.GrantJWTBearer:
+		assertion, err := grantAssertion(client, time.Now())
+		if err != nil {
+			return nil, fmt.Errorf("failed to build grant assertion: %w", err)
+		}
+
+		data := url.Values{}
+		data.Set("grant_type", core.GrantJWTBearer.Identifier())
+		data.Set("assertion", assertion)
+
+		if len(client.Scopes) > 0 {
+			data.Set("scope", strings.Join(client.Scopes, " "))
+		}
+
+		return p.requestToken(ctx, client, data)
+	case core.GrantPassword:
+		if req.Username == "" || req.Password == "" {
+			return nil, fmt.Errorf("username and password are required for the %s grant", core.GrantPassword)
+		}
+
+		data := url.Values{}
+		data.Set("grant_type", string(core.GrantPassword))
+		data.Set("username", req.Username)
+		data.Set("password", req.Password)
 
-	if len(client.Scopes) > 0 {
-		data.Set("scope", strings.Join(client.Scopes, " "))
+		if len(client.Scopes) > 0 {
+			data.Set("scope", strings.Join(client.Scopes, " "))
+		}
+
+		return p.requestToken(ctx, client, data)
+	case core.GrantRefreshToken:
+		if req.RefreshToken == "" {
+			return nil, fmt.Errorf("refresh token is required for the %s grant", core.GrantRefreshToken)
+		}
+
+		data := url.Values{}
+		data.Set("grant_type", string(core.GrantRefreshToken))
+		data.Set("refresh_token", req.RefreshToken)
+
+		if len(client.Scopes) > 0 {
+			data.Set("scope", strings.Join(client.Scopes, " "))
+		}
+
+		return p.requestToken(ctx, client, data)
+	case core.GrantTokenExchange:
+		return p.tokenExchange(ctx, client, req.Exchange)
+	default:
+		return nil, fmt.Errorf("unsupported grant type %q", req.Grant)
 	}
+}
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, client.TokenURL, strings.NewReader(data.Encode()))
+// requestToken authenticates the client and sends the grant parameters to the token endpoint
+func (p *OAuthProvider) requestToken(ctx context.Context, client core.Client, data url.Values) (*core.Token, error) {
+	body, err := p.postForm(ctx, client, client.TokenURL, data)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
+		return nil, fmt.Errorf("token request failed: %w", err)
 	}
 
-	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
-	req.Header.Set("Accept", "application/json")
+	var tokenResp struct {
+		AccessToken     string `json:"access_token"`
+		TokenType       string `json:"token_type"`
+		ExpiresIn       int    `json:"expires_in"`
+		Scope           string `json:"scope,omitempty"`
+		RefreshToken    string `json:"refresh_token,omitempty"`
+		IssuedTokenType string `json:"issued_token_type,omitempty"`
+	}
 
-	resp, err := p.httpClient.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("failed to send request: %w", err)
+	if err := json.Unmarshal(body, &tokenResp); err != nil {
+		return nil, fmt.Errorf("failed to parse token response: %w", err)
+	}
+
+	token := &core.Token{
+		AccessToken:     tokenResp.AccessToken,
+		TokenType:       tokenResp.TokenType,
+		ExpiresIn:       tokenResp.ExpiresIn,
+		Scope:           tokenResp.Scope,
+		RefreshToken:    tokenResp.RefreshToken,
+		IssuedTokenType: tokenResp.IssuedTokenType,
+	}
+
+	if client.TLSCertificate != "" {
+		token.CertThumbprint = certificateThumbprint(tokenResp.AccessToken)
 	}
-	defer func() { _ = resp.Body.Close() }()
 
-	body, err := io.ReadAll(resp.Body)
+	return token, nil
+}
+
+// singleUseGrants redeem a credential the server accepts once: an authorization or device code, or a refresh
+// token the server rotates. Resending a request the server processed fails with invalid_grant and may
+// revoke the tokens it just issued.
+var singleUseGrants = map[string]bool{
+	string(core.GrantAuthorizationCode): true,
+	core.GrantDeviceCode.Identifier():   true,
+	string(core.GrantRefreshToken):      true,
+}
+
+// postForm authenticates the client and posts the form to one of the authorization server's endpoints.
+// Responses other than 200 OK are returned as *core.OAuthError.
+func (p *OAuthProvider) postForm(ctx context.Context, client core.Client, endpoint string, data url.Values) ([]byte, error) {
+	addTarget(client, data)
+
+	idempotent := !singleUseGrants[data.Get("grant_type")]
+
+	resp, body, err := p.send(ctx, client, idempotent, func() (*http.Request, error) {
+		// Every attempt is authenticated again, client assertions must not be replayed
+		header := http.Header{}
+		if err := authenticate(client, data, header); err != nil {
+			return nil, fmt.Errorf("failed to authenticate client: %w", err)
+		}
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(data.Encode()))
+		if err != nil {
+			return nil, fmt.Errorf("failed to create request: %w", err)
+		}
+
+		req.Header = header
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Set("Accept", "application/json")
+
+		for name, value := range client.ExtraHeaders {
+			if req.Header.Get(name) == "" {
+				req.Header.Set(name, value)
+			}
+		}
+
+		return req, nil
+	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to read response: %w", err)
+		return nil, err
 	}
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("token request failed with status %d: %s", resp.StatusCode, string(body))
+		return nil, newOAuthError(resp.StatusCode, resp.Header, body)
 	}
 
-	var tokenResp struct {
-		AccessToken string `json:"access_token"`
-		TokenType   string `json:"token_type"`
-		ExpiresIn   int    `json:"expires_in"`
-		Scope       string `json:"scope,omitempty"`
+	return body, nil
+}
+
+// addTarget adds the client's audience, resource indicators (RFC 8707) and extra parameters to a request.
+// Parameters already set by the grant, such as the audience of a token exchange, are kept.
+func addTarget(client core.Client, data url.Values) {
+	if client.Audience != "" && !data.Has("audience") {
+		data.Set("audience", client.Audience)
 	}
 
-	if err := json.Unmarshal(body, &tokenResp); err != nil {
-		return nil, fmt.Errorf("failed to parse token response: %w", err)
+	if !data.Has("resource") {
+		for _, resource := range client.Resource {
+			data.Add("resource", resource)
+		}
+	}
+
+	for key, value := range client.ExtraParams {
+		if !data.Has(key) {
+			data.Set(key, value)
+		}
+	}
+}
+
+// authenticate adds the client credentials to the token request according to the client's auth method
+func authenticate(client core.Client, data url.Values, header http.Header) error {
+	switch client.AuthMethod() {
+	case core.AuthMethodClientSecretBasic:
+		// RFC 6749 section 2.3.1 requires form-encoding the credentials before base64 encoding them
+		credentials := url.QueryEscape(client.ClientID) + ":" + url.QueryEscape(client.ClientSecret)
+		header.Set("Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(credentials)))
+	case core.AuthMethodPrivateKeyJWT, core.AuthMethodClientSecretJWT:
+		assertion, err := clientAssertion(client, time.Now())
+		if err != nil {
+			return err
+		}
+
+		data.Set("client_id", client.ClientID)
+		data.Set("client_assertion_type", clientAssertionType)
+		data.Set("client_assertion", assertion)
+	case core.AuthMethodNone, core.AuthMethodTLSClientAuth, core.AuthMethodSelfSignedTLSClientAuth:
+		// mTLS clients authenticate with the certificate presented during the TLS handshake
+		data.Set("client_id", client.ClientID)
+	default:
+		data.Set("client_id", client.ClientID)
+		data.Set("client_secret", client.ClientSecret)
+	}
+
+	return nil
+}
+
+// clientAssertion builds the signed client_assertion for the JWT based auth methods
+func clientAssertion(client core.Client, now time.Time) (string, error) {
+	if client.AuthMethod() == core.AuthMethodClientSecretJWT {
+		return secretAssertion(client, now)
 	}
 
-	return &core.Token{
-		AccessToken: tokenResp.AccessToken,
-		TokenType:   tokenResp.TokenType,
-		ExpiresIn:   tokenResp.ExpiresIn,
-		Scope:       tokenResp.Scope,
-	}, nil
+	return privateKeyAssertion(client, now)
 }
@@ -2,6 +2,10 @@ package prov
 
 import (
 	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/base64"
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
@@ -21,7 +25,15 @@ func TestNewOAuthProvider(t *testing.T) {
 	assert.Equal(t, 30*time.Second, provider.httpClient.Timeout)
 }
 
+func TestNewOAuthProvider_WithHTTPSettings(t *testing.T) {
+	provider := NewOAuthProvider(WithHTTPSettings(core.HTTPSettings{ProxyURL: "http://proxy.example.com:3128"}))
+
+	assert.Equal(t, "http://proxy.example.com:3128", provider.settings.ProxyURL)
+}
+
 func TestOAuthProvider_GetToken(t *testing.T) {
+	fastRetries(t)
+
 	tests := []struct {
 		name           string
 		client         core.Client
@@ -95,6 +107,69 @@ func TestOAuthProvider_GetToken(t *testing.T) {
 			},
 			expectedErr: "",
 		},
+		{
+			name: "client_secret_basic sends form-encoded credentials in the Authorization header",
+			client: core.Client{
+				Name:                    "test-client",
+				ClientID:                "test client:id",
+				ClientSecret:            "s3cr%t/+",
+				TokenEndpointAuthMethod: core.AuthMethodClientSecretBasic,
+			},
+			serverResponse: func(w http.ResponseWriter, r *http.Request) {
+				assert.Equal(t, "Basic "+base64.StdEncoding.EncodeToString([]byte("test+client%3Aid:s3cr%25t%2F%2B")),
+					r.Header.Get("Authorization"))
+
+				err := r.ParseForm()
+				require.NoError(t, err)
+				assert.Equal(t, "client_credentials", r.FormValue("grant_type"))
+				assert.False(t, r.Form.Has("client_id"))
+				assert.False(t, r.Form.Has("client_secret"))
+
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusOK)
+				_ = json.NewEncoder(w).Encode(map[string]interface{}{
+					"access_token": "test-access-token",
+					"token_type":   "Bearer",
+					"expires_in":   3600,
+				})
+			},
+			expectedToken: &core.Token{
+				AccessToken: "test-access-token",
+				TokenType:   "Bearer",
+				ExpiresIn:   3600,
+			},
+			expectedErr: "",
+		},
+		{
+			name: "none sends only the client ID",
+			client: core.Client{
+				Name:                    "test-client",
+				ClientID:                "test-client-id",
+				TokenEndpointAuthMethod: core.AuthMethodNone,
+			},
+			serverResponse: func(w http.ResponseWriter, r *http.Request) {
+				assert.Empty(t, r.Header.Get("Authorization"))
+
+				err := r.ParseForm()
+				require.NoError(t, err)
+				assert.Equal(t, "test-client-id", r.FormValue("client_id"))
+				assert.False(t, r.Form.Has("client_secret"))
+
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusOK)
+				_ = json.NewEncoder(w).Encode(map[string]interface{}{
+					"access_token": "test-access-token",
+					"token_type":   "Bearer",
+					"expires_in":   3600,
+				})
+			},
+			expectedToken: &core.Token{
+				AccessToken: "test-access-token",
+				TokenType:   "Bearer",
+				ExpiresIn:   3600,
+			},
+			expectedErr: "",
+		},
 		{
 			name: "server returns 400 bad request",
 			client: core.Client{
@@ -111,7 +186,7 @@ func TestOAuthProvider_GetToken(t *testing.T) {
 				})
 			},
 			expectedToken: nil,
-			expectedErr:   "token request failed with status 400",
+			expectedErr:   "token request failed: invalid_client: Invalid client credentials",
 		},
 		{
 			name: "server returns 401 unauthorized",
@@ -128,7 +203,7 @@ func TestOAuthProvider_GetToken(t *testing.T) {
 				})
 			},
 			expectedToken: nil,
-			expectedErr:   "token request failed with status 401",
+			expectedErr:   "token request failed: unauthorized",
 		},
 		{
 			name: "invalid json response",
@@ -157,7 +232,7 @@ func TestOAuthProvider_GetToken(t *testing.T) {
 				_, _ = w.Write([]byte("Internal Server Error"))
 			},
 			expectedToken: nil,
-			expectedErr:   "token request failed with status 500",
+			expectedErr:   "token request failed: server responded with HTTP 500 Internal Server Error",
 		},
 	}
 
@@ -170,7 +245,7 @@ func TestOAuthProvider_GetToken(t *testing.T) {
 			client.TokenURL = server.URL
 
 			provider := NewOAuthProvider()
-			token, err := provider.GetToken(context.Background(), client)
+			token, err := provider.GetToken(context.Background(), client, core.TokenRequest{})
 
 			if tt.expectedErr != "" {
 				assert.Error(t, err)
@@ -202,7 +277,7 @@ func TestOAuthProvider_GetToken_ContextCancellation(t *testing.T) {
 	}
 
 	provider := NewOAuthProvider()
-	token, err := provider.GetToken(ctx, client)
+	token, err := provider.GetToken(ctx, client, core.TokenRequest{})
 
 	assert.Error(t, err)
 	assert.Nil(t, token)
@@ -218,7 +293,7 @@ func TestOAuthProvider_GetToken_InvalidURL(t *testing.T) {
 	}
 
 	provider := NewOAuthProvider()
-	token, err := provider.GetToken(context.Background(), client)
+	token, err := provider.GetToken(context.Background(), client, core.TokenRequest{})
 
 	assert.Error(t, err)
 	assert.Nil(t, token)
@@ -234,9 +309,262 @@ func TestOAuthProvider_GetToken_NetworkError(t *testing.T) {
 	}
 
 	provider := NewOAuthProvider()
-	token, err := provider.GetToken(context.Background(), client)
+	token, err := provider.GetToken(context.Background(), client, core.TokenRequest{})
 
 	assert.Error(t, err)
 	assert.Nil(t, token)
 	assert.Contains(t, err.Error(), "failed to send request")
 }
+
+func TestOAuthProvider_GetToken_RefreshToken(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, r.ParseForm())
+		assert.Equal(t, "refresh_token", r.FormValue("grant_type"))
+		assert.Equal(t, "openid", r.FormValue("scope"))
+
+		w.Header().Set("Content-Type", "application/json")
+
+		if r.FormValue("refresh_token") != "valid-refresh-token" {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`{"error":"invalid_grant","error_description":"Token is not active"}`))
+
+			return
+		}
+
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"access_token":  "refreshed-token",
+			"token_type":    "Bearer",
+			"expires_in":    300,
+			"refresh_token": "rotated-refresh-token",
+		})
+	}))
+	defer server.Close()
+
+	client := core.Client{
+		ClientID:                "test-client-id",
+		TokenURL:                server.URL,
+		Scopes:                  []string{"openid"},
+		TokenEndpointAuthMethod: core.AuthMethodNone,
+	}
+
+	provider := NewOAuthProvider()
+
+	token, err := provider.GetToken(context.Background(), client, core.TokenRequest{
+		Grant:        core.GrantRefreshToken,
+		RefreshToken: "valid-refresh-token",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "refreshed-token", token.AccessToken)
+	assert.Equal(t, "rotated-refresh-token", token.RefreshToken)
+
+	token, err = provider.GetToken(context.Background(), client, core.TokenRequest{
+		Grant:        core.GrantRefreshToken,
+		RefreshToken: "revoked-refresh-token",
+	})
+	assert.ErrorIs(t, err, core.ErrInvalidGrant)
+	assert.Nil(t, token)
+
+	_, err = provider.GetToken(context.Background(), client, core.TokenRequest{Grant: core.GrantRefreshToken})
+	assert.ErrorContains(t, err, "refresh token is required")
+}
+
+func TestOAuthProvider_GetToken_Password(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, r.ParseForm())
+		assert.Equal(t, "password", r.FormValue("grant_type"))
+		assert.Equal(t, "alice", r.FormValue("username"))
+		assert.Equal(t, "alice-password", r.FormValue("password"))
+		assert.Equal(t, "openid", r.FormValue("scope"))
+		assert.Equal(t, "test-client-secret", r.FormValue("client_secret"))
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"access_token": "user-token",
+			"token_type":   "Bearer",
+			"expires_in":   300,
+		})
+	}))
+	defer server.Close()
+
+	client := core.Client{
+		ClientID:     "test-client-id",
+		ClientSecret: "test-client-secret",
+		TokenURL:     server.URL,
+		Scopes:       []string{"openid"},
+	}
+
+	token, err := NewOAuthProvider().GetToken(context.Background(), client, core.TokenRequest{
+		Grant:    core.GrantPassword,
+		Username: "alice",
+		Password: "alice-password",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "user-token", token.AccessToken)
+
+	_, err = NewOAuthProvider().GetToken(context.Background(), client, core.TokenRequest{Grant: core.GrantPassword, Username: "alice"})
+	assert.ErrorContains(t, err, "username and password are required")
+}
+
+func TestOAuthProvider_GetToken_TokenTarget(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, r.ParseForm())
+		assert.Equal(t, "client_credentials", r.FormValue("grant_type"))
+		assert.Equal(t, "https://api.example.com", r.FormValue("audience"))
+		assert.Equal(t, []string{"https://a.example.com", "https://b.example.com"}, r.Form["resource"])
+		assert.Equal(t, "acme", r.FormValue("organization"))
+		assert.Equal(t, "tenant-1", r.Header.Get("X-Tenant"))
+		assert.Equal(t, "application/x-www-form-urlencoded", r.Header.Get("Content-Type"))
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"access_token": "api-token",
+			"token_type":   "Bearer",
+		})
+	}))
+	defer server.Close()
+
+	client := core.Client{
+		ClientID:     "test-client-id",
+		ClientSecret: "test-client-secret",
+		TokenURL:     server.URL,
+		Audience:     "https://api.example.com",
+		Resource:     []string{"https://a.example.com", "https://b.example.com"},
+		// Extra parameters and headers never replace the ones the grant and client authentication set
+		ExtraParams:  map[string]string{"organization": "acme", "grant_type": "password"},
+		ExtraHeaders: map[string]string{"X-Tenant": "tenant-1", "Content-Type": "text/plain"},
+	}
+
+	token, err := NewOAuthProvider().GetToken(context.Background(), client, core.TokenRequest{Grant: core.GrantClientCredentials})
+	require.NoError(t, err)
+	assert.Equal(t, "api-token", token.AccessToken)
+}
+
+func TestOAuthProvider_GetToken_JWTBearer(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, r.ParseForm())
+		assert.Equal(t, "urn:ietf:params:oauth:grant-type:jwt-bearer", r.FormValue("grant_type"))
+		assert.Equal(t, "https://www.googleapis.com/auth/cloud-platform", r.FormValue("scope"))
+		assert.Empty(t, r.FormValue("client_secret"))
+
+		var header jwtHeader
+		var claims grantClaims
+		decodeJWT(t, r.FormValue("assertion"), &header, &claims)
+		assert.Equal(t, "sa@project.iam.gserviceaccount.com", claims.Iss)
+		assert.Equal(t, "https://www.googleapis.com/auth/cloud-platform", claims.Scope)
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"access_token": "service-account-token",
+			"token_type":   "Bearer",
+			"expires_in":   3599,
+		})
+	}))
+	defer server.Close()
+
+	client := core.Client{
+		ClientID:                "sa@project.iam.gserviceaccount.com",
+		TokenURL:                server.URL,
+		Scopes:                  []string{"https://www.googleapis.com/auth/cloud-platform"},
+		TokenEndpointAuthMethod: core.AuthMethodNone,
+		PrivateKey:              encodePKCS8(t, key),
+	}
+
+	token, err := NewOAuthProvider().GetToken(context.Background(), client, core.TokenRequest{Grant: core.GrantJWTBearer})
+	require.NoError(t, err)
+	assert.Equal(t, "service-account-token", token.AccessToken)
+
+	client.PrivateKey = ""
+	_, err = NewOAuthProvider().GetToken(context.Background(), client, core.TokenRequest{Grant: core.GrantJWTBearer})
+	assert.ErrorContains(t, err, "failed to build grant assertion")
+}
+
+func TestOAuthProvider_GetToken_PrivateKeyJWT(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Empty(t, r.Header.Get("Authorization"))
+
+		err := r.ParseForm()
+		require.NoError(t, err)
+		assert.Equal(t, "client-id", r.FormValue("client_id"))
+		assert.False(t, r.Form.Has("client_secret"))
+		assert.Equal(t, clientAssertionType, r.FormValue("client_assertion_type"))
+
+		var header jwtHeader
+		var claims assertionClaims
+		decodeJWT(t, r.FormValue("client_assertion"), &header, &claims)
+		assert.Equal(t, "ES256", header.Alg)
+		assert.Equal(t, "http://"+r.Host, claims.Aud)
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"access_token": "test-access-token",
+			"token_type":   "Bearer",
+			"expires_in":   3600,
+		})
+	}))
+	defer server.Close()
+
+	provider := NewOAuthProvider()
+	token, err := provider.GetToken(context.Background(), core.Client{
+		Name:                    "test-client",
+		ClientID:                "client-id",
+		TokenURL:                server.URL,
+		TokenEndpointAuthMethod: core.AuthMethodPrivateKeyJWT,
+		PrivateKey:              encodePKCS8(t, key),
+	}, core.TokenRequest{})
+
+	require.NoError(t, err)
+	assert.Equal(t, "test-access-token", token.AccessToken)
+}
+
+func TestOAuthProvider_GetToken_InvalidPrivateKey(t *testing.T) {
+	provider := NewOAuthProvider()
+	token, err := provider.GetToken(context.Background(), core.Client{
+		ClientID:                "client-id",
+		TokenURL:                "http://127.0.0.1:1/token",
+		TokenEndpointAuthMethod: core.AuthMethodPrivateKeyJWT,
+		PrivateKey:              "not a key",
+	}, core.TokenRequest{})
+
+	assert.ErrorContains(t, err, "failed to authenticate client")
+	assert.Nil(t, token)
+}
+
+func TestOAuthProvider_GetToken_ClientSecretJWT(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		err := r.ParseForm()
+		require.NoError(t, err)
+		assert.False(t, r.Form.Has("client_secret"), "the secret must not be sent")
+		assert.Equal(t, clientAssertionType, r.FormValue("client_assertion_type"))
+
+		var header jwtHeader
+		var claims assertionClaims
+		decodeJWT(t, r.FormValue("client_assertion"), &header, &claims)
+		assert.Equal(t, "HS256", header.Alg)
+		assert.Equal(t, "client-id", claims.Sub)
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"access_token": "test-access-token",
+			"token_type":   "Bearer",
+			"expires_in":   3600,
+		})
+	}))
+	defer server.Close()
+
+	provider := NewOAuthProvider()
+	token, err := provider.GetToken(context.Background(), core.Client{
+		ClientID:                "client-id",
+		ClientSecret:            "client-secret",
+		TokenURL:                server.URL,
+		TokenEndpointAuthMethod: core.AuthMethodClientSecretJWT,
+	}, core.TokenRequest{})
+
+	require.NoError(t, err)
+	assert.Equal(t, "test-access-token", token.AccessToken)
+}
@@ -0,0 +1,42 @@
+package repo
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteFileAtomic(t *testing.T) {
+	tmpDir := t.TempDir()
+	path := filepath.Join(tmpDir, "vault.enc")
+
+	err := writeFileAtomic(path, []byte("first"), 0600)
+	require.NoError(t, err)
+
+	err = writeFileAtomic(path, []byte("second"), 0600)
+	require.NoError(t, err)
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("second"), data)
+
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	entries, err := os.ReadDir(tmpDir)
+	require.NoError(t, err)
+	assert.Len(t, entries, 1, "temporary files should not be left behind")
+}
+
+func TestWriteFileAtomic_MissingDirectory(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "missing", "vault.enc")
+
+	err := writeFileAtomic(path, []byte("data"), 0600)
+
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to create temporary file")
+}
@@ -0,0 +1,243 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/ksysoev/authkeeper/pkg/core"
+)
+
+// ListBackups returns the kept vault generations, newest first, with the time each was saved as the vault.
+// Backups that cannot be decrypted with the current password are reported as unreadable.
+func (r *VaultRepository) ListBackups(_ context.Context) ([]core.Backup, error) {
+	var backups []core.Backup
+
+	for i := 1; i <= r.backups; i++ {
+		path := r.backupPath(i)
+
+		info, err := os.Stat(path)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		} else if err != nil {
+			return nil, fmt.Errorf("failed to read backup %d: %w", i, err)
+		}
+
+		backup := core.Backup{
+			Index:   i,
+			SavedAt: info.ModTime(),
+		}
+
+		if data, err := r.readBackup(path); err == nil {
+			backup.Clients = len(data.Clients)
+			backup.Readable = true
+		}
+
+		backups = append(backups, backup)
+	}
+
+	return backups, nil
+}
+
+// RestoreBackup replaces the vault with the given backup generation.
+// The current vault is backed up first, so a restore can itself be undone.
+func (r *VaultRepository) RestoreBackup(ctx context.Context, index int) error {
+	if index < 1 || index > r.backups {
+		return fmt.Errorf("backup %d not found", index)
+	}
+
+	return r.withLock(ctx, func() error {
+		return r.restore(index)
+	})
+}
+
+func (r *VaultRepository) restore(index int) error {
+	path := r.backupPath(index)
+
+	fileData, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("backup %d not found", index)
+	} else if err != nil {
+		return fmt.Errorf("failed to read backup %d: %w", index, err)
+	}
+
+	if _, _, err := r.unseal(fileData); err != nil {
+		return fmt.Errorf("backup %d cannot be decrypted with the current master password: %w", index, err)
+	}
+
+	if err := r.rotateBackups(); err != nil {
+		return err
+	}
+
+	if err := writeFileAtomic(r.path, fileData, 0600); err != nil {
+		return fmt.Errorf("failed to restore backup %d: %w", index, err)
+	}
+
+	return nil
+}
+
+// rekeyBackups re-encrypts the kept backups with the current key after a password change, so a leaked
+// old password opens no generation. Backups the old password cannot decrypt either are removed.
+func (r *VaultRepository) rekeyBackups(oldPassword string) error {
+	for i := 1; i <= r.backups; i++ {
+		path := r.backupPath(i)
+
+		info, err := os.Stat(path)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		} else if err != nil {
+			return fmt.Errorf("failed to read backup %d: %w", i, err)
+		}
+
+		fileData, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("failed to read backup %d: %w", i, err)
+		}
+
+		plaintext, err := decrypt(fileData, oldPassword)
+		if err != nil {
+			if err := os.Remove(path); err != nil {
+				return fmt.Errorf("failed to remove backup %d: %w", i, err)
+			}
+
+			continue
+		}
+
+		h, key, err := r.encryptionKey()
+		if err != nil {
+			return fmt.Errorf("failed to encrypt backup %d: %w", i, err)
+		}
+
+		ciphertext, err := seal(h, key, plaintext)
+		if err != nil {
+			return fmt.Errorf("failed to encrypt backup %d: %w", i, err)
+		}
+
+		if err := writeFileAtomic(path, ciphertext, 0600); err != nil {
+			return fmt.Errorf("failed to write backup %d: %w", i, err)
+		}
+
+		// Backups are listed with their modification time, the time the generation was saved as the vault
+		if err := os.Chtimes(path, info.ModTime(), info.ModTime()); err != nil {
+			return fmt.Errorf("failed to write backup %d: %w", i, err)
+		}
+	}
+
+	return nil
+}
+
+// readBackup decrypts a backup file with the current password
+func (r *VaultRepository) readBackup(path string) (*vaultData, error) {
+	fileData, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	data, _, err := r.unseal(fileData)
+
+	return data, err
+}
+
+// rotateBackups shifts existing backups by one generation and keeps the current vault as backup 1.
+// The oldest generation and any left over from a higher limit are dropped, also when backups are disabled.
+func (r *VaultRepository) rotateBackups() error {
+	if err := r.pruneBackups(); err != nil {
+		return err
+	}
+
+	if r.backups == 0 || !r.Exists() {
+		return nil
+	}
+
+	if err := os.Remove(r.backupPath(r.backups)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to remove oldest backup: %w", err)
+	}
+
+	for i := r.backups - 1; i >= 1; i-- {
+		err := os.Rename(r.backupPath(i), r.backupPath(i+1))
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("failed to rotate backup %d: %w", i, err)
+		}
+	}
+
+	// A hard link keeps the current generation without a window where the vault file is missing
+	if err := os.Link(r.path, r.backupPath(1)); err != nil {
+		if err := copyFile(r.path, r.backupPath(1)); err != nil {
+			return fmt.Errorf("failed to back up vault: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// pruneBackups removes backups with an index above the configured limit. They are kept by an earlier run
+// with more backups and would otherwise stay on disk under an old password, unseen by list and passwd.
+func (r *VaultRepository) pruneBackups() error {
+	dir := filepath.Dir(r.path)
+
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("failed to list backups: %w", err)
+	}
+
+	prefix := filepath.Base(r.path) + ".bak."
+
+	for _, entry := range entries {
+		suffix, ok := strings.CutPrefix(entry.Name(), prefix)
+		if !ok {
+			continue
+		}
+
+		index, err := strconv.Atoi(suffix)
+		if err != nil || index <= r.backups {
+			continue
+		}
+
+		if err := os.Remove(filepath.Join(dir, entry.Name())); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("failed to remove backup %d: %w", index, err)
+		}
+	}
+
+	return nil
+}
+
+func (r *VaultRepository) backupPath(index int) string {
+	return r.path + ".bak." + strconv.Itoa(index)
+}
+
+// copyFile copies src to dst preserving the modification time, used when hard links are unsupported
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = in.Close() }()
+
+	info, err := in.Stat()
+	if err != nil {
+		return err
+	}
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(out, in); err != nil {
+		_ = out.Close()
+		return err
+	}
+
+	if err := out.Close(); err != nil {
+		return err
+	}
+
+	return os.Chtimes(dst, info.ModTime(), info.ModTime())
+}
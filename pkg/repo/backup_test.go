@@ -0,0 +1,210 @@
+package repo
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ksysoev/authkeeper/pkg/core"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewVaultRepository_WithBackups(t *testing.T) {
+	assert.Equal(t, DefaultBackups, NewVaultRepository("/tmp/vault.enc").backups)
+	assert.Equal(t, 2, NewVaultRepository("/tmp/vault.enc", WithBackups(2)).backups)
+	assert.Equal(t, 0, NewVaultRepository("/tmp/vault.enc", WithBackups(-1)).backups)
+}
+
+func TestVaultRepository_RotatesBackups(t *testing.T) {
+	vaultPath := filepath.Join(t.TempDir(), "vault.enc")
+	ctx := context.Background()
+
+	repo := NewVaultRepository(vaultPath, WithBackups(2))
+	require.NoError(t, repo.Load(ctx, "password"))
+
+	for i := 1; i <= 4; i++ {
+		name := fmt.Sprintf("client%d", i)
+		require.NoError(t, repo.Save(ctx, core.Client{Name: name, ClientID: name, ClientSecret: "s", TokenURL: "u"}))
+	}
+
+	backups, err := repo.ListBackups(ctx)
+	require.NoError(t, err)
+	require.Len(t, backups, 2)
+
+	assert.Equal(t, 1, backups[0].Index)
+	assert.True(t, backups[0].Readable)
+	assert.Equal(t, 3, backups[0].Clients)
+	assert.Equal(t, 2, backups[1].Index)
+	assert.Equal(t, 2, backups[1].Clients)
+	assert.False(t, backups[0].SavedAt.IsZero())
+
+	_, err = os.Stat(vaultPath + ".bak.3")
+	assert.True(t, os.IsNotExist(err), "backups beyond the limit must be dropped")
+}
+
+func TestVaultRepository_LowerBackupLimitPrunesOldGenerations(t *testing.T) {
+	vaultPath := filepath.Join(t.TempDir(), "vault.enc")
+	ctx := context.Background()
+
+	repo := NewVaultRepository(vaultPath, WithBackups(5))
+	require.NoError(t, repo.Load(ctx, "password"))
+
+	for i := 1; i <= 6; i++ {
+		name := fmt.Sprintf("client%d", i)
+		require.NoError(t, repo.Save(ctx, core.Client{Name: name, ClientID: name, ClientSecret: "s", TokenURL: "u"}))
+	}
+
+	require.FileExists(t, vaultPath+".bak.5")
+
+	repo = NewVaultRepository(vaultPath, WithBackups(2))
+	require.NoError(t, repo.Load(ctx, "password"))
+	require.NoError(t, repo.Save(ctx, core.Client{Name: "client7", ClientID: "client7", ClientSecret: "s", TokenURL: "u"}))
+
+	for i := 3; i <= 5; i++ {
+		assert.NoFileExists(t, fmt.Sprintf("%s.bak.%d", vaultPath, i), "backups above the new limit must be removed")
+	}
+
+	backups, err := repo.ListBackups(ctx)
+	require.NoError(t, err)
+	assert.Len(t, backups, 2)
+
+	repo = NewVaultRepository(vaultPath, WithBackups(0))
+	require.NoError(t, repo.Load(ctx, "password"))
+	require.NoError(t, repo.Delete(ctx, "client7"))
+
+	assert.NoFileExists(t, vaultPath+".bak.1", "disabling backups must remove the kept generations")
+	assert.NoFileExists(t, vaultPath+".bak.2")
+}
+
+func TestVaultRepository_BackupsDisabled(t *testing.T) {
+	vaultPath := filepath.Join(t.TempDir(), "vault.enc")
+	ctx := context.Background()
+
+	repo := NewVaultRepository(vaultPath, WithBackups(0))
+	require.NoError(t, repo.Load(ctx, "password"))
+
+	require.NoError(t, repo.Save(ctx, core.Client{Name: "client1", ClientID: "id1", ClientSecret: "s1", TokenURL: "u1"}))
+	require.NoError(t, repo.Save(ctx, core.Client{Name: "client2", ClientID: "id2", ClientSecret: "s2", TokenURL: "u2"}))
+
+	backups, err := repo.ListBackups(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, backups)
+
+	err = repo.RestoreBackup(ctx, 1)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "not found")
+}
+
+func TestVaultRepository_RestoreBackup(t *testing.T) {
+	vaultPath := filepath.Join(t.TempDir(), "vault.enc")
+	ctx := context.Background()
+
+	repo := NewVaultRepository(vaultPath)
+	require.NoError(t, repo.Load(ctx, "password"))
+
+	require.NoError(t, repo.Save(ctx, core.Client{Name: "client1", ClientID: "id1", ClientSecret: "s1", TokenURL: "u1"}))
+	require.NoError(t, repo.Save(ctx, core.Client{Name: "client2", ClientID: "id2", ClientSecret: "s2", TokenURL: "u2"}))
+	require.NoError(t, repo.Delete(ctx, "client1"))
+
+	err := repo.RestoreBackup(ctx, 1)
+	require.NoError(t, err)
+
+	names, err := repo.List(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"client1", "client2"}, names)
+
+	// The replaced vault becomes the newest backup, so the restore can be undone
+	backups, err := repo.ListBackups(ctx)
+	require.NoError(t, err)
+	require.NotEmpty(t, backups)
+	assert.Equal(t, 1, backups[0].Clients)
+}
+
+func TestVaultRepository_RestoreBackup_NotFound(t *testing.T) {
+	vaultPath := filepath.Join(t.TempDir(), "vault.enc")
+	ctx := context.Background()
+
+	repo := NewVaultRepository(vaultPath)
+	require.NoError(t, repo.Load(ctx, "password"))
+	require.NoError(t, repo.Save(ctx, core.Client{Name: "client1", ClientID: "id1", ClientSecret: "s1", TokenURL: "u1"}))
+
+	err := repo.RestoreBackup(ctx, 1)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "backup 1 not found")
+
+	err = repo.RestoreBackup(ctx, 0)
+	assert.Error(t, err)
+}
+
+func TestVaultRepository_ChangePassword_RekeysBackups(t *testing.T) {
+	vaultPath := filepath.Join(t.TempDir(), "vault.enc")
+	ctx := context.Background()
+
+	repo := NewVaultRepository(vaultPath)
+	require.NoError(t, repo.Load(ctx, "old-password"))
+	require.NoError(t, repo.Save(ctx, core.Client{Name: "client1", ClientID: "id1", ClientSecret: "s1", TokenURL: "u1"}))
+	require.NoError(t, repo.Save(ctx, core.Client{Name: "client2", ClientID: "id2", ClientSecret: "s2", TokenURL: "u2"}))
+
+	// A generation kept under an even older password cannot be re-encrypted
+	stale, err := encrypt([]byte(`{"clients":[]}`), "older-password")
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(repo.backupPath(2), stale, 0600))
+
+	before, err := os.Stat(repo.backupPath(1))
+	require.NoError(t, err)
+
+	require.NoError(t, repo.ChangePassword(ctx, "old-password", "new-password"))
+
+	backups, err := repo.ListBackups(ctx)
+	require.NoError(t, err)
+	require.Len(t, backups, 1, "backups the old password cannot decrypt must be removed")
+	assert.True(t, backups[0].Readable)
+	assert.Equal(t, 1, backups[0].Clients)
+	assert.True(t, backups[0].SavedAt.Equal(before.ModTime()), "re-encrypting must keep the time the generation was saved")
+
+	fileData, err := os.ReadFile(repo.backupPath(1))
+	require.NoError(t, err)
+	_, err = decrypt(fileData, "old-password")
+	assert.Error(t, err, "the old password must not open backups")
+
+	require.NoError(t, repo.RestoreBackup(ctx, 1))
+
+	repo2 := NewVaultRepository(vaultPath)
+	require.NoError(t, repo2.Load(ctx, "new-password"))
+
+	clients, err := repo2.List(ctx)
+	require.NoError(t, err)
+	require.Len(t, clients, 1)
+	assert.Equal(t, "client1", clients[0])
+}
+
+func TestVaultRepository_ListBackups_KeepsVaultKey(t *testing.T) {
+	vaultPath := filepath.Join(t.TempDir(), "vault.enc")
+	ctx := context.Background()
+
+	repo := NewVaultRepository(vaultPath)
+	require.NoError(t, repo.Load(ctx, "password"))
+	require.NoError(t, repo.Save(ctx, core.Client{Name: "client1", ClientID: "id1", ClientSecret: "s1", TokenURL: "u1"}))
+
+	// A backup encrypted with its own salt derives a different key than the vault
+	backup, err := encrypt([]byte(`{"clients":[]}`), "password")
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(repo.backupPath(1), backup, 0600))
+
+	backups, err := repo.ListBackups(ctx)
+	require.NoError(t, err)
+	require.Len(t, backups, 1)
+	assert.True(t, backups[0].Readable)
+
+	fileData, err := os.ReadFile(vaultPath)
+	require.NoError(t, err)
+
+	h, _, err := parseHeader(fileData)
+	require.NoError(t, err)
+
+	require.NotNil(t, repo.key)
+	assert.True(t, repo.key.header.equal(h), "reading a backup must not replace the vault key")
+}
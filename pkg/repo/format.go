@@ -0,0 +1,262 @@
+package repo
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/pbkdf2"
+)
+
+// Vault file layout (all integers are big-endian):
+//
+//	magic[4] | version[1] | cipher[1] | kdf[1] | time[4] | memory[4] | threads[1] | saltLen[1] | salt | nonce | ciphertext
+//
+// The header (everything before the nonce) is authenticated as GCM additional data.
+// Files that do not start with the magic bytes are treated as legacy headerless
+// vaults: salt[32] | nonce | ciphertext, with PBKDF2-SHA256 at 100k iterations.
+const (
+	formatVersion1  = 1
+	headerFixedSize = 17
+)
+
+var vaultMagic = []byte("AKV\x00")
+
+type kdfID byte
+
+const (
+	kdfPBKDF2SHA256 kdfID = 1
+	kdfArgon2id     kdfID = 2
+)
+
+type cipherID byte
+
+const (
+	cipherAES256GCM cipherID = 1
+)
+
+// kdfParams describes how the encryption key is derived from the master password
+type kdfParams struct {
+	ID      kdfID
+	Time    uint32 // iterations for PBKDF2, passes for Argon2id
+	Memory  uint32 // memory in KiB, Argon2id only
+	Threads uint8  // parallelism, Argon2id only
+}
+
+// defaultKDF is used for every newly written vault
+var defaultKDF = kdfParams{
+	ID:      kdfArgon2id,
+	Time:    3,
+	Memory:  64 * 1024,
+	Threads: 4,
+}
+
+// legacyKDF matches the parameters of headerless vaults
+var legacyKDF = kdfParams{
+	ID:   kdfPBKDF2SHA256,
+	Time: iterations,
+}
+
+// header is the self-describing prefix of a vault file
+type header struct {
+	Version byte
+	Cipher  cipherID
+	KDF     kdfParams
+	Salt    []byte
+	legacy  bool
+}
+
+// newHeader creates a header with a fresh random salt for the given KDF parameters
+func newHeader(params kdfParams) (header, error) {
+	salt := make([]byte, saltSize)
+	if _, err := io.ReadFull(rand.Reader, salt); err != nil {
+		return header{}, fmt.Errorf("failed to generate salt: %w", err)
+	}
+
+	return header{
+		Version: formatVersion1,
+		Cipher:  cipherAES256GCM,
+		KDF:     params,
+		Salt:    salt,
+	}, nil
+}
+
+// marshal encodes the header into its binary form, legacy headers encode to the bare salt
+func (h header) marshal() []byte {
+	if h.legacy {
+		return append([]byte(nil), h.Salt...)
+	}
+
+	buf := make([]byte, 0, headerFixedSize+len(h.Salt))
+	buf = append(buf, vaultMagic...)
+	buf = append(buf, h.Version, byte(h.Cipher), byte(h.KDF.ID))
+	buf = binary.BigEndian.AppendUint32(buf, h.KDF.Time)
+	buf = binary.BigEndian.AppendUint32(buf, h.KDF.Memory)
+	buf = append(buf, h.KDF.Threads, byte(len(h.Salt)))
+	buf = append(buf, h.Salt...)
+
+	return buf
+}
+
+// equal reports whether both headers describe the same key derivation
+func (h header) equal(other header) bool {
+	return h.legacy == other.legacy && h.KDF == other.KDF && bytes.Equal(h.Salt, other.Salt)
+}
+
+// aad returns the additional authenticated data bound to the ciphertext
+func (h header) aad() []byte {
+	if h.legacy {
+		return nil
+	}
+
+	return h.marshal()
+}
+
+// parseHeader decodes the vault header and returns it together with the remaining payload
+func parseHeader(data []byte) (header, []byte, error) {
+	if !bytes.HasPrefix(data, vaultMagic) {
+		if len(data) < saltSize {
+			return header{}, nil, fmt.Errorf("ciphertext too short")
+		}
+
+		return header{
+			Cipher: cipherAES256GCM,
+			KDF:    legacyKDF,
+			Salt:   data[:saltSize],
+			legacy: true,
+		}, data[saltSize:], nil
+	}
+
+	if len(data) < headerFixedSize {
+		return header{}, nil, fmt.Errorf("vault header too short")
+	}
+
+	h := header{
+		Version: data[4],
+		Cipher:  cipherID(data[5]),
+		KDF: kdfParams{
+			ID:      kdfID(data[6]),
+			Time:    binary.BigEndian.Uint32(data[7:11]),
+			Memory:  binary.BigEndian.Uint32(data[11:15]),
+			Threads: data[15],
+		},
+	}
+
+	if h.Version != formatVersion1 {
+		return header{}, nil, fmt.Errorf("unsupported vault format version %d", h.Version)
+	}
+
+	if h.Cipher != cipherAES256GCM {
+		return header{}, nil, fmt.Errorf("unsupported vault cipher %d", h.Cipher)
+	}
+
+	if err := h.KDF.validate(); err != nil {
+		return header{}, nil, err
+	}
+
+	saltLen := int(data[16])
+	if len(data) < headerFixedSize+saltLen {
+		return header{}, nil, fmt.Errorf("vault header too short")
+	}
+
+	h.Salt = data[headerFixedSize : headerFixedSize+saltLen]
+
+	return h, data[headerFixedSize+saltLen:], nil
+}
+
+// validate rejects unknown KDFs and parameters that are too weak or too expensive
+func (p kdfParams) validate() error {
+	switch p.ID {
+	case kdfPBKDF2SHA256:
+		if p.Time < 10000 {
+			return fmt.Errorf("PBKDF2 iteration count %d is too low", p.Time)
+		}
+	case kdfArgon2id:
+		if p.Time < 1 || p.Time > 64 {
+			return fmt.Errorf("invalid Argon2id time parameter %d", p.Time)
+		}
+
+		if p.Memory < 8*1024 || p.Memory > 1024*1024 {
+			return fmt.Errorf("invalid Argon2id memory parameter %d KiB", p.Memory)
+		}
+
+		if p.Threads < 1 {
+			return fmt.Errorf("invalid Argon2id parallelism %d", p.Threads)
+		}
+	default:
+		return fmt.Errorf("unsupported key derivation function %d", p.ID)
+	}
+
+	return nil
+}
+
+// deriveKey derives the encryption key from the password using the configured KDF
+func (p kdfParams) deriveKey(password string, salt []byte) ([]byte, error) {
+	switch p.ID {
+	case kdfPBKDF2SHA256:
+		return pbkdf2.Key([]byte(password), salt, int(p.Time), keySize, sha256.New), nil
+	case kdfArgon2id:
+		return argon2.IDKey([]byte(password), salt, p.Time, p.Memory, p.Threads, keySize), nil
+	default:
+		return nil, fmt.Errorf("unsupported key derivation function %d", p.ID)
+	}
+}
+
+// seal encrypts plaintext with the derived key and prepends the header and nonce
+func seal(h header, key, plaintext []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+
+	result := h.marshal()
+	result = append(result, nonce...)
+
+	return gcm.Seal(result, nonce, plaintext, h.aad()), nil
+}
+
+// open decrypts the payload that follows the header with the derived key
+func open(h header, key, payload []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(payload) < gcm.NonceSize() {
+		return nil, fmt.Errorf("ciphertext too short")
+	}
+
+	nonce := payload[:gcm.NonceSize()]
+
+	plaintext, err := gcm.Open(nil, nonce, payload[gcm.NonceSize():], h.aad())
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt: %w", err)
+	}
+
+	return plaintext, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create GCM: %w", err)
+	}
+
+	return gcm, nil
+}
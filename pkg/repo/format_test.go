@@ -0,0 +1,140 @@
+package repo
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// encryptLegacy produces a headerless vault blob as written by older versions
+func encryptLegacy(t *testing.T, plaintext []byte, password string) []byte {
+	t.Helper()
+
+	h, err := newHeader(legacyKDF)
+	require.NoError(t, err)
+
+	h.legacy = true
+
+	key, err := h.KDF.deriveKey(password, h.Salt)
+	require.NoError(t, err)
+
+	data, err := seal(h, key, plaintext)
+	require.NoError(t, err)
+
+	return data
+}
+
+func TestHeader_MarshalParse(t *testing.T) {
+	h, err := newHeader(defaultKDF)
+	require.NoError(t, err)
+
+	data := append(h.marshal(), []byte("payload")...)
+
+	parsed, rest, err := parseHeader(data)
+	require.NoError(t, err)
+
+	assert.Equal(t, byte(formatVersion1), parsed.Version)
+	assert.Equal(t, cipherAES256GCM, parsed.Cipher)
+	assert.Equal(t, defaultKDF, parsed.KDF)
+	assert.Equal(t, h.Salt, parsed.Salt)
+	assert.False(t, parsed.legacy)
+	assert.True(t, h.equal(parsed))
+	assert.Equal(t, []byte("payload"), rest)
+}
+
+func TestParseHeader_Legacy(t *testing.T) {
+	data := make([]byte, saltSize+16)
+
+	h, rest, err := parseHeader(data)
+	require.NoError(t, err)
+
+	assert.True(t, h.legacy)
+	assert.Equal(t, legacyKDF, h.KDF)
+	assert.Len(t, h.Salt, saltSize)
+	assert.Len(t, rest, 16)
+}
+
+func TestParseHeader_Invalid(t *testing.T) {
+	valid, err := newHeader(defaultKDF)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name        string
+		mutate      func([]byte) []byte
+		expectedErr string
+	}{
+		{
+			name:        "truncated header",
+			mutate:      func(b []byte) []byte { return b[:headerFixedSize-1] },
+			expectedErr: "vault header too short",
+		},
+		{
+			name:        "truncated salt",
+			mutate:      func(b []byte) []byte { return b[:headerFixedSize+4] },
+			expectedErr: "vault header too short",
+		},
+		{
+			name:        "unknown version",
+			mutate:      func(b []byte) []byte { b[4] = 99; return b },
+			expectedErr: "unsupported vault format version 99",
+		},
+		{
+			name:        "unknown cipher",
+			mutate:      func(b []byte) []byte { b[5] = 99; return b },
+			expectedErr: "unsupported vault cipher 99",
+		},
+		{
+			name:        "unknown kdf",
+			mutate:      func(b []byte) []byte { b[6] = 99; return b },
+			expectedErr: "unsupported key derivation function 99",
+		},
+		{
+			name:        "zero argon2 time",
+			mutate:      func(b []byte) []byte { copy(b[7:11], []byte{0, 0, 0, 0}); return b },
+			expectedErr: "invalid Argon2id time parameter",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := parseHeader(tt.mutate(valid.marshal()))
+
+			assert.Error(t, err)
+			assert.Contains(t, err.Error(), tt.expectedErr)
+		})
+	}
+}
+
+func TestKDFParams_Validate(t *testing.T) {
+	assert.NoError(t, defaultKDF.validate())
+	assert.NoError(t, legacyKDF.validate())
+
+	assert.Error(t, kdfParams{ID: kdfPBKDF2SHA256, Time: 1000}.validate())
+	assert.Error(t, kdfParams{ID: kdfArgon2id, Time: 1, Memory: 1024, Threads: 1}.validate())
+	assert.Error(t, kdfParams{ID: kdfArgon2id, Time: 1, Memory: 64 * 1024, Threads: 0}.validate())
+}
+
+func TestDecrypt_Legacy(t *testing.T) {
+	plaintext := []byte("legacy data")
+
+	data := encryptLegacy(t, plaintext, "password")
+
+	decrypted, err := decrypt(data, "password")
+	require.NoError(t, err)
+	assert.Equal(t, plaintext, decrypted)
+
+	_, err = decrypt(data, "wrong-password")
+	assert.Error(t, err)
+}
+
+func TestDecrypt_TamperedHeader(t *testing.T) {
+	ciphertext, err := encrypt([]byte("test data"), "password")
+	require.NoError(t, err)
+
+	// Flip a salt bit so neither the derived key nor the authenticated header match
+	ciphertext[headerFixedSize] ^= 0x01
+
+	_, err = decrypt(ciphertext, "password")
+	assert.Error(t, err)
+}
@@ -0,0 +1,67 @@
+package repo
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/ksysoev/authkeeper/pkg/core"
+)
+
+// lockTimeout is how long a writer waits for another process to release the vault lock
+var lockTimeout = 3 * time.Second
+
+const lockRetryInterval = 50 * time.Millisecond
+
+// fileLock is an advisory lock held on a sidecar file next to the vault
+type fileLock struct {
+	f *os.File
+}
+
+// acquireLock takes an exclusive advisory lock on path, waiting up to lockTimeout for other holders
+func acquireLock(ctx context.Context, path string) (*fileLock, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open lock file: %w", err)
+	}
+
+	timeout, cancel := context.WithTimeout(ctx, lockTimeout)
+	defer cancel()
+
+	for {
+		locked, err := tryLock(f)
+		if err != nil {
+			_ = f.Close()
+			return nil, fmt.Errorf("failed to lock vault: %w", err)
+		}
+
+		if locked {
+			return &fileLock{f: f}, nil
+		}
+
+		select {
+		case <-timeout.Done():
+			_ = f.Close()
+
+			// Only our own deadline means the lock is held, a cancelled caller gets its own error
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+
+			return nil, fmt.Errorf("%w by another authkeeper process (lock file %s), try again later", core.ErrVaultLocked, path)
+		case <-time.After(lockRetryInterval):
+		}
+	}
+}
+
+// release unlocks and closes the lock file
+func (l *fileLock) release() error {
+	err := unlock(l.f)
+
+	if cerr := l.f.Close(); err == nil {
+		err = cerr
+	}
+
+	return err
+}
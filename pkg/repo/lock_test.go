@@ -0,0 +1,137 @@
+package repo
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ksysoev/authkeeper/pkg/core"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAcquireLock(t *testing.T) {
+	lockPath := filepath.Join(t.TempDir(), "vault.enc.lock")
+	ctx := context.Background()
+
+	lock, err := acquireLock(ctx, lockPath)
+	require.NoError(t, err)
+
+	require.NoError(t, lock.release())
+
+	lock, err = acquireLock(ctx, lockPath)
+	require.NoError(t, err)
+	require.NoError(t, lock.release())
+}
+
+func TestAcquireLock_HeldByAnother(t *testing.T) {
+	lockPath := filepath.Join(t.TempDir(), "vault.enc.lock")
+	ctx := context.Background()
+
+	prev := lockTimeout
+	lockTimeout = 200 * time.Millisecond
+	t.Cleanup(func() { lockTimeout = prev })
+
+	held, err := acquireLock(ctx, lockPath)
+	require.NoError(t, err)
+	defer func() { _ = held.release() }()
+
+	_, err = acquireLock(ctx, lockPath)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "vault is locked by another authkeeper process")
+	assert.ErrorIs(t, err, core.ErrVaultLocked)
+}
+
+func TestAcquireLock_ContextDone(t *testing.T) {
+	lockPath := filepath.Join(t.TempDir(), "vault.enc.lock")
+
+	held, err := acquireLock(context.Background(), lockPath)
+	require.NoError(t, err)
+	defer func() { _ = held.release() }()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err = acquireLock(ctx, lockPath)
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.NotErrorIs(t, err, core.ErrVaultLocked)
+
+	ctx, cancel = context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	_, err = acquireLock(ctx, lockPath)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.NotErrorIs(t, err, core.ErrVaultLocked)
+}
+
+func TestVaultRepository_Save_Locked(t *testing.T) {
+	vaultPath := filepath.Join(t.TempDir(), "vault.enc")
+	ctx := context.Background()
+
+	prev := lockTimeout
+	lockTimeout = 200 * time.Millisecond
+	t.Cleanup(func() { lockTimeout = prev })
+
+	repo := NewVaultRepository(vaultPath)
+	require.NoError(t, repo.Load(ctx, "password"))
+
+	held, err := acquireLock(ctx, vaultPath+".lock")
+	require.NoError(t, err)
+	defer func() { _ = held.release() }()
+
+	err = repo.Save(ctx, core.Client{Name: "client1", ClientID: "id1", ClientSecret: "s1", TokenURL: "u1"})
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "locked")
+
+	err = repo.Delete(ctx, "client1")
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "locked")
+}
+
+func TestVaultRepository_ConcurrentSaves(t *testing.T) {
+	vaultPath := filepath.Join(t.TempDir(), "vault.enc")
+	ctx := context.Background()
+
+	prev := lockTimeout
+	lockTimeout = 30 * time.Second
+	t.Cleanup(func() { lockTimeout = prev })
+
+	initial := NewVaultRepository(vaultPath)
+	require.NoError(t, initial.Load(ctx, "password"))
+	require.NoError(t, initial.Save(ctx, core.Client{Name: "client0", ClientID: "id0", ClientSecret: "s0", TokenURL: "u0"}))
+
+	const writers = 4
+
+	repos := make([]*VaultRepository, writers)
+	for i := range repos {
+		repos[i] = NewVaultRepository(vaultPath)
+		require.NoError(t, repos[i].Load(ctx, "password"))
+	}
+
+	var wg sync.WaitGroup
+	errs := make([]error, writers)
+
+	for i, r := range repos {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			name := fmt.Sprintf("client%d", i+1)
+			errs[i] = r.Save(ctx, core.Client{Name: name, ClientID: name, ClientSecret: "s", TokenURL: "u"})
+		}()
+	}
+
+	wg.Wait()
+
+	for _, err := range errs {
+		require.NoError(t, err)
+	}
+
+	names, err := initial.List(ctx)
+	require.NoError(t, err)
+	assert.Len(t, names, writers+1, "no update may be lost")
+}
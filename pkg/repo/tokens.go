@@ -0,0 +1,144 @@
+package repo
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	"github.com/ksysoev/authkeeper/pkg/core"
+)
+
+// tokenData is a cached access token, stored inside the encrypted vault
+type tokenData struct {
+	Client         string    `json:"client"`
+	Key            string    `json:"key"`
+	AccessToken    string    `json:"access_token"`
+	TokenType      string    `json:"token_type"`
+	ExpiresIn      int       `json:"expires_in"`
+	Scope          string    `json:"scope,omitempty"`
+	IssuedAt       time.Time `json:"issued_at"`
+	CertThumbprint string    `json:"cnf_x5t_s256,omitempty"`
+}
+
+// refreshTokenData is the refresh token of a client, stored inside the encrypted vault
+type refreshTokenData struct {
+	Client string `json:"client"`
+	Token  string `json:"token"`
+}
+
+// GetCachedToken returns the cached token for the client and cache key, or nil when there is none
+func (r *VaultRepository) GetCachedToken(_ context.Context, clientName, key string) (*core.Token, error) {
+	data, err := r.load()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, t := range data.Tokens {
+		if t.Client == clientName && t.Key == key {
+			token := toToken(t)
+			return &token, nil
+		}
+	}
+
+	return nil, nil
+}
+
+// SaveCachedToken stores a token in the cache, replacing any previous token for the same key.
+// Cache writes don't rotate backups, they would otherwise push out real vault generations.
+func (r *VaultRepository) SaveCachedToken(ctx context.Context, clientName, key string, token core.Token) error {
+	return r.withLock(ctx, func() error {
+		return r.saveToken(clientName, key, token)
+	})
+}
+
+func (r *VaultRepository) saveToken(clientName, key string, token core.Token) error {
+	data, err := r.load()
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+
+	data.Tokens = slices.DeleteFunc(data.Tokens, func(t tokenData) bool {
+		return (t.Client == clientName && t.Key == key) || t.expired(now)
+	})
+
+	data.Tokens = append(data.Tokens, toTokenData(clientName, key, token))
+
+	return r.save(data)
+}
+
+// GetRefreshToken returns the stored refresh token of the client, or an empty string when there is none
+func (r *VaultRepository) GetRefreshToken(_ context.Context, clientName string) (string, error) {
+	data, err := r.load()
+	if err != nil {
+		return "", err
+	}
+
+	for _, t := range data.RefreshTokens {
+		if t.Client == clientName {
+			return t.Token, nil
+		}
+	}
+
+	return "", nil
+}
+
+// SaveRefreshToken stores the refresh token of the client, replacing a rotated one. An empty token removes it.
+func (r *VaultRepository) SaveRefreshToken(ctx context.Context, clientName, refreshToken string) error {
+	return r.withLock(ctx, func() error {
+		data, err := r.load()
+		if err != nil {
+			return err
+		}
+
+		data.RefreshTokens = slices.DeleteFunc(data.RefreshTokens, func(t refreshTokenData) bool {
+			return t.Client == clientName
+		})
+
+		if refreshToken != "" {
+			data.RefreshTokens = append(data.RefreshTokens, refreshTokenData{Client: clientName, Token: refreshToken})
+		}
+
+		return r.save(data)
+	})
+}
+
+// dropTokens removes all cached and refresh tokens of a client, used when the client changes or is deleted
+func (d *vaultData) dropTokens(clientName string) {
+	d.Tokens = slices.DeleteFunc(d.Tokens, func(t tokenData) bool {
+		return t.Client == clientName
+	})
+
+	d.RefreshTokens = slices.DeleteFunc(d.RefreshTokens, func(t refreshTokenData) bool {
+		return t.Client == clientName
+	})
+}
+
+func (t tokenData) expired(now time.Time) bool {
+	return !now.Before(t.IssuedAt.Add(time.Duration(t.ExpiresIn) * time.Second))
+}
+
+func toTokenData(clientName, key string, t core.Token) tokenData {
+	return tokenData{
+		Client:         clientName,
+		Key:            key,
+		AccessToken:    t.AccessToken,
+		TokenType:      t.TokenType,
+		ExpiresIn:      t.ExpiresIn,
+		Scope:          t.Scope,
+		IssuedAt:       t.IssuedAt,
+		CertThumbprint: t.CertThumbprint,
+	}
+}
+
+func toToken(t tokenData) core.Token {
+	return core.Token{
+		AccessToken:    t.AccessToken,
+		TokenType:      t.TokenType,
+		ExpiresIn:      t.ExpiresIn,
+		Scope:          t.Scope,
+		IssuedAt:       t.IssuedAt,
+		CertThumbprint: t.CertThumbprint,
+	}
+}
@@ -0,0 +1,189 @@
+package repo
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ksysoev/authkeeper/pkg/core"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVaultRepository_CachedToken(t *testing.T) {
+	vaultPath := filepath.Join(t.TempDir(), "vault.enc")
+	ctx := context.Background()
+
+	repo := NewVaultRepository(vaultPath)
+	require.NoError(t, repo.Load(ctx, "password"))
+	require.NoError(t, repo.Save(ctx, core.Client{Name: "client1", ClientID: "id1", ClientSecret: "s1", TokenURL: "u1"}))
+
+	require.NoError(t, repo.Save(ctx, core.Client{Name: "client2", ClientID: "id2", ClientSecret: "s2", TokenURL: "u2"}))
+
+	token, err := repo.GetCachedToken(ctx, "client1", "client1|")
+	require.NoError(t, err)
+	assert.Nil(t, token)
+
+	issued := core.Token{
+		AccessToken:    "secret-access-token",
+		TokenType:      "Bearer",
+		ExpiresIn:      3600,
+		Scope:          "read",
+		IssuedAt:       time.Now().UTC().Truncate(time.Second),
+		CertThumbprint: "thumbprint",
+	}
+	require.NoError(t, repo.SaveCachedToken(ctx, "client1", "client1|", issued))
+
+	// A fresh repository must read the token back from disk
+	repo2 := NewVaultRepository(vaultPath)
+	require.NoError(t, repo2.Load(ctx, "password"))
+
+	token, err = repo2.GetCachedToken(ctx, "client1", "client1|")
+	require.NoError(t, err)
+	require.NotNil(t, token)
+	assert.Equal(t, issued, *token)
+
+	token, err = repo2.GetCachedToken(ctx, "client1", "client1|other")
+	require.NoError(t, err)
+	assert.Nil(t, token)
+
+	raw, err := os.ReadFile(vaultPath)
+	require.NoError(t, err)
+	assert.False(t, bytes.Contains(raw, []byte("secret-access-token")), "cached tokens must be encrypted")
+
+	backups, err := repo2.ListBackups(ctx)
+	require.NoError(t, err)
+	assert.Len(t, backups, 1, "caching a token must not rotate backups")
+}
+
+func TestVaultRepository_CachedToken_ReplacesAndPrunes(t *testing.T) {
+	vaultPath := filepath.Join(t.TempDir(), "vault.enc")
+	ctx := context.Background()
+
+	repo := NewVaultRepository(vaultPath)
+	require.NoError(t, repo.Load(ctx, "password"))
+
+	now := time.Now()
+	require.NoError(t, repo.SaveCachedToken(ctx, "client1", "k1", core.Token{AccessToken: "expired", ExpiresIn: 60, IssuedAt: now.Add(-time.Hour)}))
+	require.NoError(t, repo.SaveCachedToken(ctx, "client2", "k2", core.Token{AccessToken: "old", ExpiresIn: 3600, IssuedAt: now}))
+	require.NoError(t, repo.SaveCachedToken(ctx, "client2", "k2", core.Token{AccessToken: "new", ExpiresIn: 3600, IssuedAt: now}))
+
+	data, err := repo.load()
+	require.NoError(t, err)
+	require.Len(t, data.Tokens, 1)
+	assert.Equal(t, "new", data.Tokens[0].AccessToken)
+}
+
+func TestVaultRepository_CachedToken_DroppedWithClient(t *testing.T) {
+	vaultPath := filepath.Join(t.TempDir(), "vault.enc")
+	ctx := context.Background()
+
+	repo := NewVaultRepository(vaultPath, WithBackups(0))
+	require.NoError(t, repo.Load(ctx, "password"))
+
+	client := core.Client{Name: "client1", ClientID: "id1", ClientSecret: "s1", TokenURL: "u1"}
+	require.NoError(t, repo.Save(ctx, client))
+
+	token := core.Token{AccessToken: "token", ExpiresIn: 3600, IssuedAt: time.Now()}
+
+	require.NoError(t, repo.SaveCachedToken(ctx, "client1", "k", token))
+	client.ClientSecret = "rotated"
+	require.NoError(t, repo.Update(ctx, client))
+
+	cached, err := repo.GetCachedToken(ctx, "client1", "k")
+	require.NoError(t, err)
+	assert.Nil(t, cached, "updating a client must invalidate its cached tokens")
+
+	require.NoError(t, repo.SaveCachedToken(ctx, "client1", "k", token))
+	require.NoError(t, repo.Delete(ctx, "client1"))
+
+	cached, err = repo.GetCachedToken(ctx, "client1", "k")
+	require.NoError(t, err)
+	assert.Nil(t, cached, "deleting a client must drop its cached tokens")
+}
+
+func TestVaultRepository_RefreshToken(t *testing.T) {
+	vaultPath := filepath.Join(t.TempDir(), "vault.enc")
+	ctx := context.Background()
+
+	repo := NewVaultRepository(vaultPath)
+	require.NoError(t, repo.Load(ctx, "password"))
+
+	client := core.Client{Name: "client1", ClientID: "id1", TokenURL: "u1", TokenEndpointAuthMethod: core.AuthMethodNone}
+	require.NoError(t, repo.Save(ctx, client))
+
+	refreshToken, err := repo.GetRefreshToken(ctx, "client1")
+	require.NoError(t, err)
+	assert.Empty(t, refreshToken)
+
+	require.NoError(t, repo.SaveRefreshToken(ctx, "client1", "secret-refresh-token"))
+	require.NoError(t, repo.SaveRefreshToken(ctx, "client1", "rotated-refresh-token"))
+
+	// A fresh repository must read the rotated token back from disk
+	repo2 := NewVaultRepository(vaultPath)
+	require.NoError(t, repo2.Load(ctx, "password"))
+
+	refreshToken, err = repo2.GetRefreshToken(ctx, "client1")
+	require.NoError(t, err)
+	assert.Equal(t, "rotated-refresh-token", refreshToken)
+
+	raw, err := os.ReadFile(vaultPath)
+	require.NoError(t, err)
+	assert.False(t, bytes.Contains(raw, []byte("rotated-refresh-token")), "refresh tokens must be encrypted")
+
+	backups, err := repo2.ListBackups(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, backups, "storing a refresh token must not rotate backups")
+
+	require.NoError(t, repo2.SaveRefreshToken(ctx, "client1", ""))
+
+	refreshToken, err = repo2.GetRefreshToken(ctx, "client1")
+	require.NoError(t, err)
+	assert.Empty(t, refreshToken)
+
+	require.NoError(t, repo2.SaveRefreshToken(ctx, "client1", "refresh-token"))
+	require.NoError(t, repo2.Delete(ctx, "client1"))
+
+	refreshToken, err = repo2.GetRefreshToken(ctx, "client1")
+	require.NoError(t, err)
+	assert.Empty(t, refreshToken, "deleting a client must drop its refresh token")
+}
+
+func TestVaultRepository_UpdateEndpoints_KeepsTokens(t *testing.T) {
+	vaultPath := filepath.Join(t.TempDir(), "vault.enc")
+	ctx := context.Background()
+
+	repo := NewVaultRepository(vaultPath)
+	require.NoError(t, repo.Load(ctx, "password"))
+
+	client := core.Client{Name: "client1", ClientID: "id1", Issuer: "https://issuer", TokenURL: "u1"}
+	require.NoError(t, repo.Save(ctx, client))
+	require.NoError(t, repo.SaveRefreshToken(ctx, "client1", "refresh-token"))
+
+	before, err := repo.ListBackups(ctx)
+	require.NoError(t, err)
+
+	client.TokenURL = "u2"
+	client.AuthorizationURL = "a2"
+	client.EndpointsResolvedAt = time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	require.NoError(t, repo.UpdateEndpoints(ctx, client))
+
+	stored, err := repo.Get(ctx, "client1")
+	require.NoError(t, err)
+	assert.Equal(t, "u2", stored.TokenURL)
+	assert.Equal(t, "a2", stored.AuthorizationURL)
+	assert.True(t, stored.EndpointsResolvedAt.Equal(client.EndpointsResolvedAt))
+
+	refreshToken, err := repo.GetRefreshToken(ctx, "client1")
+	require.NoError(t, err)
+	assert.Equal(t, "refresh-token", refreshToken, "re-resolved endpoints must not drop the refresh token")
+
+	after, err := repo.ListBackups(ctx)
+	require.NoError(t, err)
+	assert.Len(t, after, len(before), "re-resolved endpoints must not rotate backups")
+
+	assert.ErrorIs(t, repo.UpdateEndpoints(ctx, core.Client{Name: "missing"}), core.ErrClientNotFound)
+}
@@ -2,21 +2,18 @@ package repo
 
 import (
 	"context"
-	"crypto/aes"
-	"crypto/cipher"
-	"crypto/rand"
-	"crypto/sha256"
 	"encoding/json"
 	"fmt"
-	"io"
 	"os"
 	"path/filepath"
 	"time"
 
 	"github.com/ksysoev/authkeeper/pkg/core"
-	"golang.org/x/crypto/pbkdf2"
 )
 
+// DefaultBackups is the number of previous vault generations kept when WithBackups is not given
+const DefaultBackups = 5
+
 const (
 	saltSize   = 32
 	iterations = 100000
@@ -25,28 +22,86 @@ const (
 
 type vaultData struct {
 	Clients []clientData `json:"clients"`
+	Tokens  []tokenData  `json:"tokens,omitempty"`
+	// RefreshTokens are kept apart from cached tokens, they outlive the access tokens they were issued with
+	RefreshTokens []refreshTokenData `json:"refresh_tokens,omitempty"`
 }
 
 type clientData struct {
-	Name         string    `json:"name"`
-	ClientID     string    `json:"client_id"`
-	ClientSecret string    `json:"client_secret"`
-	TokenURL     string    `json:"token_url"`
-	Scopes       []string  `json:"scopes,omitempty"`
-	CreatedAt    time.Time `json:"created_at"`
+	Name                    string            `json:"name"`
+	ClientID                string            `json:"client_id"`
+	ClientSecret            string            `json:"client_secret"`
+	TokenURL                string            `json:"token_url"`
+	Issuer                  string            `json:"issuer,omitempty"`
+	Scopes                  []string          `json:"scopes,omitempty"`
+	TokenEndpointAuthMethod string            `json:"token_endpoint_auth_method,omitempty"`
+	PrivateKey              string            `json:"private_key,omitempty"`
+	KeyID                   string            `json:"key_id,omitempty"`
+	SigningAlgorithm        string            `json:"signing_algorithm,omitempty"`
+	TLSCertificate          string            `json:"tls_certificate,omitempty"`
+	TLSKey                  string            `json:"tls_key,omitempty"`
+	GrantType               string            `json:"grant_type,omitempty"`
+	AuthorizationURL        string            `json:"authorization_url,omitempty"`
+	RedirectURL             string            `json:"redirect_url,omitempty"`
+	DeviceAuthorizationURL  string            `json:"device_authorization_url,omitempty"`
+	EndpointsResolvedAt     time.Time         `json:"endpoints_resolved_at,omitzero"`
+	AssertionIssuer         string            `json:"assertion_issuer,omitempty"`
+	AssertionSubject        string            `json:"assertion_subject,omitempty"`
+	AssertionAudience       string            `json:"assertion_audience,omitempty"`
+	Username                string            `json:"username,omitempty"`
+	Password                string            `json:"password,omitempty"`
+	Audience                string            `json:"audience,omitempty"`
+	Resource                []string          `json:"resource,omitempty"`
+	ExtraParams             map[string]string `json:"extra_params,omitempty"`
+	ExtraHeaders            map[string]string `json:"extra_headers,omitempty"`
+	HTTPTimeout             int64             `json:"http_timeout,omitempty"`
+	ProxyURL                string            `json:"proxy_url,omitempty"`
+	CACertificates          string            `json:"ca_certificates,omitempty"`
+	TLSMinVersion           string            `json:"tls_min_version,omitempty"`
+	Retries                 *int              `json:"retries,omitempty"`
+	PinnedPublicKey         string            `json:"pinned_public_key,omitempty"`
+	InsecureSkipVerify      bool              `json:"insecure_skip_verify,omitempty"`
+	CreatedAt               time.Time         `json:"created_at"`
+	UpdatedAt               time.Time         `json:"updated_at,omitzero"`
+}
+
+// vaultKey caches the key derived for the unlocked vault, so the KDF runs once per session
+type vaultKey struct {
+	password string
+	header   header
+	key      []byte
 }
 
 // VaultRepository implements core.Repository interface using encrypted file storage
 type VaultRepository struct {
 	path     string
 	password string
+	key      *vaultKey
+	backups  int
+}
+
+// Option configures a VaultRepository
+type Option func(*VaultRepository)
+
+// WithBackups sets how many previous encrypted generations of the vault are kept, 0 disables backups
+func WithBackups(n int) Option {
+	return func(r *VaultRepository) {
+		r.backups = max(n, 0)
+	}
 }
 
 // NewVaultRepository creates a new vault repository
-func NewVaultRepository(path string) *VaultRepository {
-	return &VaultRepository{
-		path: path,
+func NewVaultRepository(path string, opts ...Option) *VaultRepository {
+	r := &VaultRepository{
+		path:    path,
+		backups: DefaultBackups,
+	}
+
+	for _, opt := range opts {
+		opt(r)
 	}
+
+	return r
 }
 
 // Exists checks if the vault file exists
@@ -64,6 +119,12 @@ func (r *VaultRepository) Load(_ context.Context, password string) error {
 
 // Save stores a client in the vault
 func (r *VaultRepository) Save(ctx context.Context, client core.Client) error {
+	return r.withLock(ctx, func() error {
+		return r.saveClient(client)
+	})
+}
+
+func (r *VaultRepository) saveClient(client core.Client) error {
 	data, err := r.load()
 	if err != nil {
 		return err
@@ -83,7 +144,59 @@ func (r *VaultRepository) Save(ctx context.Context, client core.Client) error {
 
 	data.Clients = append(data.Clients, toClientData(client))
 
-	return r.save(data)
+	return r.commit(data)
+}
+
+// Update replaces an existing client, keeping its original creation time and recording the update time
+func (r *VaultRepository) Update(ctx context.Context, client core.Client) error {
+	return r.withLock(ctx, func() error {
+		return r.updateClient(client)
+	})
+}
+
+func (r *VaultRepository) updateClient(client core.Client) error {
+	data, err := r.load()
+	if err != nil {
+		return err
+	}
+
+	for i, c := range data.Clients {
+		if c.Name == client.Name {
+			client.CreatedAt = c.CreatedAt
+			client.UpdatedAt = time.Now()
+
+			data.Clients[i] = toClientData(client)
+			data.dropTokens(client.Name)
+
+			return r.commit(data)
+		}
+	}
+
+	return fmt.Errorf("%w: %s", core.ErrClientNotFound, client.Name)
+}
+
+// UpdateEndpoints stores the token, authorization and device authorization URLs of the client as re-resolved
+// from its issuer, with the time they were resolved. Nothing else changes, so its tokens are kept and no backup is rotated.
+func (r *VaultRepository) UpdateEndpoints(ctx context.Context, client core.Client) error {
+	return r.withLock(ctx, func() error {
+		data, err := r.load()
+		if err != nil {
+			return err
+		}
+
+		for i := range data.Clients {
+			if data.Clients[i].Name == client.Name {
+				data.Clients[i].TokenURL = client.TokenURL
+				data.Clients[i].AuthorizationURL = client.AuthorizationURL
+				data.Clients[i].DeviceAuthorizationURL = client.DeviceAuthorizationURL
+				data.Clients[i].EndpointsResolvedAt = client.EndpointsResolvedAt
+
+				return r.save(data)
+			}
+		}
+
+		return fmt.Errorf("%w: %s", core.ErrClientNotFound, client.Name)
+	})
 }
 
 // Get retrieves a client by name
@@ -100,7 +213,7 @@ func (r *VaultRepository) Get(ctx context.Context, name string) (*core.Client, e
 		}
 	}
 
-	return nil, fmt.Errorf("client %q not found", name)
+	return nil, fmt.Errorf("%w: %s", core.ErrClientNotFound, name)
 }
 
 // List returns all client names
@@ -135,6 +248,12 @@ func (r *VaultRepository) GetAll(ctx context.Context) ([]core.Client, error) {
 
 // Delete removes a client by name
 func (r *VaultRepository) Delete(ctx context.Context, name string) error {
+	return r.withLock(ctx, func() error {
+		return r.deleteClient(name)
+	})
+}
+
+func (r *VaultRepository) deleteClient(name string) error {
 	data, err := r.load()
 	if err != nil {
 		return err
@@ -143,11 +262,62 @@ func (r *VaultRepository) Delete(ctx context.Context, name string) error {
 	for i, c := range data.Clients {
 		if c.Name == name {
 			data.Clients = append(data.Clients[:i], data.Clients[i+1:]...)
-			return r.save(data)
+			data.dropTokens(name)
+
+			return r.commit(data)
 		}
 	}
 
-	return fmt.Errorf("client %q not found", name)
+	return fmt.Errorf("%w: %s", core.ErrClientNotFound, name)
+}
+
+// ChangePassword verifies the current password and re-encrypts the vault with the new one
+func (r *VaultRepository) ChangePassword(ctx context.Context, oldPassword, newPassword string) error {
+	if !r.Exists() {
+		return fmt.Errorf("vault does not exist")
+	}
+
+	return r.withLock(ctx, func() error {
+		return r.rekey(oldPassword, newPassword)
+	})
+}
+
+func (r *VaultRepository) rekey(oldPassword, newPassword string) error {
+	current := r.password
+	r.password = oldPassword
+
+	data, err := r.load()
+	if err != nil {
+		// A wrong old password must not replace the one the vault was unlocked with
+		r.password = current
+		return err
+	}
+
+	r.password = newPassword
+
+	if err := r.save(data); err != nil {
+		r.password = oldPassword
+		return err
+	}
+
+	// The vault is re-encrypted first, a failure here leaves it usable with the new password
+	return r.rekeyBackups(oldPassword)
+}
+
+// withLock runs fn while holding the vault lock, so the load/modify/save cycle
+// cannot interleave with another process writing the same vault
+func (r *VaultRepository) withLock(ctx context.Context, fn func() error) error {
+	if err := os.MkdirAll(filepath.Dir(r.path), 0700); err != nil {
+		return fmt.Errorf("failed to create vault directory: %w", err)
+	}
+
+	lock, err := acquireLock(ctx, r.path+".lock")
+	if err != nil {
+		return err
+	}
+	defer func() { _ = lock.release() }()
+
+	return fn()
 }
 
 // load loads and decrypts the vault
@@ -164,17 +334,55 @@ func (r *VaultRepository) load() (*vaultData, error) {
 		return &vaultData{Clients: []clientData{}}, nil
 	}
 
-	plaintext, err := decrypt(fileData, r.password)
+	return r.decode(fileData)
+}
+
+// decode decrypts and parses raw vault file content with the current password, keeping the derived key
+// for the following writes
+func (r *VaultRepository) decode(fileData []byte) (*vaultData, error) {
+	data, key, err := r.unseal(fileData)
 	if err != nil {
-		return nil, fmt.Errorf("failed to decrypt vault (wrong password?): %w", err)
+		return nil, err
+	}
+
+	r.key = key
+
+	return data, nil
+}
+
+// unseal decrypts and parses raw vault file content with the current password and returns the key it
+// derived. Backups are read this way, so their key never replaces the one of the vault.
+func (r *VaultRepository) unseal(fileData []byte) (*vaultData, *vaultKey, error) {
+	h, payload, err := parseHeader(fileData)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read vault header: %w", err)
+	}
+
+	key, err := r.deriveKey(h)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	plaintext, err := open(h, key, payload)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to decrypt vault: %w", core.ErrWrongPassword)
 	}
 
 	var data vaultData
 	if err := json.Unmarshal(plaintext, &data); err != nil {
-		return nil, fmt.Errorf("failed to parse vault data: %w", err)
+		return nil, nil, fmt.Errorf("failed to parse vault data: %w", err)
+	}
+
+	return &data, &vaultKey{password: r.password, header: h, key: key}, nil
+}
+
+// commit backs up the current vault generation and saves the new one
+func (r *VaultRepository) commit(data *vaultData) error {
+	if err := r.rotateBackups(); err != nil {
+		return err
 	}
 
-	return &data, nil
+	return r.save(data)
 }
 
 // save encrypts and saves the vault
@@ -184,7 +392,12 @@ func (r *VaultRepository) save(data *vaultData) error {
 		return fmt.Errorf("failed to marshal vault data: %w", err)
 	}
 
-	ciphertext, err := encrypt(plaintext, r.password)
+	h, key, err := r.encryptionKey()
+	if err != nil {
+		return fmt.Errorf("failed to encrypt vault: %w", err)
+	}
+
+	ciphertext, err := seal(h, key, plaintext)
 	if err != nil {
 		return fmt.Errorf("failed to encrypt vault: %w", err)
 	}
@@ -194,102 +407,153 @@ func (r *VaultRepository) save(data *vaultData) error {
 		return fmt.Errorf("failed to create vault directory: %w", err)
 	}
 
-	if err := os.WriteFile(r.path, ciphertext, 0600); err != nil {
+	if err := writeFileAtomic(r.path, ciphertext, 0600); err != nil {
 		return fmt.Errorf("failed to write vault: %w", err)
 	}
 
 	return nil
 }
 
-// encrypt encrypts plaintext using AES-256-GCM with a password-derived key
-func encrypt(plaintext []byte, password string) ([]byte, error) {
-	salt := make([]byte, saltSize)
-	if _, err := io.ReadFull(rand.Reader, salt); err != nil {
-		return nil, fmt.Errorf("failed to generate salt: %w", err)
+// deriveKey returns the key for the given header, reusing the cached key when possible
+func (r *VaultRepository) deriveKey(h header) ([]byte, error) {
+	if r.key != nil && r.key.password == r.password && r.key.header.equal(h) {
+		return r.key.key, nil
 	}
 
-	key := pbkdf2.Key([]byte(password), salt, iterations, keySize, sha256.New)
+	return h.KDF.deriveKey(r.password, h.Salt)
+}
 
-	block, err := aes.NewCipher(key)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create cipher: %w", err)
+// encryptionKey returns the header and key used to write the vault.
+// Legacy vaults and vaults with outdated KDF parameters get a fresh header with the default KDF.
+func (r *VaultRepository) encryptionKey() (header, []byte, error) {
+	if r.key != nil && r.key.password == r.password && !r.key.header.legacy && r.key.header.KDF == defaultKDF {
+		return r.key.header, r.key.key, nil
 	}
 
-	gcm, err := cipher.NewGCM(block)
+	h, err := newHeader(defaultKDF)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create GCM: %w", err)
+		return header{}, nil, err
 	}
 
-	nonce := make([]byte, gcm.NonceSize())
-	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
-		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	key, err := h.KDF.deriveKey(r.password, h.Salt)
+	if err != nil {
+		return header{}, nil, err
 	}
 
-	ciphertext := gcm.Seal(nonce, nonce, plaintext, nil)
-
-	// Prepend salt to ciphertext
-	result := make([]byte, len(salt)+len(ciphertext))
-	copy(result, salt)
-	copy(result[len(salt):], ciphertext)
+	r.key = &vaultKey{password: r.password, header: h, key: key}
 
-	return result, nil
+	return h, key, nil
 }
 
-// decrypt decrypts ciphertext using AES-256-GCM with a password-derived key
-func decrypt(ciphertext []byte, password string) ([]byte, error) {
-	if len(ciphertext) < saltSize {
-		return nil, fmt.Errorf("ciphertext too short")
-	}
-
-	salt := ciphertext[:saltSize]
-	ciphertext = ciphertext[saltSize:]
-
-	key := pbkdf2.Key([]byte(password), salt, iterations, keySize, sha256.New)
-
-	block, err := aes.NewCipher(key)
+// encrypt encrypts plaintext using AES-256-GCM with a key derived by the default KDF
+func encrypt(plaintext []byte, password string) ([]byte, error) {
+	h, err := newHeader(defaultKDF)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create cipher: %w", err)
+		return nil, err
 	}
 
-	gcm, err := cipher.NewGCM(block)
+	key, err := h.KDF.deriveKey(password, h.Salt)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create GCM: %w", err)
+		return nil, err
 	}
 
-	if len(ciphertext) < gcm.NonceSize() {
-		return nil, fmt.Errorf("ciphertext too short")
-	}
+	return seal(h, key, plaintext)
+}
 
-	nonce := ciphertext[:gcm.NonceSize()]
-	ciphertext = ciphertext[gcm.NonceSize():]
+// decrypt decrypts a vault file in either the current or the legacy headerless format
+func decrypt(data []byte, password string) ([]byte, error) {
+	h, payload, err := parseHeader(data)
+	if err != nil {
+		return nil, err
+	}
 
-	plaintext, err := gcm.Open(nil, nonce, ciphertext, nil)
+	key, err := h.KDF.deriveKey(password, h.Salt)
 	if err != nil {
-		return nil, fmt.Errorf("failed to decrypt: %w", err)
+		return nil, err
 	}
 
-	return plaintext, nil
+	return open(h, key, payload)
 }
 
 // Helper functions to convert between core.Client and clientData
 func toClientData(c core.Client) clientData {
 	return clientData{
-		Name:         c.Name,
-		ClientID:     c.ClientID,
-		ClientSecret: c.ClientSecret,
-		TokenURL:     c.TokenURL,
-		Scopes:       c.Scopes,
-		CreatedAt:    c.CreatedAt,
+		Name:                    c.Name,
+		ClientID:                c.ClientID,
+		ClientSecret:            c.ClientSecret,
+		TokenURL:                c.TokenURL,
+		Issuer:                  c.Issuer,
+		Scopes:                  c.Scopes,
+		TokenEndpointAuthMethod: string(c.TokenEndpointAuthMethod),
+		PrivateKey:              c.PrivateKey,
+		KeyID:                   c.KeyID,
+		SigningAlgorithm:        c.SigningAlgorithm,
+		TLSCertificate:          c.TLSCertificate,
+		TLSKey:                  c.TLSKey,
+		GrantType:               string(c.GrantType),
+		AuthorizationURL:        c.AuthorizationURL,
+		RedirectURL:             c.RedirectURL,
+		DeviceAuthorizationURL:  c.DeviceAuthorizationURL,
+		EndpointsResolvedAt:     c.EndpointsResolvedAt,
+		AssertionIssuer:         c.AssertionIssuer,
+		AssertionSubject:        c.AssertionSubject,
+		AssertionAudience:       c.AssertionAudience,
+		Username:                c.Username,
+		Password:                c.Password,
+		Audience:                c.Audience,
+		Resource:                c.Resource,
+		ExtraParams:             c.ExtraParams,
+		ExtraHeaders:            c.ExtraHeaders,
+		HTTPTimeout:             int64(c.HTTP.Timeout),
+		ProxyURL:                c.HTTP.ProxyURL,
+		CACertificates:          c.HTTP.CACertificates,
+		TLSMinVersion:           c.HTTP.TLSMinVersion,
+		Retries:                 c.HTTP.Retries,
+		PinnedPublicKey:         c.HTTP.PinnedPublicKey,
+		InsecureSkipVerify:      c.HTTP.InsecureSkipVerify,
+		CreatedAt:               c.CreatedAt,
+		UpdatedAt:               c.UpdatedAt,
 	}
 }
 
 func toClient(c clientData) core.Client {
 	return core.Client{
-		Name:         c.Name,
-		ClientID:     c.ClientID,
-		ClientSecret: c.ClientSecret,
-		TokenURL:     c.TokenURL,
-		Scopes:       c.Scopes,
-		CreatedAt:    c.CreatedAt,
+		Name:                    c.Name,
+		ClientID:                c.ClientID,
+		ClientSecret:            c.ClientSecret,
+		TokenURL:                c.TokenURL,
+		Issuer:                  c.Issuer,
+		Scopes:                  c.Scopes,
+		TokenEndpointAuthMethod: core.AuthMethod(c.TokenEndpointAuthMethod),
+		PrivateKey:              c.PrivateKey,
+		KeyID:                   c.KeyID,
+		SigningAlgorithm:        c.SigningAlgorithm,
+		TLSCertificate:          c.TLSCertificate,
+		TLSKey:                  c.TLSKey,
+		GrantType:               core.GrantType(c.GrantType),
+		AuthorizationURL:        c.AuthorizationURL,
+		RedirectURL:             c.RedirectURL,
+		DeviceAuthorizationURL:  c.DeviceAuthorizationURL,
+		EndpointsResolvedAt:     c.EndpointsResolvedAt,
+		AssertionIssuer:         c.AssertionIssuer,
+		AssertionSubject:        c.AssertionSubject,
+		AssertionAudience:       c.AssertionAudience,
+		Username:                c.Username,
+		Password:                c.Password,
+		Audience:                c.Audience,
+		Resource:                c.Resource,
+		ExtraParams:             c.ExtraParams,
+		ExtraHeaders:            c.ExtraHeaders,
+		CreatedAt:               c.CreatedAt,
+		UpdatedAt:               c.UpdatedAt,
+		HTTP: core.HTTPSettings{
+			Timeout:            time.Duration(c.HTTPTimeout),
+			ProxyURL:           c.ProxyURL,
+			CACertificates:     c.CACertificates,
+			TLSMinVersion:      c.TLSMinVersion,
+			Retries:            c.Retries,
+			PinnedPublicKey:    c.PinnedPublicKey,
+			InsecureSkipVerify: c.InsecureSkipVerify,
+		},
 	}
 }
@@ -0,0 +1,97 @@
+package ui
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"time"
+
+	"github.com/ksysoev/authkeeper/pkg/core"
+)
+
+// browser implements core.Interactor by showing the authorization URL and opening it in the default browser.
+// Device codes are only printed, the device grant is meant for machines without a browser.
+// User credentials for the password grant are read from the terminal.
+type browser struct {
+	// open launches the URL, it is replaced in tests
+	open func(url string) error
+}
+
+// Authorize shows the authorization URL and tries to open it, the URL stays usable when no browser can be started
+func (b browser) Authorize(_ context.Context, authURL string) error {
+	fmt.Fprintln(os.Stderr)
+	printInfo("Sign in with your browser to continue:")
+	fmt.Fprintln(os.Stderr, authURL)
+	fmt.Fprintln(os.Stderr)
+
+	if err := b.open(authURL); err != nil {
+		printMuted("Could not open a browser, open the URL above manually")
+	}
+
+	printProgress("Waiting for authorization")
+
+	return nil
+}
+
+// ShowDeviceCode shows where to enter the user code to approve a device authorization request
+func (b browser) ShowDeviceCode(_ context.Context, auth core.DeviceAuthorization) error {
+	fmt.Fprintln(os.Stderr)
+	printInfo("To sign in, open the following URL on any device:")
+	fmt.Fprintln(os.Stderr, auth.VerificationURI)
+	fmt.Fprintln(os.Stderr)
+	printInfo(fmt.Sprintf("and enter the code: %s", auth.UserCode))
+
+	if auth.VerificationURIComplete != "" {
+		printMuted(fmt.Sprintf("Or open %s to skip entering the code", auth.VerificationURIComplete))
+	}
+
+	if auth.ExpiresIn > 0 {
+		printMuted(fmt.Sprintf("The code expires in %s", time.Duration(auth.ExpiresIn)*time.Second))
+	}
+
+	fmt.Fprintln(os.Stderr)
+	printProgress("Waiting for approval (Ctrl-C to cancel)")
+
+	return nil
+}
+
+// Credentials prompts for the username and password of the resource owner, the password is not echoed
+func (b browser) Credentials(_ context.Context, clientName string) (username, password string, err error) {
+	fmt.Fprintln(os.Stderr)
+	printInfo(fmt.Sprintf("Sign in to %s with a user account", clientName))
+	printWarning("The password grant is a legacy grant, the password is sent to the token endpoint")
+
+	if username, err = readLine("Username: "); err != nil {
+		return "", "", err
+	}
+
+	if password, err = readPassword("Password: "); err != nil {
+		return "", "", err
+	}
+
+	return username, password, nil
+}
+
+// openBrowser opens the URL with the platform's default browser
+func openBrowser(url string) error {
+	var cmd *exec.Cmd
+
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("open", url)
+	case "windows":
+		cmd = exec.Command("rundll32", "url.dll,FileProtocolHandler", url)
+	default:
+		cmd = exec.Command("xdg-open", url)
+	}
+
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	go func() { _ = cmd.Wait() }()
+
+	return nil
+}
@@ -0,0 +1,40 @@
+package ui
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/ksysoev/authkeeper/pkg/core"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBrowser_Authorize(t *testing.T) {
+	var opened string
+
+	b := browser{open: func(url string) error {
+		opened = url
+		return nil
+	}}
+
+	assert.NoError(t, b.Authorize(context.Background(), "https://idp.example.com/authorize?state=abc"))
+	assert.Equal(t, "https://idp.example.com/authorize?state=abc", opened)
+
+	// Without a browser the URL is still shown, so the flow continues
+	b = browser{open: func(string) error { return errors.New("xdg-open not found") }}
+	assert.NoError(t, b.Authorize(context.Background(), "https://idp.example.com/authorize"))
+}
+
+func TestBrowser_ShowDeviceCode(t *testing.T) {
+	b := browser{open: func(string) error {
+		t.Fatal("device codes must not open a browser")
+		return nil
+	}}
+
+	assert.NoError(t, b.ShowDeviceCode(context.Background(), core.DeviceAuthorization{
+		UserCode:                "ABCD-EFGH",
+		VerificationURI:         "https://idp.example.com/device",
+		VerificationURIComplete: "https://idp.example.com/device?user_code=ABCD-EFGH",
+		ExpiresIn:               600,
+	}))
+}
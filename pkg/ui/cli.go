@@ -2,6 +2,9 @@ package ui
 
 import (
 	"context"
+	"io"
+	"os"
+	"time"
 
 	"github.com/ksysoev/authkeeper/pkg/core"
 )
@@ -9,23 +12,72 @@ import (
 // CoreService defines what UI needs from core (interface on consumer side)
 type CoreService interface {
 	AddClient(ctx context.Context, client core.Client) error
+	DiscoverIssuer(ctx context.Context, issuer string, settings core.HTTPSettings) (*core.ServerMetadata, error)
+	UpdateClient(ctx context.Context, client core.Client) error
 	GetClient(ctx context.Context, name string) (*core.Client, error)
 	ListClients(ctx context.Context) ([]string, error)
 	GetAllClients(ctx context.Context) ([]core.Client, error)
 	DeleteClient(ctx context.Context, name string) error
-	IssueToken(ctx context.Context, clientName string) (*core.Token, error)
+	IssueToken(ctx context.Context, clientName string, opts core.TokenOptions) (*core.Token, error)
+	ExchangeToken(ctx context.Context, clientName string, exchange core.TokenExchange) (*core.Token, error)
 	IsRepositoryInitialized() bool
 	CheckPassword(ctx context.Context, password string) error
+	ChangePassword(ctx context.Context, oldPassword, newPassword string) error
+	ListBackups(ctx context.Context) ([]core.Backup, error)
+	RestoreBackup(ctx context.Context, index int) error
 }
 
 // CLI implements the command-line interface
 type CLI struct {
-	service CoreService
+	service   CoreService
+	passwords PasswordSource
+	format    OutputFormat
+	out       io.Writer
+	// interactor involves the user in interactive grants
+	interactor core.Interactor
+	// decode adds the decoded header and claims of JWT access tokens to the token output
+	decode bool
+	now    func() time.Time
+}
+
+// Option configures a CLI
+type Option func(*CLI)
+
+// WithPasswordSource configures non-interactive sources for the master password
+func WithPasswordSource(source PasswordSource) Option {
+	return func(c *CLI) {
+		c.passwords = source
+	}
+}
+
+// WithOutputFormat sets the format used to write command results to stdout
+func WithOutputFormat(format OutputFormat) Option {
+	return func(c *CLI) {
+		c.format = format
+	}
+}
+
+// WithDecodedTokens adds the decoded header and claims of JWT access tokens to the token output
+func WithDecodedTokens(decode bool) Option {
+	return func(c *CLI) {
+		c.decode = decode
+	}
 }
 
 // NewCLI creates a new CLI
-func NewCLI(service CoreService) *CLI {
-	return &CLI{
-		service: service,
+func NewCLI(service CoreService, opts ...Option) *CLI {
+	c := &CLI{
+		service:    service,
+		passwords:  PasswordSource{FD: -1},
+		format:     OutputText,
+		out:        os.Stdout,
+		interactor: browser{open: openBrowser},
+		now:        time.Now,
 	}
+
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	return c
 }
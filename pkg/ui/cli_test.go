@@ -74,14 +74,14 @@ func TestCoreService_ListClients_NoClients(t *testing.T) {
 
 func TestCoreService_IssueToken_Success(t *testing.T) {
 	service := NewMockCoreService(t)
-	service.EXPECT().IssueToken(mock.Anything, "client1").Return(&core.Token{
+	service.EXPECT().IssueToken(mock.Anything, "client1", core.TokenOptions{}).Return(&core.Token{
 		AccessToken: "access-token",
 		TokenType:   "Bearer",
 		ExpiresIn:   3600,
 		Scope:       "read write",
 	}, nil)
 
-	token, err := service.IssueToken(context.Background(), "client1")
+	token, err := service.IssueToken(context.Background(), "client1", core.TokenOptions{})
 	assert.NoError(t, err)
 	assert.NotNil(t, token)
 	assert.Equal(t, "access-token", token.AccessToken)
@@ -91,9 +91,9 @@ func TestCoreService_IssueToken_Success(t *testing.T) {
 
 func TestCoreService_IssueToken_Error(t *testing.T) {
 	service := NewMockCoreService(t)
-	service.EXPECT().IssueToken(mock.Anything, "client1").Return(nil, errors.New("token error"))
+	service.EXPECT().IssueToken(mock.Anything, "client1", core.TokenOptions{}).Return(nil, errors.New("token error"))
 
-	token, err := service.IssueToken(context.Background(), "client1")
+	token, err := service.IssueToken(context.Background(), "client1", core.TokenOptions{})
 	assert.Error(t, err)
 	assert.Nil(t, token)
 }
@@ -71,6 +71,54 @@ func (_c *MockCoreService_AddClient_Call) RunAndReturn(run func(context.Context,
 	return _c
 }
 
+// ChangePassword provides a mock function with given fields: ctx, oldPassword, newPassword
+func (_m *MockCoreService) ChangePassword(ctx context.Context, oldPassword string, newPassword string) error {
+	ret := _m.Called(ctx, oldPassword, newPassword)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ChangePassword")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) error); ok {
+		r0 = rf(ctx, oldPassword, newPassword)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// MockCoreService_ChangePassword_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'ChangePassword'
+type MockCoreService_ChangePassword_Call struct {
+	*mock.Call
+}
+
+// ChangePassword is a helper method to define mock.On call
+//   - ctx context.Context
+//   - oldPassword string
+//   - newPassword string
+func (_e *MockCoreService_Expecter) ChangePassword(ctx interface{}, oldPassword interface{}, newPassword interface{}) *MockCoreService_ChangePassword_Call {
+	return &MockCoreService_ChangePassword_Call{Call: _e.mock.On("ChangePassword", ctx, oldPassword, newPassword)}
+}
+
+func (_c *MockCoreService_ChangePassword_Call) Run(run func(ctx context.Context, oldPassword string, newPassword string)) *MockCoreService_ChangePassword_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(string), args[2].(string))
+	})
+	return _c
+}
+
+func (_c *MockCoreService_ChangePassword_Call) Return(_a0 error) *MockCoreService_ChangePassword_Call {
+	_c.Call.Return(_a0)
+	return _c
+}
+
+func (_c *MockCoreService_ChangePassword_Call) RunAndReturn(run func(context.Context, string, string) error) *MockCoreService_ChangePassword_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
 // CheckPassword provides a mock function with given fields: ctx, password
 func (_m *MockCoreService) CheckPassword(ctx context.Context, password string) error {
 	ret := _m.Called(ctx, password)
@@ -165,6 +213,126 @@ func (_c *MockCoreService_DeleteClient_Call) RunAndReturn(run func(context.Conte
 	return _c
 }
 
+// DiscoverIssuer provides a mock function with given fields: ctx, issuer, settings
+func (_m *MockCoreService) DiscoverIssuer(ctx context.Context, issuer string, settings core.HTTPSettings) (*core.ServerMetadata, error) {
+	ret := _m.Called(ctx, issuer, settings)
+
+	if len(ret) == 0 {
+		panic("no return value specified for DiscoverIssuer")
+	}
+
+	var r0 *core.ServerMetadata
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, core.HTTPSettings) (*core.ServerMetadata, error)); ok {
+		return rf(ctx, issuer, settings)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string, core.HTTPSettings) *core.ServerMetadata); ok {
+		r0 = rf(ctx, issuer, settings)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*core.ServerMetadata)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string, core.HTTPSettings) error); ok {
+		r1 = rf(ctx, issuer, settings)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// MockCoreService_DiscoverIssuer_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'DiscoverIssuer'
+type MockCoreService_DiscoverIssuer_Call struct {
+	*mock.Call
+}
+
+// DiscoverIssuer is a helper method to define mock.On call
+//   - ctx context.Context
+//   - issuer string
+//   - settings core.HTTPSettings
+func (_e *MockCoreService_Expecter) DiscoverIssuer(ctx interface{}, issuer interface{}, settings interface{}) *MockCoreService_DiscoverIssuer_Call {
+	return &MockCoreService_DiscoverIssuer_Call{Call: _e.mock.On("DiscoverIssuer", ctx, issuer, settings)}
+}
+
+func (_c *MockCoreService_DiscoverIssuer_Call) Run(run func(ctx context.Context, issuer string, settings core.HTTPSettings)) *MockCoreService_DiscoverIssuer_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(string), args[2].(core.HTTPSettings))
+	})
+	return _c
+}
+
+func (_c *MockCoreService_DiscoverIssuer_Call) Return(_a0 *core.ServerMetadata, _a1 error) *MockCoreService_DiscoverIssuer_Call {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
+func (_c *MockCoreService_DiscoverIssuer_Call) RunAndReturn(run func(context.Context, string, core.HTTPSettings) (*core.ServerMetadata, error)) *MockCoreService_DiscoverIssuer_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// ExchangeToken provides a mock function with given fields: ctx, clientName, exchange
+func (_m *MockCoreService) ExchangeToken(ctx context.Context, clientName string, exchange core.TokenExchange) (*core.Token, error) {
+	ret := _m.Called(ctx, clientName, exchange)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ExchangeToken")
+	}
+
+	var r0 *core.Token
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, core.TokenExchange) (*core.Token, error)); ok {
+		return rf(ctx, clientName, exchange)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string, core.TokenExchange) *core.Token); ok {
+		r0 = rf(ctx, clientName, exchange)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*core.Token)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string, core.TokenExchange) error); ok {
+		r1 = rf(ctx, clientName, exchange)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// MockCoreService_ExchangeToken_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'ExchangeToken'
+type MockCoreService_ExchangeToken_Call struct {
+	*mock.Call
+}
+
+// ExchangeToken is a helper method to define mock.On call
+//   - ctx context.Context
+//   - clientName string
+//   - exchange core.TokenExchange
+func (_e *MockCoreService_Expecter) ExchangeToken(ctx interface{}, clientName interface{}, exchange interface{}) *MockCoreService_ExchangeToken_Call {
+	return &MockCoreService_ExchangeToken_Call{Call: _e.mock.On("ExchangeToken", ctx, clientName, exchange)}
+}
+
+func (_c *MockCoreService_ExchangeToken_Call) Run(run func(ctx context.Context, clientName string, exchange core.TokenExchange)) *MockCoreService_ExchangeToken_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(string), args[2].(core.TokenExchange))
+	})
+	return _c
+}
+
+func (_c *MockCoreService_ExchangeToken_Call) Return(_a0 *core.Token, _a1 error) *MockCoreService_ExchangeToken_Call {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
+func (_c *MockCoreService_ExchangeToken_Call) RunAndReturn(run func(context.Context, string, core.TokenExchange) (*core.Token, error)) *MockCoreService_ExchangeToken_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
 // GetAllClients provides a mock function with given fields: ctx
 func (_m *MockCoreService) GetAllClients(ctx context.Context) ([]core.Client, error) {
 	ret := _m.Called(ctx)
@@ -327,9 +495,9 @@ func (_c *MockCoreService_IsRepositoryInitialized_Call) RunAndReturn(run func()
 	return _c
 }
 
-// IssueToken provides a mock function with given fields: ctx, clientName
-func (_m *MockCoreService) IssueToken(ctx context.Context, clientName string) (*core.Token, error) {
-	ret := _m.Called(ctx, clientName)
+// IssueToken provides a mock function with given fields: ctx, clientName, opts
+func (_m *MockCoreService) IssueToken(ctx context.Context, clientName string, opts core.TokenOptions) (*core.Token, error) {
+	ret := _m.Called(ctx, clientName, opts)
 
 	if len(ret) == 0 {
 		panic("no return value specified for IssueToken")
@@ -337,19 +505,19 @@ func (_m *MockCoreService) IssueToken(ctx context.Context, clientName string) (*
 
 	var r0 *core.Token
 	var r1 error
-	if rf, ok := ret.Get(0).(func(context.Context, string) (*core.Token, error)); ok {
-		return rf(ctx, clientName)
+	if rf, ok := ret.Get(0).(func(context.Context, string, core.TokenOptions) (*core.Token, error)); ok {
+		return rf(ctx, clientName, opts)
 	}
-	if rf, ok := ret.Get(0).(func(context.Context, string) *core.Token); ok {
-		r0 = rf(ctx, clientName)
+	if rf, ok := ret.Get(0).(func(context.Context, string, core.TokenOptions) *core.Token); ok {
+		r0 = rf(ctx, clientName, opts)
 	} else {
 		if ret.Get(0) != nil {
 			r0 = ret.Get(0).(*core.Token)
 		}
 	}
 
-	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
-		r1 = rf(ctx, clientName)
+	if rf, ok := ret.Get(1).(func(context.Context, string, core.TokenOptions) error); ok {
+		r1 = rf(ctx, clientName, opts)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -365,13 +533,14 @@ type MockCoreService_IssueToken_Call struct {
 // IssueToken is a helper method to define mock.On call
 //   - ctx context.Context
 //   - clientName string
-func (_e *MockCoreService_Expecter) IssueToken(ctx interface{}, clientName interface{}) *MockCoreService_IssueToken_Call {
-	return &MockCoreService_IssueToken_Call{Call: _e.mock.On("IssueToken", ctx, clientName)}
+//   - opts core.TokenOptions
+func (_e *MockCoreService_Expecter) IssueToken(ctx interface{}, clientName interface{}, opts interface{}) *MockCoreService_IssueToken_Call {
+	return &MockCoreService_IssueToken_Call{Call: _e.mock.On("IssueToken", ctx, clientName, opts)}
 }
 
-func (_c *MockCoreService_IssueToken_Call) Run(run func(ctx context.Context, clientName string)) *MockCoreService_IssueToken_Call {
+func (_c *MockCoreService_IssueToken_Call) Run(run func(ctx context.Context, clientName string, opts core.TokenOptions)) *MockCoreService_IssueToken_Call {
 	_c.Call.Run(func(args mock.Arguments) {
-		run(args[0].(context.Context), args[1].(string))
+		run(args[0].(context.Context), args[1].(string), args[2].(core.TokenOptions))
 	})
 	return _c
 }
@@ -381,7 +550,65 @@ func (_c *MockCoreService_IssueToken_Call) Return(_a0 *core.Token, _a1 error) *M
 	return _c
 }
 
-func (_c *MockCoreService_IssueToken_Call) RunAndReturn(run func(context.Context, string) (*core.Token, error)) *MockCoreService_IssueToken_Call {
+func (_c *MockCoreService_IssueToken_Call) RunAndReturn(run func(context.Context, string, core.TokenOptions) (*core.Token, error)) *MockCoreService_IssueToken_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// ListBackups provides a mock function with given fields: ctx
+func (_m *MockCoreService) ListBackups(ctx context.Context) ([]core.Backup, error) {
+	ret := _m.Called(ctx)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ListBackups")
+	}
+
+	var r0 []core.Backup
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context) ([]core.Backup, error)); ok {
+		return rf(ctx)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context) []core.Backup); ok {
+		r0 = rf(ctx)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]core.Backup)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = rf(ctx)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// MockCoreService_ListBackups_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'ListBackups'
+type MockCoreService_ListBackups_Call struct {
+	*mock.Call
+}
+
+// ListBackups is a helper method to define mock.On call
+//   - ctx context.Context
+func (_e *MockCoreService_Expecter) ListBackups(ctx interface{}) *MockCoreService_ListBackups_Call {
+	return &MockCoreService_ListBackups_Call{Call: _e.mock.On("ListBackups", ctx)}
+}
+
+func (_c *MockCoreService_ListBackups_Call) Run(run func(ctx context.Context)) *MockCoreService_ListBackups_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context))
+	})
+	return _c
+}
+
+func (_c *MockCoreService_ListBackups_Call) Return(_a0 []core.Backup, _a1 error) *MockCoreService_ListBackups_Call {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
+func (_c *MockCoreService_ListBackups_Call) RunAndReturn(run func(context.Context) ([]core.Backup, error)) *MockCoreService_ListBackups_Call {
 	_c.Call.Return(run)
 	return _c
 }
@@ -444,6 +671,100 @@ func (_c *MockCoreService_ListClients_Call) RunAndReturn(run func(context.Contex
 	return _c
 }
 
+// RestoreBackup provides a mock function with given fields: ctx, index
+func (_m *MockCoreService) RestoreBackup(ctx context.Context, index int) error {
+	ret := _m.Called(ctx, index)
+
+	if len(ret) == 0 {
+		panic("no return value specified for RestoreBackup")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, int) error); ok {
+		r0 = rf(ctx, index)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// MockCoreService_RestoreBackup_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'RestoreBackup'
+type MockCoreService_RestoreBackup_Call struct {
+	*mock.Call
+}
+
+// RestoreBackup is a helper method to define mock.On call
+//   - ctx context.Context
+//   - index int
+func (_e *MockCoreService_Expecter) RestoreBackup(ctx interface{}, index interface{}) *MockCoreService_RestoreBackup_Call {
+	return &MockCoreService_RestoreBackup_Call{Call: _e.mock.On("RestoreBackup", ctx, index)}
+}
+
+func (_c *MockCoreService_RestoreBackup_Call) Run(run func(ctx context.Context, index int)) *MockCoreService_RestoreBackup_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(int))
+	})
+	return _c
+}
+
+func (_c *MockCoreService_RestoreBackup_Call) Return(_a0 error) *MockCoreService_RestoreBackup_Call {
+	_c.Call.Return(_a0)
+	return _c
+}
+
+func (_c *MockCoreService_RestoreBackup_Call) RunAndReturn(run func(context.Context, int) error) *MockCoreService_RestoreBackup_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// UpdateClient provides a mock function with given fields: ctx, client
+func (_m *MockCoreService) UpdateClient(ctx context.Context, client core.Client) error {
+	ret := _m.Called(ctx, client)
+
+	if len(ret) == 0 {
+		panic("no return value specified for UpdateClient")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, core.Client) error); ok {
+		r0 = rf(ctx, client)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// MockCoreService_UpdateClient_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'UpdateClient'
+type MockCoreService_UpdateClient_Call struct {
+	*mock.Call
+}
+
+// UpdateClient is a helper method to define mock.On call
+//   - ctx context.Context
+//   - client core.Client
+func (_e *MockCoreService_Expecter) UpdateClient(ctx interface{}, client interface{}) *MockCoreService_UpdateClient_Call {
+	return &MockCoreService_UpdateClient_Call{Call: _e.mock.On("UpdateClient", ctx, client)}
+}
+
+func (_c *MockCoreService_UpdateClient_Call) Run(run func(ctx context.Context, client core.Client)) *MockCoreService_UpdateClient_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(core.Client))
+	})
+	return _c
+}
+
+func (_c *MockCoreService_UpdateClient_Call) Return(_a0 error) *MockCoreService_UpdateClient_Call {
+	_c.Call.Return(_a0)
+	return _c
+}
+
+func (_c *MockCoreService_UpdateClient_Call) RunAndReturn(run func(context.Context, core.Client) error) *MockCoreService_UpdateClient_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
 // NewMockCoreService creates a new instance of MockCoreService. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
 // The first argument is typically a *testing.T value.
 func NewMockCoreService(t interface {
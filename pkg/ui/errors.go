@@ -0,0 +1,115 @@
+package ui
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/ksysoev/authkeeper/pkg/core"
+)
+
+// Exit codes let scripts tell failures apart
+const (
+	ExitFailure        = 1
+	ExitUsage          = 2
+	ExitClientNotFound = 3
+	ExitWrongPassword  = 4
+	ExitVaultLocked    = 5
+	ExitOAuthError     = 6
+	ExitVaultNotFound  = 7
+	// ExitCancelled follows the shell convention for commands stopped with Ctrl-C
+	ExitCancelled = 130
+)
+
+// usageError marks errors in the command line, such as unknown flags
+type usageError struct {
+	err error
+}
+
+// UsageError marks err as an error in the command line, it is reported with ExitUsage
+func UsageError(err error) error {
+	return usageError{err: err}
+}
+
+func (e usageError) Error() string { return e.err.Error() }
+
+func (e usageError) Unwrap() error { return e.err }
+
+// oauthHints suggest what to check for the error codes of RFC 6749 section 5.2 and RFC 8628
+var oauthHints = map[string]string{
+	"invalid_client":         "The server did not accept the client credentials, check the client ID, secret and auth method with 'authkeeper edit'",
+	"invalid_grant":          "The grant was rejected, e.g. an expired code or refresh token, or wrong user credentials",
+	"invalid_scope":          "The server does not allow the requested scopes for this client, check them with 'authkeeper edit --scopes'",
+	"unauthorized_client":    "The client is not allowed to use this grant, pick another one with --grant",
+	"unsupported_grant_type": "The server does not support this grant, pick another one with --grant",
+	"invalid_request":        "The server rejected the request as malformed, check the client's endpoints and settings",
+	"access_denied":          "The request was denied by the user or the server",
+}
+
+// ExitCode returns the process exit code for an error returned by a command
+func ExitCode(err error) int {
+	var oauthErr *core.OAuthError
+
+	switch {
+	case err == nil:
+		return 0
+	case errors.As(err, new(usageError)):
+		return ExitUsage
+	case errors.Is(err, context.Canceled):
+		return ExitCancelled
+	case errors.Is(err, core.ErrClientNotFound):
+		return ExitClientNotFound
+	case errors.Is(err, core.ErrWrongPassword):
+		return ExitWrongPassword
+	case errors.Is(err, core.ErrVaultLocked):
+		return ExitVaultLocked
+	case errors.Is(err, core.ErrVaultNotFound):
+		return ExitVaultNotFound
+	case errors.As(err, &oauthErr):
+		return ExitOAuthError
+	default:
+		return ExitFailure
+	}
+}
+
+// ReportError prints an error returned by a command, followed by a hint on how to fix it when one is known
+func ReportError(err error) {
+	if errors.Is(err, context.Canceled) {
+		printWarning("Cancelled")
+		return
+	}
+
+	printError(err.Error())
+
+	if hint := errorHint(err); hint != "" {
+		printMuted(hint)
+	}
+}
+
+// errorHint returns a suggestion for errors users can fix themselves
+func errorHint(err error) string {
+	var oauthErr *core.OAuthError
+
+	switch {
+	case errors.Is(err, core.ErrClientNotFound):
+		return "Run 'authkeeper list' to see the stored clients"
+	case errors.Is(err, core.ErrWrongPassword):
+		return "Check the master password, or the output of --password-file and --password-command"
+	case errors.Is(err, core.ErrVaultNotFound):
+		return "Use 'authkeeper add' to create the vault and add your first client, or check the --vault path"
+	case errors.Is(err, core.ErrVaultLocked):
+		return "Another authkeeper process is updating the vault, try again when it has finished"
+	case errors.As(err, &oauthErr):
+		if hint, ok := oauthHints[oauthErr.Code]; ok {
+			return hint
+		}
+
+		if oauthErr.Code == "" {
+			return fmt.Sprintf("The server did not send an OAuth error response (HTTP %d), check the token URL and the server's status", oauthErr.StatusCode)
+		}
+
+		return ""
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,50 @@
+package ui
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/ksysoev/authkeeper/pkg/core"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExitCode(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		expected int
+	}{
+		{name: "no error", err: nil, expected: 0},
+		{name: "generic", err: errors.New("boom"), expected: ExitFailure},
+		{name: "usage", err: UsageError(errors.New("unknown flag: --bogus")), expected: ExitUsage},
+		{name: "client not found", err: fmt.Errorf("failed to get client: %w: api", core.ErrClientNotFound), expected: ExitClientNotFound},
+		{name: "wrong password", err: fmt.Errorf("failed to decrypt vault: %w", core.ErrWrongPassword), expected: ExitWrongPassword},
+		{name: "vault locked", err: fmt.Errorf("%w by another process", core.ErrVaultLocked), expected: ExitVaultLocked},
+		{name: "vault not found", err: core.ErrVaultNotFound, expected: ExitVaultNotFound},
+		{name: "oauth error", err: fmt.Errorf("failed to get token: %w", &core.OAuthError{StatusCode: 401, Code: "invalid_client"}), expected: ExitOAuthError},
+		{name: "cancelled", err: fmt.Errorf("device authorization was cancelled: %w", context.Canceled), expected: ExitCancelled},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, ExitCode(tt.err))
+		})
+	}
+}
+
+func TestErrorHint(t *testing.T) {
+	assert.Contains(t, errorHint(fmt.Errorf("%w: api", core.ErrClientNotFound)), "authkeeper list")
+	assert.Contains(t, errorHint(core.ErrWrongPassword), "master password")
+	assert.Contains(t, errorHint(core.ErrVaultNotFound), "--vault path")
+	assert.Contains(t, errorHint(&core.OAuthError{StatusCode: 401, Code: "invalid_client"}), "client ID, secret and auth method")
+	assert.Contains(t, errorHint(&core.OAuthError{StatusCode: 502}), "HTTP 502")
+	assert.Empty(t, errorHint(&core.OAuthError{StatusCode: 400, Code: "custom_error"}))
+	assert.Empty(t, errorHint(errors.New("boom")))
+}
+
+func TestReportError(t *testing.T) {
+	ReportError(&core.OAuthError{StatusCode: 400, Code: "invalid_scope"})
+	ReportError(context.Canceled)
+}
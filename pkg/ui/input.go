@@ -2,7 +2,9 @@ package ui
 
 import (
 	"bufio"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"strings"
 	"syscall"
@@ -10,38 +12,116 @@ import (
 	"golang.org/x/term"
 )
 
+const minPasswordLength = 8
+
+// stdinIsTerminal reports whether stdin is an interactive terminal
+var stdinIsTerminal = func() bool {
+	return term.IsTerminal(int(syscall.Stdin))
+}
+
 func readLine(prompt string) (string, error) {
-	fmt.Print(prompt)
+	fmt.Fprint(os.Stderr, prompt)
 	reader := bufio.NewReader(os.Stdin)
 	input, err := reader.ReadString('\n')
+	if errors.Is(err, io.EOF) && input == "" && !stdinIsTerminal() {
+		fmt.Fprintln(os.Stderr)
+		return "", fmt.Errorf("no input available for %q: stdin is not a terminal, pass the value with a flag", strings.TrimSpace(prompt))
+	}
 	if err != nil {
 		return "", err
 	}
 	return strings.TrimSpace(input), nil
 }
 
+// readLineDefault prompts with the current value shown and returns it when the input is empty
+func readLineDefault(prompt, current string) (string, error) {
+	if current != "" {
+		prompt = fmt.Sprintf("%s [%s]", prompt, current)
+	}
+
+	input, err := readLine(prompt + ": ")
+	if err != nil {
+		return "", err
+	}
+
+	if input == "" {
+		return current, nil
+	}
+
+	return input, nil
+}
+
+// readOptionalDefault prompts for an optional value with the current value shown, "-" removes it
+func readOptionalDefault(prompt, current string) (string, error) {
+	value, err := readLineDefault(prompt+` (optional, "-" to remove)`, current)
+	if err != nil || value == "-" {
+		return "", err
+	}
+
+	return value, nil
+}
+
 func readPassword(prompt string) (string, error) {
-	fmt.Print(prompt)
+	if !stdinIsTerminal() {
+		return "", fmt.Errorf("cannot prompt for %q: stdin is not a terminal, pass the value with a flag", strings.TrimSpace(prompt))
+	}
+
+	fmt.Fprint(os.Stderr, prompt)
 	password, err := term.ReadPassword(int(syscall.Stdin))
-	fmt.Println()
+	fmt.Fprintln(os.Stderr)
 	if err != nil {
 		return "", err
 	}
 	return string(password), nil
 }
 
-func confirm(prompt string) bool {
+// readNewPassword asks for a new password twice until it is long enough and both entries match
+func readNewPassword(prompt, confirmPrompt string) (string, error) {
+	for {
+		password, err := readPassword(prompt)
+		if err != nil {
+			return "", err
+		}
+
+		if len(password) < minPasswordLength {
+			printError(fmt.Sprintf("Password must be at least %d characters long", minPasswordLength))
+			fmt.Fprintln(os.Stderr)
+			continue
+		}
+
+		confirmPassword, err := readPassword(confirmPrompt)
+		if err != nil {
+			return "", err
+		}
+
+		if password != confirmPassword {
+			printError("Passwords do not match. Please try again.")
+			fmt.Fprintln(os.Stderr)
+			continue
+		}
+
+		return password, nil
+	}
+}
+
+// confirm asks a yes/no question. Without a terminal there is nobody to answer it, so it fails with a usage
+// error naming the flag that skips the question instead of silently cancelling.
+func confirm(prompt, skipFlag string) (bool, error) {
+	if !stdinIsTerminal() {
+		return false, UsageError(fmt.Errorf("cannot ask %q: stdin is not a terminal, pass %s to confirm", prompt, skipFlag))
+	}
+
 	for {
 		input, err := readLine(fmt.Sprintf("%s (y/n): ", prompt))
 		if err != nil {
-			return false
+			return false, err
 		}
 		input = strings.ToLower(input)
 		if input == "y" || input == "yes" {
-			return true
+			return true, nil
 		}
 		if input == "n" || input == "no" {
-			return false
+			return false, nil
 		}
 		printError("Please enter 'y' or 'n'")
 	}
@@ -53,13 +133,13 @@ func selectFromList(title string, items []string) (int, error) {
 	}
 
 	printTitle(title)
-	fmt.Println()
+	fmt.Fprintln(os.Stderr)
 
 	for i, item := range items {
-		fmt.Printf("%s%d%s. %s\n", colorCyan, i+1, colorReset, item)
+		fmt.Fprintf(os.Stderr, "%s%d%s. %s\n", colorCyan, i+1, colorReset, item)
 	}
 
-	fmt.Println()
+	fmt.Fprintln(os.Stderr)
 	for {
 		input, err := readLine("Enter number: ")
 		if err != nil {
@@ -0,0 +1,403 @@
+package ui
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ksysoev/authkeeper/pkg/core"
+	"gopkg.in/yaml.v3"
+)
+
+// OutputFormat selects how command results are written to stdout
+type OutputFormat string
+
+const (
+	// OutputText is the decorated, human readable output
+	OutputText OutputFormat = "text"
+	// OutputJSON writes results as JSON
+	OutputJSON OutputFormat = "json"
+	// OutputYAML writes results as YAML
+	OutputYAML OutputFormat = "yaml"
+	// OutputEnv writes shell export statements
+	OutputEnv OutputFormat = "env"
+	// OutputRaw writes only the bare value, e.g. the access token
+	OutputRaw OutputFormat = "raw"
+	// OutputHeader writes an HTTP Authorization header line
+	OutputHeader OutputFormat = "header"
+)
+
+// OutputFormats lists all supported output formats
+var OutputFormats = []OutputFormat{OutputText, OutputJSON, OutputYAML, OutputEnv, OutputRaw, OutputHeader}
+
+// ParseOutputFormat validates an output format name
+func ParseOutputFormat(name string) (OutputFormat, error) {
+	for _, format := range OutputFormats {
+		if string(format) == name {
+			return format, nil
+		}
+	}
+
+	return "", fmt.Errorf("unknown output format %q, expected one of: %s", name, outputFormatNames())
+}
+
+func outputFormatNames() string {
+	names := make([]string, len(OutputFormats))
+	for i, format := range OutputFormats {
+		names[i] = string(format)
+	}
+
+	return strings.Join(names, ", ")
+}
+
+type tokenOutput struct {
+	Client      string `json:"client" yaml:"client"`
+	AccessToken string `json:"access_token" yaml:"access_token"`
+	TokenType   string `json:"token_type" yaml:"token_type"`
+	ExpiresIn   int    `json:"expires_in" yaml:"expires_in"`
+	Scope       string `json:"scope,omitempty" yaml:"scope,omitempty"`
+	CertBinding string `json:"cnf_x5t_s256,omitempty" yaml:"cnf_x5t_s256,omitempty"`
+	// IssuedType is the issued_token_type of exchanged tokens
+	IssuedType string `json:"issued_token_type,omitempty" yaml:"issued_token_type,omitempty"`
+	// JWT is the decoded access token, added on request
+	JWT *jwtOutput `json:"jwt,omitempty" yaml:"jwt,omitempty"`
+}
+
+type jwtOutput struct {
+	Header    map[string]any `json:"header" yaml:"header"`
+	Claims    map[string]any `json:"claims" yaml:"claims"`
+	Status    string         `json:"status" yaml:"status"`
+	IssuedAt  *time.Time     `json:"issued_at,omitempty" yaml:"issued_at,omitempty"`
+	NotBefore *time.Time     `json:"not_before,omitempty" yaml:"not_before,omitempty"`
+	ExpiresAt *time.Time     `json:"expires_at,omitempty" yaml:"expires_at,omitempty"`
+}
+
+type clientOutput struct {
+	Name       string   `json:"name" yaml:"name"`
+	ClientID   string   `json:"client_id" yaml:"client_id"`
+	TokenURL   string   `json:"token_url" yaml:"token_url"`
+	Issuer     string   `json:"issuer,omitempty" yaml:"issuer,omitempty"`
+	Scopes     []string `json:"scopes" yaml:"scopes"`
+	AuthMethod string   `json:"auth_method" yaml:"auth_method"`
+	KeyID      string   `json:"key_id,omitempty" yaml:"key_id,omitempty"`
+	GrantType  string   `json:"grant_type" yaml:"grant_type"`
+	// Legacy flags clients using a deprecated grant such as password
+	Legacy    bool       `json:"legacy,omitempty" yaml:"legacy,omitempty"`
+	Username  string     `json:"username,omitempty" yaml:"username,omitempty"`
+	Audience  string     `json:"audience,omitempty" yaml:"audience,omitempty"`
+	Resource  []string   `json:"resource,omitempty" yaml:"resource,omitempty"`
+	PinnedKey string     `json:"pinned_public_key,omitempty" yaml:"pinned_public_key,omitempty"`
+	Insecure  bool       `json:"insecure_skip_verify,omitempty" yaml:"insecure_skip_verify,omitempty"`
+	AuthURL   string     `json:"authorization_url,omitempty" yaml:"authorization_url,omitempty"`
+	DeviceURL string     `json:"device_authorization_url,omitempty" yaml:"device_authorization_url,omitempty"`
+	CreatedAt time.Time  `json:"created_at" yaml:"created_at"`
+	UpdatedAt *time.Time `json:"updated_at,omitempty" yaml:"updated_at,omitempty"`
+}
+
+// writeToken renders an issued token to the CLI output in the configured format
+func (c *CLI) writeToken(clientName string, token *core.Token) error {
+	switch c.format {
+	case OutputJSON, OutputYAML:
+		out := tokenOutput{
+			Client:      clientName,
+			AccessToken: token.AccessToken,
+			TokenType:   token.TokenType,
+			ExpiresIn:   token.ExpiresIn,
+			Scope:       token.Scope,
+			CertBinding: token.CertThumbprint,
+			IssuedType:  token.IssuedTokenType,
+		}
+
+		if jwt := c.decodeToken(token); jwt != nil {
+			out.JWT = c.jwtOutput(jwt)
+		}
+
+		return c.writeStructured(out)
+	case OutputEnv:
+		_, err := fmt.Fprintf(c.out, "export ACCESS_TOKEN=%s\nexport TOKEN_TYPE=%s\nexport EXPIRES_IN=%d\nexport SCOPE=%s\n",
+			shellQuote(token.AccessToken), shellQuote(token.TokenType), token.ExpiresIn, shellQuote(token.Scope))
+		if err == nil && token.IssuedTokenType != "" {
+			_, err = fmt.Fprintf(c.out, "export ISSUED_TOKEN_TYPE=%s\n", shellQuote(token.IssuedTokenType))
+		}
+
+		return err
+	case OutputRaw:
+		_, err := fmt.Fprintln(c.out, token.AccessToken)
+		return err
+	case OutputHeader:
+		_, err := fmt.Fprintf(c.out, "Authorization: %s %s\n", authScheme(token.TokenType), token.AccessToken)
+		return err
+	default:
+		_, err := fmt.Fprintf(c.out, "Client: %s\n\nAccess Token:\n%s\n\nToken Type: %s\nExpires In: %d seconds\nScope: %s\n",
+			clientName, token.AccessToken, token.TokenType, token.ExpiresIn, token.Scope)
+		if err == nil && token.IssuedTokenType != "" {
+			_, err = fmt.Fprintf(c.out, "Issued Token Type: %s\n", token.IssuedTokenType)
+		}
+		if err == nil && token.CertThumbprint != "" {
+			_, err = fmt.Fprintf(c.out, "Bound to certificate (x5t#S256): %s\n", token.CertThumbprint)
+		}
+		if jwt := c.decodeToken(token); err == nil && jwt != nil {
+			fmt.Fprintln(c.out)
+			err = c.writeJWTText(jwt)
+		}
+
+		return err
+	}
+}
+
+// decodeToken decodes the access token when decoding was requested. Opaque tokens are only reported,
+// they are valid tokens nonetheless.
+func (c *CLI) decodeToken(token *core.Token) *core.JWT {
+	if !c.decode {
+		return nil
+	}
+
+	jwt, err := core.ParseJWT(token.AccessToken)
+	if err != nil {
+		printWarning(fmt.Sprintf("Access token cannot be decoded: %v", err))
+		return nil
+	}
+
+	return jwt
+}
+
+// writeJWT renders the decoded header and claims of a JWT and its validity in the configured format
+func (c *CLI) writeJWT(jwt *core.JWT) error {
+	switch c.format {
+	case OutputJSON, OutputYAML:
+		return c.writeStructured(c.jwtOutput(jwt))
+	case OutputEnv, OutputRaw, OutputHeader:
+		return fmt.Errorf("output format %q is not supported for decoded tokens", c.format)
+	default:
+		return c.writeJWTText(jwt)
+	}
+}
+
+func (c *CLI) jwtOutput(jwt *core.JWT) *jwtOutput {
+	return &jwtOutput{
+		Header:    jwt.Header,
+		Claims:    jwt.Claims,
+		Status:    string(jwt.Status(c.now())),
+		IssuedAt:  claimTime(jwt, "iat"),
+		NotBefore: claimTime(jwt, "nbf"),
+		ExpiresAt: claimTime(jwt, "exp"),
+	}
+}
+
+// claimTime returns a NumericDate claim in UTC, nil when the token has none
+func claimTime(jwt *core.JWT, claim string) *time.Time {
+	t, ok := jwt.Time(claim)
+	if !ok {
+		return nil
+	}
+
+	t = t.UTC()
+
+	return &t
+}
+
+// writeJWTText pretty-prints the header and claims, followed by the token's times relative to now
+// and whether it is expired or not yet valid
+func (c *CLI) writeJWTText(jwt *core.JWT) error {
+	header, err := json.MarshalIndent(jwt.Header, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode header: %w", err)
+	}
+
+	claims, err := json.MarshalIndent(jwt.Claims, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode claims: %w", err)
+	}
+
+	fmt.Fprintf(c.out, "Header:\n%s\n\nClaims:\n%s\n\n", header, claims)
+
+	now := c.now()
+	times := []struct{ label, claim string }{{"Issued At: ", "iat"}, {"Not Before:", "nbf"}, {"Expires At:", "exp"}}
+
+	for _, tt := range times {
+		if t, ok := jwt.Time(tt.claim); ok {
+			fmt.Fprintf(c.out, "%s %s (%s)\n", tt.label, t.Local().Format("2006-01-02 15:04:05 MST"), relativeTime(t, now))
+		}
+	}
+
+	switch jwt.Status(now) {
+	case core.JWTExpired:
+		fmt.Fprintf(c.out, "Status:     %sexpired%s\n", colorRed, colorReset)
+	case core.JWTNotYetValid:
+		fmt.Fprintf(c.out, "Status:     %snot yet valid%s\n", colorYellow, colorReset)
+	default:
+		fmt.Fprintf(c.out, "Status:     %svalid%s\n", colorGreen, colorReset)
+	}
+
+	_, err = fmt.Fprintln(c.out, "Signature:  not verified")
+
+	return err
+}
+
+// relativeTime describes t relative to now, e.g. "in 55m0s" or "2d3h ago"
+func relativeTime(t, now time.Time) string {
+	d := t.Sub(now).Round(time.Second)
+
+	switch {
+	case d > 0:
+		return "in " + shortDuration(d)
+	case d < 0:
+		return shortDuration(-d) + " ago"
+	default:
+		return "now"
+	}
+}
+
+// shortDuration formats a duration with its two largest units
+func shortDuration(d time.Duration) string {
+	days := int(d / (24 * time.Hour))
+	hours := int(d/time.Hour) % 24
+	minutes := int(d/time.Minute) % 60
+	seconds := int(d/time.Second) % 60
+
+	switch {
+	case days > 0:
+		return fmt.Sprintf("%dd%dh", days, hours)
+	case hours > 0:
+		return fmt.Sprintf("%dh%dm", hours, minutes)
+	case minutes > 0:
+		return fmt.Sprintf("%dm%ds", minutes, seconds)
+	default:
+		return fmt.Sprintf("%ds", seconds)
+	}
+}
+
+// writeClients renders client details to the CLI output in the configured format
+func (c *CLI) writeClients(clients []core.Client) error {
+	switch c.format {
+	case OutputJSON, OutputYAML:
+		out := make([]clientOutput, len(clients))
+		for i, client := range clients {
+			out[i] = clientOutput{
+				Name:       client.Name,
+				ClientID:   client.ClientID,
+				TokenURL:   client.TokenURL,
+				Issuer:     client.Issuer,
+				Scopes:     client.Scopes,
+				AuthMethod: string(client.AuthMethod()),
+				KeyID:      client.KeyID,
+				GrantType:  string(client.Grant()),
+				Legacy:     client.Grant().Legacy(),
+				Username:   client.Username,
+				Audience:   client.Audience,
+				Resource:   client.Resource,
+				PinnedKey:  client.HTTP.PinnedPublicKey,
+				Insecure:   client.HTTP.InsecureSkipVerify,
+				AuthURL:    client.AuthorizationURL,
+				DeviceURL:  client.DeviceAuthorizationURL,
+				CreatedAt:  client.CreatedAt,
+			}
+
+			if out[i].Scopes == nil {
+				out[i].Scopes = []string{}
+			}
+
+			if !client.UpdatedAt.IsZero() {
+				out[i].UpdatedAt = &client.UpdatedAt
+			}
+		}
+
+		return c.writeStructured(out)
+	case OutputRaw:
+		for _, client := range clients {
+			if _, err := fmt.Fprintln(c.out, client.Name); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	default:
+		for i, client := range clients {
+			fmt.Fprintf(c.out, "%s%d. %s%s\n", colorCyan, i+1, client.Name, colorReset)
+			fmt.Fprintf(c.out, "   Client ID:  %s\n", client.ClientID)
+			fmt.Fprintf(c.out, "   Token URL:  %s\n", client.TokenURL)
+			if client.Issuer != "" {
+				fmt.Fprintf(c.out, "   Issuer:     %s\n", client.Issuer)
+			}
+			fmt.Fprintf(c.out, "   Scopes:     %s\n", strings.Join(client.Scopes, ", "))
+			fmt.Fprintf(c.out, "   Auth:       %s\n", client.AuthMethod())
+			if client.Grant().Legacy() {
+				fmt.Fprintf(c.out, "   Grant:      %s %s(legacy)%s\n", client.Grant(), colorYellow, colorReset)
+			} else {
+				fmt.Fprintf(c.out, "   Grant:      %s\n", client.Grant())
+			}
+			if client.Username != "" {
+				fmt.Fprintf(c.out, "   Username:   %s\n", client.Username)
+			}
+			if client.KeyID != "" {
+				fmt.Fprintf(c.out, "   Key ID:     %s\n", client.KeyID)
+			}
+			if client.Audience != "" {
+				fmt.Fprintf(c.out, "   Audience:   %s\n", client.Audience)
+			}
+			if len(client.Resource) > 0 {
+				fmt.Fprintf(c.out, "   Resource:   %s\n", strings.Join(client.Resource, ", "))
+			}
+			if client.HTTP.PinnedPublicKey != "" {
+				fmt.Fprintf(c.out, "   TLS:        pinned public key %s\n", client.HTTP.PinnedPublicKey)
+			}
+			if client.HTTP.InsecureSkipVerify {
+				fmt.Fprintf(c.out, "   TLS:        %scertificate verification disabled (insecure)%s\n", colorRed, colorReset)
+			}
+			fmt.Fprintf(c.out, "   Created:    %s\n", client.CreatedAt.Format("2006-01-02 15:04:05"))
+			if !client.UpdatedAt.IsZero() {
+				fmt.Fprintf(c.out, "   Updated:    %s\n", client.UpdatedAt.Format("2006-01-02 15:04:05"))
+			}
+			fmt.Fprintln(c.out)
+		}
+
+		return nil
+	}
+}
+
+// writeStructured encodes v as JSON or YAML depending on the configured format
+func (c *CLI) writeStructured(v any) error {
+	if c.format == OutputYAML {
+		enc := yaml.NewEncoder(c.out)
+		enc.SetIndent(2)
+
+		if err := enc.Encode(v); err != nil {
+			return fmt.Errorf("failed to encode YAML output: %w", err)
+		}
+
+		return enc.Close()
+	}
+
+	enc := json.NewEncoder(c.out)
+	enc.SetIndent("", "  ")
+
+	if err := enc.Encode(v); err != nil {
+		return fmt.Errorf("failed to encode JSON output: %w", err)
+	}
+
+	return nil
+}
+
+// authScheme returns the Authorization header scheme for a token type, defaulting to Bearer
+func authScheme(tokenType string) string {
+	if tokenType == "" || strings.EqualFold(tokenType, "bearer") {
+		return "Bearer"
+	}
+
+	return tokenType
+}
+
+// shellQuote quotes s for safe use in POSIX shell export statements
+func shellQuote(s string) string {
+	if s != "" && strings.Trim(s, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789-_.~+/=:") == "" {
+		return s
+	}
+
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// isMachineReadable reports whether decorations like tips should be skipped
+func (c *CLI) isMachineReadable() bool {
+	return c.format != OutputText
+}
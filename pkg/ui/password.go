@@ -0,0 +1,124 @@
+package ui
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// PasswordEnvVar is the environment variable holding the master password for non-interactive use
+const PasswordEnvVar = "AUTHKEEPER_PASSWORD"
+
+var errNoPasswordSource = errors.New("no terminal available to prompt for the master password; " +
+	"set " + PasswordEnvVar + " or use --password-file, --password-fd or --password-command")
+
+// PasswordSource describes non-interactive sources of the master password.
+// Sources are tried in order: FD, File, Command and finally the AUTHKEEPER_PASSWORD
+// environment variable; the terminal prompt is used only when none of them is set.
+type PasswordSource struct {
+	// File is a path to a file whose first line is the password
+	File string
+	// FD is an open file descriptor to read the password from, negative when unset
+	FD int
+	// Command is run through the shell and the first line of its stdout is the password
+	Command string
+}
+
+// read returns the password from the first configured source.
+// The boolean result is false when no non-interactive source is configured.
+func (s PasswordSource) read() (string, bool, error) {
+	var (
+		password string
+		origin   string
+		err      error
+	)
+
+	switch {
+	case s.FD >= 0:
+		origin = fmt.Sprintf("file descriptor %d", s.FD)
+		password, err = readPasswordFD(s.FD)
+	case s.File != "":
+		origin = s.File
+		password, err = readPasswordFile(s.File)
+	case s.Command != "":
+		origin = "password command"
+		password, err = runPasswordCommand(s.Command)
+	default:
+		value, ok := os.LookupEnv(PasswordEnvVar)
+		if !ok {
+			return "", false, nil
+		}
+
+		origin = PasswordEnvVar
+		password = value
+	}
+
+	if err != nil {
+		return "", true, fmt.Errorf("failed to read master password from %s: %w", origin, err)
+	}
+
+	if password == "" {
+		return "", true, fmt.Errorf("master password from %s is empty", origin)
+	}
+
+	return password, true, nil
+}
+
+// readPasswordFD reads the password from an inherited file descriptor and closes it, it is of no further use
+func readPasswordFD(fd int) (string, error) {
+	f := os.NewFile(uintptr(fd), "password-fd")
+	if f == nil {
+		return "", fmt.Errorf("invalid file descriptor")
+	}
+	defer func() { _ = f.Close() }()
+
+	return readFirstLine(f)
+}
+
+func readPasswordFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = f.Close() }()
+
+	return readFirstLine(f)
+}
+
+// runPasswordCommand runs command through the shell, passing through stdin and stderr
+// so tools like gpg or password managers can still interact with the user
+func runPasswordCommand(command string) (string, error) {
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.Command("cmd", "/C", command)
+	} else {
+		cmd = exec.Command("sh", "-c", command)
+	}
+
+	var stdout bytes.Buffer
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = &stdout
+	cmd.Stderr = os.Stderr
+
+	if err := cmd.Run(); err != nil {
+		return "", err
+	}
+
+	return readFirstLine(&stdout)
+}
+
+// readFirstLine returns the first line of r without the trailing line break
+func readFirstLine(r io.Reader) (string, error) {
+	line, err := bufio.NewReader(r).ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return "", err
+	}
+
+	return strings.TrimRight(line, "\r\n"), nil
+}
@@ -0,0 +1,152 @@
+package ui
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPasswordSource_Read(t *testing.T) {
+	passwordFile := filepath.Join(t.TempDir(), "password")
+	require.NoError(t, os.WriteFile(passwordFile, []byte("file-password\nsecond line\n"), 0600))
+
+	tests := []struct {
+		name        string
+		source      PasswordSource
+		env         *string
+		expected    string
+		expectedOK  bool
+		expectedErr string
+	}{
+		{
+			name:       "no source",
+			source:     PasswordSource{FD: -1},
+			expectedOK: false,
+		},
+		{
+			name:       "environment variable",
+			source:     PasswordSource{FD: -1},
+			env:        ptr("env-password"),
+			expected:   "env-password",
+			expectedOK: true,
+		},
+		{
+			name:       "file takes precedence over environment",
+			source:     PasswordSource{FD: -1, File: passwordFile},
+			env:        ptr("env-password"),
+			expected:   "file-password",
+			expectedOK: true,
+		},
+		{
+			name:        "missing file",
+			source:      PasswordSource{FD: -1, File: filepath.Join(t.TempDir(), "missing")},
+			expectedOK:  true,
+			expectedErr: "failed to read master password from",
+		},
+		{
+			name:        "empty environment variable",
+			source:      PasswordSource{FD: -1},
+			env:         ptr(""),
+			expectedOK:  true,
+			expectedErr: "is empty",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv(PasswordEnvVar, "")
+			if tt.env != nil {
+				t.Setenv(PasswordEnvVar, *tt.env)
+			} else {
+				require.NoError(t, os.Unsetenv(PasswordEnvVar))
+			}
+
+			password, ok, err := tt.source.read()
+
+			assert.Equal(t, tt.expectedOK, ok)
+
+			if tt.expectedErr != "" {
+				assert.Error(t, err)
+				assert.Contains(t, err.Error(), tt.expectedErr)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tt.expected, password)
+			}
+		})
+	}
+}
+
+func ptr(s string) *string {
+	return &s
+}
+
+func TestPasswordSource_ReadFD(t *testing.T) {
+	r, w, err := os.Pipe()
+	require.NoError(t, err)
+
+	_, err = w.WriteString("fd-password\n")
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+
+	source := PasswordSource{FD: int(r.Fd()), File: "ignored"}
+
+	password, ok, err := source.read()
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, "fd-password", password)
+
+	// The descriptor is closed once the password is read
+	assert.Error(t, r.Close())
+}
+
+func TestPasswordSource_ReadCommand(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("shell command test requires sh")
+	}
+
+	password, ok, err := PasswordSource{FD: -1, Command: "printf 'cmd-password\\r\\n'"}.read()
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, "cmd-password", password)
+
+	_, ok, err = PasswordSource{FD: -1, Command: "exit 3"}.read()
+	assert.True(t, ok)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "password command")
+}
+
+func TestPromptMasterPassword_NonInteractive(t *testing.T) {
+	prev := stdinIsTerminal
+	stdinIsTerminal = func() bool { return false }
+	t.Cleanup(func() { stdinIsTerminal = prev })
+
+	cli := NewCLI(NewMockCoreService(t))
+
+	t.Run("environment variable", func(t *testing.T) {
+		t.Setenv(PasswordEnvVar, "env-password")
+
+		password, err := cli.PromptMasterPassword(false)
+		require.NoError(t, err)
+		assert.Equal(t, "env-password", password)
+	})
+
+	t.Run("too short for a new vault", func(t *testing.T) {
+		t.Setenv(PasswordEnvVar, "short")
+
+		_, err := cli.PromptMasterPassword(true)
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "at least 8 characters")
+	})
+
+	t.Run("no terminal and no source", func(t *testing.T) {
+		t.Setenv(PasswordEnvVar, "")
+		require.NoError(t, os.Unsetenv(PasswordEnvVar))
+
+		_, err := cli.PromptMasterPassword(false)
+		assert.ErrorIs(t, err, errNoPasswordSource)
+	})
+}
@@ -2,33 +2,34 @@ package ui
 
 import (
 	"fmt"
+	"os"
 )
 
 func printTitle(text string) {
-	fmt.Printf("%s%s%s%s\n", colorBold, colorMagenta, text, colorReset)
+	fmt.Fprintf(os.Stderr, "%s%s%s%s\n", colorBold, colorMagenta, text, colorReset)
 }
 
 func printSuccess(text string) {
-	fmt.Printf("%s✓ %s%s\n", colorGreen, text, colorReset)
+	fmt.Fprintf(os.Stderr, "%s✓ %s%s\n", colorGreen, text, colorReset)
 }
 
 func printError(text string) {
-	fmt.Printf("%s✗ Error: %s%s\n", colorRed, text, colorReset)
+	fmt.Fprintf(os.Stderr, "%s✗ Error: %s%s\n", colorRed, text, colorReset)
 }
 
 func printInfo(text string) {
-	fmt.Printf("%s%s%s\n", colorCyan, text, colorReset)
+	fmt.Fprintf(os.Stderr, "%s%s%s\n", colorCyan, text, colorReset)
 }
 
 func printWarning(text string) {
-	fmt.Printf("%s⚠ %s%s\n", colorYellow, text, colorReset)
+	fmt.Fprintf(os.Stderr, "%s⚠ %s%s\n", colorYellow, text, colorReset)
 }
 
 func printMuted(text string) {
-	fmt.Printf("%s%s%s\n", colorGray, text, colorReset)
+	fmt.Fprintf(os.Stderr, "%s%s%s\n", colorGray, text, colorReset)
 }
 
 func printProgress(text string) {
-	fmt.Printf("%s%s...%s ", colorCyan, text, colorReset)
-	fmt.Println()
+	fmt.Fprintf(os.Stderr, "%s%s...%s ", colorCyan, text, colorReset)
+	fmt.Fprintln(os.Stderr)
 }
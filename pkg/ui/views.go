@@ -3,14 +3,147 @@ package ui
 import (
 	"context"
 	"fmt"
+	"io"
+	"maps"
+	"os"
+	"slices"
 	"strings"
 	"time"
 
 	"github.com/ksysoev/authkeeper/pkg/core"
 )
 
+// ClientInput holds client fields supplied on the command line when adding a client.
+// Empty fields that the client needs are prompted for interactively.
+type ClientInput struct {
+	Name         string
+	ClientID     string
+	ClientSecret string
+	TokenURL     string
+	Issuer       string
+	// Scopes are space-separated, nil when not given. They are optional and only prompted for on a terminal.
+	Scopes           *string
+	AuthMethod       string
+	PrivateKeyFile   string
+	KeyID            string
+	SigningAlgorithm string
+	TLSCertFile      string
+	TLSKeyFile       string
+	GrantType        string
+	AuthorizationURL string
+	RedirectURL      string
+	// DeviceAuthorizationURL is the device code endpoint for the device_code grant
+	DeviceAuthorizationURL string
+	// ServiceAccountFile is a Google service account JSON key file providing the client ID, key and token URL
+	ServiceAccountFile string
+	// AssertionIssuer, AssertionSubject and AssertionAudience override the claims of jwt_bearer assertions
+	AssertionIssuer   string
+	AssertionSubject  string
+	AssertionAudience string
+	// Username and Password are the stored credentials of the legacy password grant
+	Username string
+	Password string
+	// Audience and Resource name the API the tokens are for, Params are key=value form parameters
+	// and Headers "Name: value" HTTP headers added to every request
+	Audience string
+	Resource []string
+	Params   []string
+	Headers  []string
+	// Timeout, ProxyURL, TLSMinVersion and Retries override the global HTTP settings for the client,
+	// CAFile is a PEM file with CA certificates trusted for the client's requests
+	Timeout       time.Duration
+	ProxyURL      string
+	CAFile        string
+	TLSMinVersion string
+	Retries       *int
+	// PinnedPublicKey trusts a server presenting this public key, InsecureSkipVerify disables
+	// certificate verification for servers on loopback and private addresses
+	PinnedPublicKey    string
+	InsecureSkipVerify bool
+	// Yes saves the client without asking for confirmation
+	Yes bool
+}
+
 // AddClient handles the add client flow
-func (c *CLI) AddClient(ctx context.Context, name, clientID, clientSecret, tokenURL, scopesStr string) error {
+func (c *CLI) AddClient(ctx context.Context, in ClientInput) error {
+	authMethod, err := core.ParseAuthMethod(in.AuthMethod)
+	if err != nil {
+		return err
+	}
+
+	grant, err := core.ParseGrantType(in.GrantType)
+	if err != nil {
+		return err
+	}
+
+	client := core.Client{
+		Name:                    in.Name,
+		ClientID:                in.ClientID,
+		ClientSecret:            in.ClientSecret,
+		TokenURL:                in.TokenURL,
+		Issuer:                  in.Issuer,
+		TokenEndpointAuthMethod: authMethod,
+		KeyID:                   in.KeyID,
+		SigningAlgorithm:        in.SigningAlgorithm,
+		GrantType:               grant,
+		AuthorizationURL:        in.AuthorizationURL,
+		RedirectURL:             in.RedirectURL,
+		DeviceAuthorizationURL:  in.DeviceAuthorizationURL,
+		AssertionIssuer:         in.AssertionIssuer,
+		AssertionSubject:        in.AssertionSubject,
+		AssertionAudience:       in.AssertionAudience,
+		Username:                in.Username,
+		Password:                in.Password,
+		Audience:                in.Audience,
+		Resource:                in.Resource,
+		HTTP: core.HTTPSettings{
+			Timeout:            in.Timeout,
+			ProxyURL:           in.ProxyURL,
+			TLSMinVersion:      in.TLSMinVersion,
+			Retries:            in.Retries,
+			PinnedPublicKey:    in.PinnedPublicKey,
+			InsecureSkipVerify: in.InsecureSkipVerify,
+		},
+	}
+
+	if in.CAFile != "" {
+		if client.HTTP.CACertificates, err = readKeyFile(in.CAFile); err != nil {
+			return err
+		}
+	}
+
+	if client.ExtraParams, err = core.ParseExtraParams(in.Params); err != nil {
+		return err
+	}
+
+	if client.ExtraHeaders, err = core.ParseExtraHeaders(in.Headers); err != nil {
+		return err
+	}
+
+	if in.PrivateKeyFile != "" {
+		if client.PrivateKey, err = readKeyFile(in.PrivateKeyFile); err != nil {
+			return err
+		}
+	}
+
+	if in.TLSCertFile != "" {
+		if client.TLSCertificate, err = readKeyFile(in.TLSCertFile); err != nil {
+			return err
+		}
+	}
+
+	if in.TLSKeyFile != "" {
+		if client.TLSKey, err = readKeyFile(in.TLSKeyFile); err != nil {
+			return err
+		}
+	}
+
+	if in.ServiceAccountFile != "" {
+		if err := applyServiceAccount(&client, in); err != nil {
+			return err
+		}
+	}
+
 	password, err := c.PromptMasterPassword(!c.service.IsRepositoryInitialized())
 	if err != nil {
 		return fmt.Errorf("failed to get master password: %w", err)
@@ -21,83 +154,125 @@ func (c *CLI) AddClient(ctx context.Context, name, clientID, clientSecret, token
 		return err
 	}
 
+	if client.Issuer != "" {
+		if err := c.discoverIssuer(ctx, &client, in.AuthMethod == "" && in.ServiceAccountFile == ""); err != nil {
+			return err
+		}
+	}
+
+	needsSecret := client.RequiresSecret()
+	needsKey := client.AuthMethod() == core.AuthMethodPrivateKeyJWT || client.Grant() == core.GrantJWTBearer
+	needsCert := client.UsesMutualTLS()
+	needsAuthorizationURL := client.Grant() == core.GrantAuthorizationCode
+	needsDeviceURL := client.Grant() == core.GrantDeviceCode
+
 	// Prompt for missing fields
-	if name == "" || clientID == "" || clientSecret == "" || tokenURL == "" {
+	if client.Name == "" || client.ClientID == "" || (needsSecret && client.ClientSecret == "") ||
+		(needsKey && client.PrivateKey == "") || (needsCert && client.TLSCertificate == "") || client.TokenURL == "" ||
+		(needsAuthorizationURL && client.AuthorizationURL == "") || (needsDeviceURL && client.DeviceAuthorizationURL == "") {
 		printInfo("Enter client credentials")
-		fmt.Println()
+		fmt.Fprintln(os.Stderr)
+	}
+
+	if client.Name == "" {
+		client.Name, err = readLine("Client Name: ")
+		if err != nil {
+			return err
+		}
+	}
+
+	if client.ClientID == "" {
+		client.ClientID, err = readLine("Client ID: ")
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := promptCredentials(&client); err != nil {
+		return err
+	}
+
+	if client.TokenURL == "" {
+		client.TokenURL, err = readLine("Token URL: ")
+		if err != nil {
+			return err
+		}
 	}
 
-	if name == "" {
-		name, err = readLine("Client Name: ")
+	if needsAuthorizationURL && client.AuthorizationURL == "" {
+		client.AuthorizationURL, err = readLine("Authorization URL: ")
 		if err != nil {
 			return err
 		}
 	}
 
-	if clientID == "" {
-		clientID, err = readLine("Client ID: ")
+	if needsDeviceURL && client.DeviceAuthorizationURL == "" {
+		client.DeviceAuthorizationURL, err = readLine("Device Authorization URL: ")
 		if err != nil {
 			return err
 		}
 	}
 
-	if clientSecret == "" {
-		clientSecret, err = readPassword("Client Secret: ")
+	// Stored user credentials are optional, without them they are asked for when a token is issued
+	if client.Grant() == core.GrantPassword && client.Username == "" && stdinIsTerminal() {
+		client.Username, err = readLine("Username (optional, asked for on every token request when empty): ")
 		if err != nil {
 			return err
 		}
 	}
 
-	if tokenURL == "" {
-		tokenURL, err = readLine("Token URL: ")
+	if client.Username != "" && client.Password == "" {
+		client.Password, err = readPassword("Password: ")
 		if err != nil {
 			return err
 		}
 	}
 
-	if scopesStr == "" {
+	var scopesStr string
+	if in.Scopes != nil {
+		scopesStr = *in.Scopes
+	} else if stdinIsTerminal() {
 		scopesStr, err = readLine("Scopes (optional, space-separated): ")
 		if err != nil {
 			return err
 		}
 	}
 
-	var scopes []string
 	if scopesStr != "" {
-		scopes = strings.Fields(scopesStr)
+		client.Scopes = strings.Fields(scopesStr)
 	}
 
 	// Confirm
-	fmt.Println()
+	fmt.Fprintln(os.Stderr)
 	printInfo("Review client details:")
-	fmt.Println()
-	fmt.Printf("Name:          %s\n", name)
-	fmt.Printf("Client ID:     %s\n", clientID)
-	fmt.Printf("Client Secret: %s\n", strings.Repeat("•", len(clientSecret)))
-	fmt.Printf("Token URL:     %s\n", tokenURL)
-	fmt.Printf("Scopes:        %s\n", strings.Join(scopes, ", "))
-	fmt.Println()
-
-	if !confirm("Save this client?") {
-		printWarning("Cancelled")
-		return nil
+	fmt.Fprintln(os.Stderr)
+	printClientReview(&client)
+	fmt.Fprintln(os.Stderr)
+
+	if client.HTTP.InsecureSkipVerify {
+		printInsecureWarning(client.Name)
+		fmt.Fprintln(os.Stderr)
+	}
+
+	if !in.Yes {
+		ok, err := confirm("Save this client?", "--yes")
+		if err != nil {
+			return err
+		}
+
+		if !ok {
+			printWarning("Cancelled")
+			return nil
+		}
 	}
 
 	// Save
 	printProgress("Saving to encrypted vault")
 
-	client := core.Client{
-		Name:         name,
-		ClientID:     clientID,
-		ClientSecret: clientSecret,
-		TokenURL:     tokenURL,
-		Scopes:       scopes,
-		CreatedAt:    time.Now(),
-	}
+	client.CreatedAt = time.Now()
 
 	err = c.service.AddClient(ctx, client)
 	if err != nil {
-		printError(err.Error())
 		return err
 	}
 
@@ -105,147 +280,305 @@ func (c *CLI) AddClient(ctx context.Context, name, clientID, clientSecret, token
 	return nil
 }
 
-// IssueToken handles the token issuance flow
-func (c *CLI) IssueToken(ctx context.Context, clientName string) error {
-	// Check if repository is initialized
-	if !c.service.IsRepositoryInitialized() {
-		printWarning("Vault not found")
-		printMuted("Use 'authkeeper add' to create vault and add your first client")
-		return nil
+// noClientsError is returned by commands acting on a client when the vault has no clients
+func noClientsError(clientName string) error {
+	if clientName != "" {
+		return fmt.Errorf("%w: %s", core.ErrClientNotFound, clientName)
 	}
 
-	password, err := c.PromptMasterPassword(false)
-	if err != nil {
-		return fmt.Errorf("failed to read master password: %w", err)
-	}
+	return fmt.Errorf("%w: the vault has no clients", core.ErrClientNotFound)
+}
 
-	err = c.service.CheckPassword(ctx, password)
-	if err != nil {
-		return err
-	}
+// promptCredentials asks for the client secret, private key or client certificate that the client's auth method
+// and grant need when it is not set yet
+func promptCredentials(client *core.Client) error {
+	var err error
 
-	// Load clients
-	printProgress("Loading clients from vault")
-	clients, err := c.service.ListClients(ctx)
-	if err != nil {
-		printError(err.Error())
-		return err
+	if client.RequiresSecret() && client.ClientSecret == "" {
+		client.ClientSecret, err = readPassword("Client Secret: ")
+		if err != nil {
+			return err
+		}
 	}
 
-	if len(clients) == 0 {
-		printWarning("No clients found in vault")
-		printMuted("Use 'authkeeper add' to add your first client")
-		return nil
+	if (client.AuthMethod() == core.AuthMethodPrivateKeyJWT || client.Grant() == core.GrantJWTBearer) && client.PrivateKey == "" {
+		keyFile, err := readLine("Private Key File (PEM): ")
+		if err != nil {
+			return err
+		}
+
+		if client.PrivateKey, err = readKeyFile(keyFile); err != nil {
+			return err
+		}
 	}
 
-	var selectedClient string
-	if clientName != "" {
-		// Validate that the client exists
-		found := false
-		for _, client := range clients {
-			if client == clientName {
-				selectedClient = clientName
-				found = true
-				break
-			}
+	if client.UsesMutualTLS() && client.TLSCertificate == "" {
+		certFile, err := readLine("Client Certificate File (PEM): ")
+		if err != nil {
+			return err
 		}
-		if !found {
-			printError(fmt.Sprintf("Client '%s' not found in vault", clientName))
-			return fmt.Errorf("client not found: %s", clientName)
+
+		if client.TLSCertificate, err = readKeyFile(certFile); err != nil {
+			return err
 		}
-	} else {
-		// Select client interactively
-		fmt.Println()
-		idx, err := selectFromList("Select OIDC client:", clients)
+	}
+
+	if client.UsesMutualTLS() && client.TLSKey == "" {
+		keyFile, err := readLine("Client Certificate Key File (PEM): ")
 		if err != nil {
 			return err
 		}
-		selectedClient = clients[idx]
+
+		if client.TLSKey, err = readKeyFile(keyFile); err != nil {
+			return err
+		}
 	}
 
-	// Fetch token
-	fmt.Println()
-	printProgress("Fetching access token")
+	return nil
+}
+
+// applyServiceAccount fills the client from a Google service account key file, values given on the command line win
+func applyServiceAccount(client *core.Client, in ClientInput) error {
+	data, err := os.ReadFile(in.ServiceAccountFile)
+	if err != nil {
+		return fmt.Errorf("failed to read service account key file: %w", err)
+	}
 
-	token, err := c.service.IssueToken(ctx, selectedClient)
+	account, err := core.ParseGoogleServiceAccount(data)
 	if err != nil {
-		printError(err.Error())
 		return err
 	}
 
-	// Display token
-	printSuccess("Token issued successfully!")
-	fmt.Println()
-	fmt.Printf("Client: %s\n", selectedClient)
-	fmt.Println()
-	fmt.Println("Access Token:")
-	fmt.Println(token.AccessToken)
-	fmt.Println()
-	fmt.Printf("Token Type: %s\n", token.TokenType)
-	fmt.Printf("Expires In: %d seconds\n", token.ExpiresIn)
-	fmt.Printf("Scope: %s\n", token.Scope)
-	fmt.Println()
-	printMuted("💡 Tip: Copy the access token to use in your API requests")
+	if client.ClientID == "" {
+		client.ClientID = account.ClientID
+	}
+	if client.TokenURL == "" {
+		client.TokenURL = account.TokenURL
+	}
+	if client.PrivateKey == "" {
+		client.PrivateKey = account.PrivateKey
+	}
+	if client.KeyID == "" {
+		client.KeyID = account.KeyID
+	}
+	if in.AuthMethod == "" {
+		client.TokenEndpointAuthMethod = account.TokenEndpointAuthMethod
+	}
+	if in.GrantType == "" {
+		client.GrantType = account.GrantType
+	}
 
 	return nil
 }
 
-// ListClients handles the list clients flow
-func (c *CLI) ListClients(ctx context.Context) error {
-	// Check if repository is initialized
-	if !c.service.IsRepositoryInitialized() {
-		printWarning("Vault not found")
-		printMuted("Use 'authkeeper add' to create vault and add your first client")
-		return nil
-	}
+// discoverIssuer resolves missing endpoints from the issuer's metadata and, unless chosen explicitly,
+// an auth method the issuer supports
+func (c *CLI) discoverIssuer(ctx context.Context, client *core.Client, pickAuthMethod bool) error {
+	printProgress("Discovering issuer metadata")
 
-	password, err := c.PromptMasterPassword(false)
+	metadata, err := c.service.DiscoverIssuer(ctx, client.Issuer, client.HTTP)
 	if err != nil {
-		return fmt.Errorf("failed to read master password: %w", err)
+		return err
 	}
 
-	err = c.service.CheckPassword(ctx, password)
-	if err != nil {
-		return err
+	if client.TokenURL == "" {
+		client.TokenURL = metadata.TokenEndpoint
 	}
 
-	// Load vault
-	printProgress("Loading vault")
-	clients, err := c.service.GetAllClients(ctx)
+	if client.AuthorizationURL == "" && client.Grant() == core.GrantAuthorizationCode {
+		client.AuthorizationURL = metadata.AuthorizationEndpoint
+	}
+
+	if client.DeviceAuthorizationURL == "" && client.Grant() == core.GrantDeviceCode {
+		client.DeviceAuthorizationURL = metadata.DeviceAuthorizationEndpoint
+	}
+
+	if pickAuthMethod {
+		client.TokenEndpointAuthMethod = metadata.DefaultAuthMethod()
+	} else if !metadata.SupportsAuthMethod(client.AuthMethod()) {
+		printWarning(fmt.Sprintf("Issuer does not advertise support for %s", client.AuthMethod()))
+	}
+
+	// The first token request then uses the endpoints just resolved instead of fetching the metadata again
+	client.EndpointsResolvedAt = c.now()
+
+	if len(metadata.GrantTypes) > 0 && !metadata.SupportsGrant(client.Grant().Identifier()) {
+		printWarning(fmt.Sprintf("Issuer does not advertise support for the %s grant", client.Grant()))
+	}
+
+	printSuccess(fmt.Sprintf("Token endpoint: %s", metadata.TokenEndpoint))
+	if len(metadata.GrantTypes) > 0 {
+		printMuted(fmt.Sprintf("Supported grants: %s", strings.Join(metadata.GrantTypes, ", ")))
+	}
+	if len(metadata.TokenEndpointAuthMethods) > 0 {
+		printMuted(fmt.Sprintf("Supported auth methods: %s", strings.Join(metadata.TokenEndpointAuthMethods, ", ")))
+	}
+	fmt.Fprintln(os.Stderr)
+
+	return nil
+}
+
+// printClientReview shows client details for confirmation, with secrets masked
+func printClientReview(client *core.Client) {
+	fmt.Fprintf(os.Stderr, "Name:          %s\n", client.Name)
+	fmt.Fprintf(os.Stderr, "Client ID:     %s\n", client.ClientID)
+	if client.RequiresSecret() {
+		fmt.Fprintf(os.Stderr, "Client Secret: %s\n", strings.Repeat("•", len(client.ClientSecret)))
+	}
+	fmt.Fprintf(os.Stderr, "Token URL:     %s\n", client.TokenURL)
+	if client.Issuer != "" {
+		fmt.Fprintf(os.Stderr, "Issuer:        %s\n", client.Issuer)
+	}
+	fmt.Fprintf(os.Stderr, "Scopes:        %s\n", strings.Join(client.Scopes, ", "))
+	fmt.Fprintf(os.Stderr, "Auth Method:   %s\n", client.AuthMethod())
+	if client.Grant().Legacy() {
+		fmt.Fprintf(os.Stderr, "Grant Type:    %s (legacy)\n", client.Grant())
+	} else {
+		fmt.Fprintf(os.Stderr, "Grant Type:    %s\n", client.Grant())
+	}
+	if client.Username != "" {
+		fmt.Fprintf(os.Stderr, "Username:      %s\n", client.Username)
+		fmt.Fprintf(os.Stderr, "Password:      %s\n", strings.Repeat("•", len(client.Password)))
+	}
+	if client.AuthorizationURL != "" {
+		fmt.Fprintf(os.Stderr, "Auth URL:      %s\n", client.AuthorizationURL)
+	}
+	if client.RedirectURL != "" {
+		fmt.Fprintf(os.Stderr, "Redirect URL:  %s\n", client.RedirectURL)
+	}
+	if client.DeviceAuthorizationURL != "" {
+		fmt.Fprintf(os.Stderr, "Device URL:    %s\n", client.DeviceAuthorizationURL)
+	}
+	if client.AssertionIssuer != "" {
+		fmt.Fprintf(os.Stderr, "Assertion Iss: %s\n", client.AssertionIssuer)
+	}
+	if client.AssertionSubject != "" {
+		fmt.Fprintf(os.Stderr, "Assertion Sub: %s\n", client.AssertionSubject)
+	}
+	if client.AssertionAudience != "" {
+		fmt.Fprintf(os.Stderr, "Assertion Aud: %s\n", client.AssertionAudience)
+	}
+	if client.Audience != "" {
+		fmt.Fprintf(os.Stderr, "Audience:      %s\n", client.Audience)
+	}
+	if len(client.Resource) > 0 {
+		fmt.Fprintf(os.Stderr, "Resource:      %s\n", strings.Join(client.Resource, ", "))
+	}
+	if len(client.ExtraParams) > 0 {
+		fmt.Fprintf(os.Stderr, "Params:        %s\n", strings.Join(paramList(client.ExtraParams), ", "))
+	}
+	if len(client.ExtraHeaders) > 0 {
+		// Header values often are API keys
+		fmt.Fprintf(os.Stderr, "Headers:       %s (values hidden)\n", strings.Join(slices.Sorted(maps.Keys(client.ExtraHeaders)), ", "))
+	}
+	if client.PrivateKey != "" {
+		fmt.Fprintf(os.Stderr, "Private Key:   %s\n", keySummary(client.PrivateKey))
+	}
+	if client.KeyID != "" {
+		fmt.Fprintf(os.Stderr, "Key ID:        %s\n", client.KeyID)
+	}
+	if client.SigningAlgorithm != "" {
+		fmt.Fprintf(os.Stderr, "Algorithm:     %s\n", client.SigningAlgorithm)
+	}
+	if client.TLSCertificate != "" {
+		fmt.Fprintf(os.Stderr, "Certificate:   %s\n", keySummary(client.TLSCertificate))
+	}
+	if client.HTTP.Timeout > 0 {
+		fmt.Fprintf(os.Stderr, "Timeout:       %s\n", client.HTTP.Timeout)
+	}
+	if client.HTTP.ProxyURL != "" {
+		fmt.Fprintf(os.Stderr, "Proxy:         %s\n", client.HTTP.ProxyURL)
+	}
+	if client.HTTP.CACertificates != "" {
+		fmt.Fprintf(os.Stderr, "CA Certs:      %d (PEM)\n", strings.Count(client.HTTP.CACertificates, "-----BEGIN CERTIFICATE-----"))
+	}
+	if client.HTTP.TLSMinVersion != "" {
+		fmt.Fprintf(os.Stderr, "TLS Min:       %s\n", client.HTTP.TLSMinVersion)
+	}
+	if client.HTTP.Retries != nil {
+		fmt.Fprintf(os.Stderr, "Retries:       %d\n", *client.HTTP.Retries)
+	}
+	if client.HTTP.PinnedPublicKey != "" {
+		fmt.Fprintf(os.Stderr, "Pinned Key:    %s\n", client.HTTP.PinnedPublicKey)
+	}
+	if client.HTTP.InsecureSkipVerify {
+		fmt.Fprintf(os.Stderr, "TLS Verify:    %sdisabled (insecure)%s\n", colorRed, colorReset)
+	}
+}
+
+// paramList formats extra parameters as sorted key=value pairs
+func paramList(params map[string]string) []string {
+	list := make([]string, 0, len(params))
+	for _, key := range slices.Sorted(maps.Keys(params)) {
+		list = append(list, key+"="+params[key])
+	}
+
+	return list
+}
+
+// readKeyFile reads a PEM encoded key or certificate file
+func readKeyFile(path string) (string, error) {
+	data, err := os.ReadFile(path)
 	if err != nil {
-		printError(err.Error())
-		return err
+		return "", fmt.Errorf("failed to read key file: %w", err)
 	}
 
-	if len(clients) == 0 {
-		printWarning("No clients found")
-		printMuted("Use 'authkeeper add' to add your first client")
-		return nil
+	if !strings.Contains(string(data), "-----BEGIN ") {
+		return "", fmt.Errorf("file %s does not contain PEM encoded data", path)
 	}
 
-	fmt.Println()
-	printInfo(fmt.Sprintf("Found %d client(s)", len(clients)))
-	fmt.Println()
+	return string(data), nil
+}
+
+// keySummary describes a PEM key without revealing it
+func keySummary(pemData string) string {
+	_, rest, found := strings.Cut(pemData, "-----BEGIN ")
+	if !found {
+		return "(set)"
+	}
 
-	for i, client := range clients {
-		fmt.Printf("%s%d. %s%s\n", colorCyan, i+1, client.Name, colorReset)
-		fmt.Printf("   Client ID:  %s\n", client.ClientID)
-		fmt.Printf("   Token URL:  %s\n", client.TokenURL)
-		fmt.Printf("   Scopes:     %s\n", strings.Join(client.Scopes, ", "))
-		fmt.Printf("   Created:    %s\n", client.CreatedAt.Format("2006-01-02 15:04:05"))
-		fmt.Println()
+	kind, _, _ := strings.Cut(rest, "-----")
+
+	return kind + " (PEM)"
+}
+
+// warnInsecure warns before the client connects to a server whose certificate is not verified
+func (c *CLI) warnInsecure(ctx context.Context, clientName string) error {
+	client, err := c.service.GetClient(ctx, clientName)
+	if err != nil {
+		return err
+	}
+
+	if client.HTTP.InsecureSkipVerify {
+		printInsecureWarning(clientName)
 	}
 
 	return nil
 }
 
-// DeleteClient handles the delete client flow
-func (c *CLI) DeleteClient(ctx context.Context, clientName string, force bool) error {
+// printInsecureWarning tells the user that anyone on the network path can read the client's secrets and tokens
+func printInsecureWarning(clientName string) {
+	printWarning(fmt.Sprintf("TLS certificate verification is disabled for %s: anyone on the network path can read its secrets and tokens", clientName))
+	printMuted("Use it only for local development servers, or pin the server's public key with --pinned-pubkey instead")
+}
+
+// printScopeMismatch warns when the server granted other scopes than requested, tests relying on
+// a down-scoped token would otherwise run with more or less privileges than they assume
+func printScopeMismatch(token *core.Token) {
+	missing, extra := token.ScopeMismatch()
+	if len(missing) > 0 {
+		printWarning(fmt.Sprintf("Requested scopes not granted: %s", strings.Join(missing, ", ")))
+	}
+	if len(extra) > 0 {
+		printWarning(fmt.Sprintf("Scopes granted without being requested: %s", strings.Join(extra, ", ")))
+	}
+}
+
+// IssueToken handles the token issuance flow
+func (c *CLI) IssueToken(ctx context.Context, clientName string, opts core.TokenOptions) error {
 	// Check if repository is initialized
 	if !c.service.IsRepositoryInitialized() {
-		printWarning("Vault not found")
-		printMuted("Use 'authkeeper add' to create vault and add your first client")
-		return nil
+		return core.ErrVaultNotFound
 	}
 
 	password, err := c.PromptMasterPassword(false)
@@ -262,13 +595,11 @@ func (c *CLI) DeleteClient(ctx context.Context, clientName string, force bool) e
 	printProgress("Loading clients from vault")
 	clients, err := c.service.ListClients(ctx)
 	if err != nil {
-		printError(err.Error())
 		return err
 	}
 
 	if len(clients) == 0 {
-		printWarning("No clients found in vault")
-		return nil
+		return noClientsError(clientName)
 	}
 
 	var selectedClient string
@@ -283,84 +614,999 @@ func (c *CLI) DeleteClient(ctx context.Context, clientName string, force bool) e
 			}
 		}
 		if !found {
-			printError(fmt.Sprintf("Client '%s' not found in vault", clientName))
-			return fmt.Errorf("client not found: %s", clientName)
+			return fmt.Errorf("%w: %s", core.ErrClientNotFound, clientName)
 		}
 	} else {
 		// Select client interactively
-		fmt.Println()
-		idx, err := selectFromList("Select client to delete:", clients)
+		fmt.Fprintln(os.Stderr)
+		idx, err := selectFromList("Select OIDC client:", clients)
 		if err != nil {
 			return err
 		}
 		selectedClient = clients[idx]
 	}
 
-	// Confirm deletion (unless force flag is set)
-	if !force {
-		fmt.Println()
-		printWarning(fmt.Sprintf("Are you sure you want to delete '%s'?", selectedClient))
-		printMuted("This action cannot be undone.")
-		fmt.Println()
+	if err := c.warnInsecure(ctx, selectedClient); err != nil {
+		return err
+	}
 
-		if !confirm("Delete this client?") {
-			printInfo("Cancelled")
-			return nil
-		}
+	// Fetch token
+	fmt.Fprintln(os.Stderr)
+	printProgress("Fetching access token")
+
+	if opts.Interactor == nil {
+		opts.Interactor = c.interactor
 	}
 
-	// Delete
-	printProgress("Deleting client")
-	err = c.service.DeleteClient(ctx, selectedClient)
+	token, err := c.service.IssueToken(ctx, selectedClient, opts)
 	if err != nil {
-		printError(err.Error())
 		return err
 	}
 
-	printSuccess("Client deleted successfully!")
-	return nil
-}
+	// Display token
+	printSuccess("Token issued successfully!")
+	printScopeMismatch(token)
 
-// PromptMasterPassword prompts for master password with confirmation for new vault
-func (c *CLI) PromptMasterPassword(isNewVault bool) (string, error) {
-	if isNewVault {
-		printTitle("🔐 Create New Vault")
-		fmt.Println()
-		printInfo("You're creating a new vault. Please choose a strong master password.")
-		printWarning("This password encrypts all your credentials - don't forget it!")
-		fmt.Println()
+	if c.isMachineReadable() {
+		return c.writeToken(selectedClient, token)
+	}
+
+	fmt.Fprintln(os.Stderr)
+	if err := c.writeToken(selectedClient, token); err != nil {
+		return err
+	}
+	fmt.Fprintln(os.Stderr)
+	printMuted("💡 Tip: Copy the access token to use in your API requests")
+
+	return nil
+}
+
+// ExchangeInput holds the parameters of a token exchange. The subject and actor tokens are given literally,
+// read from a file ("-" for stdin) or issued for another stored client.
+type ExchangeInput struct {
+	SubjectToken     string
+	SubjectTokenFile string
+	SubjectClient    string
+	SubjectTokenType string
+	ActorToken       string
+	ActorTokenFile   string
+	ActorClient      string
+	ActorTokenType   string
+	Audience         []string
+	Resource         []string
+	// RequestedTokenType and the token types accept short names such as jwt or full URIs
+	RequestedTokenType string
+	// NoCache applies to the tokens issued for SubjectClient and ActorClient
+	NoCache bool
+}
+
+// ExchangeToken handles the token exchange flow (RFC 8693)
+func (c *CLI) ExchangeToken(ctx context.Context, clientName string, in ExchangeInput) error {
+	exchange := core.TokenExchange{Audience: in.Audience, Resource: in.Resource}
+
+	var err error
+	if exchange.SubjectTokenType, err = core.ParseTokenType(in.SubjectTokenType); err != nil {
+		return err
+	}
+	if exchange.ActorTokenType, err = core.ParseTokenType(in.ActorTokenType); err != nil {
+		return err
+	}
+	if exchange.RequestedTokenType, err = core.ParseTokenType(in.RequestedTokenType); err != nil {
+		return err
+	}
+
+	// Tokens from files are read before unlocking, stdin may be needed for the master password afterwards
+	if exchange.SubjectToken, err = readToken(in.SubjectToken, in.SubjectTokenFile); err != nil {
+		return err
+	}
+	if exchange.ActorToken, err = readToken(in.ActorToken, in.ActorTokenFile); err != nil {
+		return err
+	}
+
+	// Check if repository is initialized
+	if !c.service.IsRepositoryInitialized() {
+		return core.ErrVaultNotFound
+	}
+
+	password, err := c.PromptMasterPassword(false)
+	if err != nil {
+		return fmt.Errorf("failed to read master password: %w", err)
+	}
+
+	err = c.service.CheckPassword(ctx, password)
+	if err != nil {
+		return err
+	}
+
+	// Load clients
+	printProgress("Loading clients from vault")
+	clients, err := c.service.ListClients(ctx)
+	if err != nil {
+		return err
+	}
+
+	if len(clients) == 0 {
+		return noClientsError(clientName)
+	}
+
+	var selectedClient string
+	if clientName != "" {
+		// Validate that the client exists
+		found := false
+		for _, client := range clients {
+			if client == clientName {
+				selectedClient = clientName
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("%w: %s", core.ErrClientNotFound, clientName)
+		}
+	} else {
+		// Select client interactively
+		fmt.Fprintln(os.Stderr)
+		idx, err := selectFromList("Select client to exchange the token with:", clients)
+		if err != nil {
+			return err
+		}
+		selectedClient = clients[idx]
+	}
+
+	if in.SubjectClient != "" {
+		if exchange.SubjectToken, err = c.clientToken(ctx, in.SubjectClient, in.NoCache); err != nil {
+			return err
+		}
+	}
+
+	if in.ActorClient != "" {
+		if exchange.ActorToken, err = c.clientToken(ctx, in.ActorClient, in.NoCache); err != nil {
+			return err
+		}
+	}
+
+	if err := c.warnInsecure(ctx, selectedClient); err != nil {
+		return err
+	}
+
+	fmt.Fprintln(os.Stderr)
+	printProgress("Exchanging token")
+
+	token, err := c.service.ExchangeToken(ctx, selectedClient, exchange)
+	if err != nil {
+		return err
+	}
+
+	printSuccess("Token exchanged successfully!")
+
+	if c.isMachineReadable() {
+		return c.writeToken(selectedClient, token)
+	}
+
+	fmt.Fprintln(os.Stderr)
+
+	return c.writeToken(selectedClient, token)
+}
+
+// clientToken issues an access token for another stored client, used as subject or actor token of an exchange
+func (c *CLI) clientToken(ctx context.Context, clientName string, noCache bool) (string, error) {
+	printProgress(fmt.Sprintf("Fetching access token for %s", clientName))
+
+	token, err := c.service.IssueToken(ctx, clientName, core.TokenOptions{NoCache: noCache, Interactor: c.interactor})
+	if err != nil {
+		return "", err
+	}
+
+	return token.AccessToken, nil
+}
+
+// readToken returns the literal token or reads it from a file, "-" reads it from stdin.
+// Surrounding whitespace, such as the trailing newline of a file, is not part of the token.
+func readToken(literal, path string) (string, error) {
+	if path == "" {
+		return literal, nil
+	}
+
+	var data []byte
+	var err error
+
+	if path == "-" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(path)
+	}
+
+	if err != nil {
+		return "", fmt.Errorf("failed to read token: %w", err)
+	}
+
+	token := strings.TrimSpace(string(data))
+	if token == "" {
+		return "", fmt.Errorf("token file %s is empty", path)
+	}
+
+	return token, nil
+}
+
+// DecodeJWT prints the header and claims of a JWT given literally or read from a file ("-" for stdin).
+// The token is decoded offline and its signature is not verified.
+func (c *CLI) DecodeJWT(literal, path string) error {
+	token, err := readToken(literal, path)
+	if err != nil {
+		return err
+	}
+
+	jwt, err := core.ParseJWT(token)
+	if err != nil {
+		return err
+	}
+
+	return c.writeJWT(jwt)
+}
+
+// ListClients handles the list clients flow
+func (c *CLI) ListClients(ctx context.Context) error {
+	// Credential formats have nothing to render for a list, reject them before the vault is unlocked
+	if c.format == OutputEnv || c.format == OutputHeader {
+		return UsageError(fmt.Errorf("output format %q is not supported for listing clients", c.format))
+	}
+
+	// Check if repository is initialized
+	if !c.service.IsRepositoryInitialized() {
+		return core.ErrVaultNotFound
+	}
+
+	password, err := c.PromptMasterPassword(false)
+	if err != nil {
+		return fmt.Errorf("failed to read master password: %w", err)
+	}
+
+	err = c.service.CheckPassword(ctx, password)
+	if err != nil {
+		return err
+	}
+
+	// Load vault
+	printProgress("Loading vault")
+	clients, err := c.service.GetAllClients(ctx)
+	if err != nil {
+		return err
+	}
+
+	if len(clients) == 0 {
+		printWarning("No clients found")
+		printMuted("Use 'authkeeper add' to add your first client")
+
+		if c.isMachineReadable() {
+			return c.writeClients(clients)
+		}
+
+		return nil
+	}
+
+	fmt.Fprintln(os.Stderr)
+	printInfo(fmt.Sprintf("Found %d client(s)", len(clients)))
+	fmt.Fprintln(os.Stderr)
+
+	return c.writeClients(clients)
+}
+
+// DeleteClient handles the delete client flow
+func (c *CLI) DeleteClient(ctx context.Context, clientName string, force bool) error {
+	// Check if repository is initialized
+	if !c.service.IsRepositoryInitialized() {
+		return core.ErrVaultNotFound
+	}
+
+	password, err := c.PromptMasterPassword(false)
+	if err != nil {
+		return fmt.Errorf("failed to read master password: %w", err)
+	}
+
+	err = c.service.CheckPassword(ctx, password)
+	if err != nil {
+		return err
+	}
+
+	// Load clients
+	printProgress("Loading clients from vault")
+	clients, err := c.service.ListClients(ctx)
+	if err != nil {
+		return err
+	}
+
+	if len(clients) == 0 {
+		return noClientsError(clientName)
+	}
+
+	var selectedClient string
+	if clientName != "" {
+		// Validate that the client exists
+		found := false
+		for _, client := range clients {
+			if client == clientName {
+				selectedClient = clientName
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("%w: %s", core.ErrClientNotFound, clientName)
+		}
+	} else {
+		// Select client interactively
+		fmt.Fprintln(os.Stderr)
+		idx, err := selectFromList("Select client to delete:", clients)
+		if err != nil {
+			return err
+		}
+		selectedClient = clients[idx]
+	}
+
+	// Confirm deletion (unless force flag is set)
+	if !force {
+		fmt.Fprintln(os.Stderr)
+		printWarning(fmt.Sprintf("Are you sure you want to delete '%s'?", selectedClient))
+		printMuted("This action cannot be undone.")
+		fmt.Fprintln(os.Stderr)
+
+		ok, err := confirm("Delete this client?", "--force")
+		if err != nil {
+			return err
+		}
+
+		if !ok {
+			printInfo("Cancelled")
+			return nil
+		}
+	}
+
+	// Delete
+	printProgress("Deleting client")
+	err = c.service.DeleteClient(ctx, selectedClient)
+	if err != nil {
+		return err
+	}
+
+	printSuccess("Client deleted successfully!")
+	return nil
+}
+
+// ClientChanges holds client fields supplied on the command line for a scripted edit.
+// Nil fields are left unchanged.
+type ClientChanges struct {
+	ClientID     *string
+	ClientSecret *string
+	TokenURL     *string
+	Issuer       *string
+	Scopes       *string
+	AuthMethod   *string
+	// PrivateKeyFile is a path to the PEM encoded private key to store
+	PrivateKeyFile   *string
+	KeyID            *string
+	SigningAlgorithm *string
+	// TLSCertFile and TLSKeyFile are paths to the PEM encoded client certificate and key to store
+	TLSCertFile      *string
+	TLSKeyFile       *string
+	GrantType        *string
+	AuthorizationURL *string
+	RedirectURL      *string
+	// DeviceAuthorizationURL is the device code endpoint for the device_code grant
+	DeviceAuthorizationURL *string
+	// AssertionIssuer, AssertionSubject and AssertionAudience are the claims of jwt_bearer assertions
+	AssertionIssuer   *string
+	AssertionSubject  *string
+	AssertionAudience *string
+	// Username and Password are the stored credentials of the password grant, empty values remove them
+	Username *string
+	Password *string
+	// Audience, Resource, Params and Headers replace the stored values, empty values remove them
+	Audience *string
+	Resource *[]string
+	Params   *[]string
+	Headers  *[]string
+	// Timeout, ProxyURL, CAFile, TLSMinVersion and Retries change the client's HTTP settings,
+	// zero, empty and negative values remove them so the global settings apply
+	Timeout       *time.Duration
+	ProxyURL      *string
+	CAFile        *string
+	TLSMinVersion *string
+	Retries       *int
+	// PinnedPublicKey changes the pinned server public key, empty removes it
+	PinnedPublicKey    *string
+	InsecureSkipVerify *bool
+}
+
+// IsEmpty reports whether no field changes were supplied
+func (ch ClientChanges) IsEmpty() bool {
+	return ch.ClientID == nil && ch.ClientSecret == nil && ch.TokenURL == nil && ch.Issuer == nil && ch.Scopes == nil &&
+		ch.AuthMethod == nil && ch.PrivateKeyFile == nil && ch.KeyID == nil && ch.SigningAlgorithm == nil &&
+		ch.TLSCertFile == nil && ch.TLSKeyFile == nil && ch.GrantType == nil && ch.AuthorizationURL == nil &&
+		ch.RedirectURL == nil && ch.DeviceAuthorizationURL == nil && ch.AssertionIssuer == nil && ch.AssertionSubject == nil &&
+		ch.AssertionAudience == nil && ch.Username == nil && ch.Password == nil && ch.Audience == nil && ch.Resource == nil &&
+		ch.Params == nil && ch.Headers == nil && ch.Timeout == nil && ch.ProxyURL == nil &&
+		ch.CAFile == nil && ch.TLSMinVersion == nil && ch.Retries == nil && ch.PinnedPublicKey == nil && ch.InsecureSkipVerify == nil
+}
+
+// apply copies the supplied field changes onto the client
+func (ch ClientChanges) apply(client *core.Client) error {
+	if ch.ClientID != nil {
+		client.ClientID = *ch.ClientID
+	}
+	if ch.ClientSecret != nil {
+		client.ClientSecret = *ch.ClientSecret
+	}
+	if ch.TokenURL != nil {
+		client.TokenURL = *ch.TokenURL
+	}
+	if ch.Issuer != nil {
+		// Endpoints resolved from another issuer are resolved again by the next token request
+		if client.Issuer != *ch.Issuer {
+			client.EndpointsResolvedAt = time.Time{}
+		}
+
+		client.Issuer = *ch.Issuer
+	}
+	if ch.Scopes != nil {
+		client.Scopes = strings.Fields(*ch.Scopes)
+	}
+	if ch.AuthMethod != nil {
+		method, err := core.ParseAuthMethod(*ch.AuthMethod)
+		if err != nil {
+			return err
+		}
+
+		client.TokenEndpointAuthMethod = method
+	}
+	if ch.PrivateKeyFile != nil {
+		key, err := readKeyFile(*ch.PrivateKeyFile)
+		if err != nil {
+			return err
+		}
+
+		client.PrivateKey = key
+	}
+	if ch.KeyID != nil {
+		client.KeyID = *ch.KeyID
+	}
+	if ch.SigningAlgorithm != nil {
+		client.SigningAlgorithm = *ch.SigningAlgorithm
+	}
+	if ch.TLSCertFile != nil {
+		cert, err := readKeyFile(*ch.TLSCertFile)
+		if err != nil {
+			return err
+		}
+
+		client.TLSCertificate = cert
+	}
+	if ch.TLSKeyFile != nil {
+		key, err := readKeyFile(*ch.TLSKeyFile)
+		if err != nil {
+			return err
+		}
+
+		client.TLSKey = key
+	}
+	if ch.GrantType != nil {
+		grant, err := core.ParseGrantType(*ch.GrantType)
+		if err != nil {
+			return err
+		}
+
+		client.GrantType = grant
+	}
+	if ch.AuthorizationURL != nil {
+		client.AuthorizationURL = *ch.AuthorizationURL
+	}
+	if ch.RedirectURL != nil {
+		client.RedirectURL = *ch.RedirectURL
+	}
+	if ch.DeviceAuthorizationURL != nil {
+		client.DeviceAuthorizationURL = *ch.DeviceAuthorizationURL
+	}
+	if ch.AssertionIssuer != nil {
+		client.AssertionIssuer = *ch.AssertionIssuer
+	}
+	if ch.AssertionSubject != nil {
+		client.AssertionSubject = *ch.AssertionSubject
+	}
+	if ch.AssertionAudience != nil {
+		client.AssertionAudience = *ch.AssertionAudience
+	}
+	if ch.Username != nil {
+		client.Username = *ch.Username
+	}
+	if ch.Password != nil {
+		client.Password = *ch.Password
+	}
+	if ch.Audience != nil {
+		client.Audience = *ch.Audience
+	}
+	if ch.Resource != nil {
+		client.Resource = nonEmpty(*ch.Resource)
+	}
+	if ch.Params != nil {
+		params, err := core.ParseExtraParams(nonEmpty(*ch.Params))
+		if err != nil {
+			return err
+		}
+
+		client.ExtraParams = params
+	}
+	if ch.Headers != nil {
+		headers, err := core.ParseExtraHeaders(nonEmpty(*ch.Headers))
+		if err != nil {
+			return err
+		}
+
+		client.ExtraHeaders = headers
+	}
+	if ch.Timeout != nil {
+		client.HTTP.Timeout = max(*ch.Timeout, 0)
+	}
+	if ch.ProxyURL != nil {
+		client.HTTP.ProxyURL = *ch.ProxyURL
+	}
+	if ch.CAFile != nil {
+		client.HTTP.CACertificates = ""
+
+		if *ch.CAFile != "" {
+			certs, err := readKeyFile(*ch.CAFile)
+			if err != nil {
+				return err
+			}
+
+			client.HTTP.CACertificates = certs
+		}
+	}
+	if ch.TLSMinVersion != nil {
+		client.HTTP.TLSMinVersion = *ch.TLSMinVersion
+	}
+	if ch.Retries != nil {
+		client.HTTP.Retries = nil
+
+		if *ch.Retries >= 0 {
+			retries := *ch.Retries
+			client.HTTP.Retries = &retries
+		}
+	}
+	if ch.PinnedPublicKey != nil {
+		client.HTTP.PinnedPublicKey = *ch.PinnedPublicKey
+	}
+	if ch.InsecureSkipVerify != nil {
+		client.HTTP.InsecureSkipVerify = *ch.InsecureSkipVerify
+	}
+
+	return nil
+}
+
+// nonEmpty drops empty values, so a flag given as "" clears a list
+func nonEmpty(values []string) []string {
+	var result []string
+	for _, value := range values {
+		if value != "" {
+			result = append(result, value)
+		}
+	}
+
+	return result
+}
+
+// EditClient handles the edit client flow.
+// When field changes are supplied they are applied without prompting, otherwise
+// the user is prompted for every field with the current value pre-filled and asked to confirm unless yes is set.
+func (c *CLI) EditClient(ctx context.Context, clientName string, changes ClientChanges, yes bool) error {
+	// Check if repository is initialized
+	if !c.service.IsRepositoryInitialized() {
+		return core.ErrVaultNotFound
+	}
+
+	password, err := c.PromptMasterPassword(false)
+	if err != nil {
+		return fmt.Errorf("failed to read master password: %w", err)
+	}
+
+	err = c.service.CheckPassword(ctx, password)
+	if err != nil {
+		return err
+	}
+
+	// Load clients
+	printProgress("Loading clients from vault")
+	clients, err := c.service.ListClients(ctx)
+	if err != nil {
+		return err
+	}
+
+	if len(clients) == 0 {
+		return noClientsError(clientName)
+	}
+
+	var selectedClient string
+	if clientName != "" {
+		// Validate that the client exists
+		found := false
+		for _, client := range clients {
+			if client == clientName {
+				selectedClient = clientName
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("%w: %s", core.ErrClientNotFound, clientName)
+		}
+	} else {
+		// Select client interactively
+		fmt.Fprintln(os.Stderr)
+		idx, err := selectFromList("Select client to edit:", clients)
+		if err != nil {
+			return err
+		}
+		selectedClient = clients[idx]
+	}
+
+	client, err := c.service.GetClient(ctx, selectedClient)
+	if err != nil {
+		return err
+	}
+
+	if changes.IsEmpty() {
+		fmt.Fprintln(os.Stderr)
+		printInfo("Edit client credentials")
+		printMuted("Press Enter to keep the current value")
+		fmt.Fprintln(os.Stderr)
+
+		client.ClientID, err = readLineDefault("Client ID", client.ClientID)
+		if err != nil {
+			return err
+		}
+
+		clientSecret, err := readPassword("Client Secret (leave empty to keep current): ")
+		if err != nil {
+			return err
+		}
+		if clientSecret != "" {
+			client.ClientSecret = clientSecret
+		}
+
+		client.TokenURL, err = readLineDefault("Token URL", client.TokenURL)
+		if err != nil {
+			return err
+		}
+
+		issuer, err := readOptionalDefault("Issuer", client.Issuer)
+		if err != nil {
+			return err
+		}
+		if issuer != client.Issuer {
+			// Endpoints resolved from another issuer are resolved again by the next token request
+			client.Issuer, client.EndpointsResolvedAt = issuer, time.Time{}
+		}
+
+		scopesStr, err := readLineDefault("Scopes (space-separated)", strings.Join(client.Scopes, " "))
+		if err != nil {
+			return err
+		}
+		client.Scopes = strings.Fields(scopesStr)
+
+		authMethod, err := readLineDefault("Auth Method (client_secret_post, client_secret_basic, client_secret_jwt, private_key_jwt, tls_client_auth, self_signed_tls_client_auth, none)", string(client.AuthMethod()))
+		if err != nil {
+			return err
+		}
+		client.TokenEndpointAuthMethod, err = core.ParseAuthMethod(authMethod)
+		if err != nil {
+			return err
+		}
+
+		// A new auth method may need a secret, key or certificate the client doesn't have yet
+		if err := promptCredentials(client); err != nil {
+			return err
+		}
+
+		grant, err := readLineDefault("Grant Type (client_credentials, authorization_code, device_code, jwt_bearer, password)", string(client.Grant()))
+		if err != nil {
+			return err
+		}
+		client.GrantType, err = core.ParseGrantType(grant)
+		if err != nil {
+			return err
+		}
+
+		if client.Grant() == core.GrantAuthorizationCode {
+			client.AuthorizationURL, err = readLineDefault("Authorization URL", client.AuthorizationURL)
+			if err != nil {
+				return err
+			}
+
+			client.RedirectURL, err = readOptionalDefault("Redirect URL", client.RedirectURL)
+			if err != nil {
+				return err
+			}
+		}
+
+		if client.Grant() == core.GrantDeviceCode {
+			client.DeviceAuthorizationURL, err = readLineDefault("Device Authorization URL", client.DeviceAuthorizationURL)
+			if err != nil {
+				return err
+			}
+		}
+
+		if client.Grant() == core.GrantJWTBearer {
+			// jwt_bearer assertions are signed with the client's private key
+			if err := promptCredentials(client); err != nil {
+				return err
+			}
 
-		for {
-			password, err := readPassword("Enter master password: ")
+			client.AssertionSubject, err = readLineDefault("Assertion Subject (optional)", client.AssertionSubject)
 			if err != nil {
-				return "", err
+				return err
 			}
+		}
 
-			if len(password) < 8 {
-				printError("Password must be at least 8 characters long")
-				fmt.Println()
-				continue
+		if client.Grant() == core.GrantPassword {
+			if err := editUserCredentials(client); err != nil {
+				return err
 			}
+		}
+
+		client.Audience, err = readOptionalDefault("Audience", client.Audience)
+		if err != nil {
+			return err
+		}
+
+		// Checked before the review, so a client that cannot be saved is not confirmed first
+		if err := core.ValidateClient(*client); err != nil {
+			return err
+		}
 
-			confirmPassword, err := readPassword("Confirm master password: ")
+		// Confirm
+		fmt.Fprintln(os.Stderr)
+		printInfo("Review client details:")
+		fmt.Fprintln(os.Stderr)
+		printClientReview(client)
+		fmt.Fprintln(os.Stderr)
+
+		if !yes {
+			ok, err := confirm("Save changes?", "--yes")
 			if err != nil {
-				return "", err
+				return err
 			}
 
-			if password != confirmPassword {
-				printError("Passwords do not match. Please try again.")
-				fmt.Println()
-				continue
+			if !ok {
+				printWarning("Cancelled")
+				return nil
 			}
+		}
+	} else {
+		if err := changes.apply(client); err != nil {
+			return err
+		}
+
+		if changes.InsecureSkipVerify != nil && *changes.InsecureSkipVerify {
+			printInsecureWarning(client.Name)
+		}
+	}
+
+	// Save
+	printProgress("Saving to encrypted vault")
+
+	err = c.service.UpdateClient(ctx, *client)
+	if err != nil {
+		return err
+	}
+
+	printSuccess("Client updated successfully!")
+	return nil
+}
+
+// editUserCredentials prompts for the stored username and password of the password grant.
+// Clearing the username removes both, so they are asked for on every token request.
+func editUserCredentials(client *core.Client) error {
+	username, err := readLineDefault("Username (optional, \"-\" to remove)", client.Username)
+	if err != nil {
+		return err
+	}
+
+	if username == "" || username == "-" {
+		client.Username, client.Password = "", ""
+		return nil
+	}
+
+	if username != client.Username || client.Password == "" {
+		client.Username = username
+		client.Password, err = readPassword("Password: ")
+		return err
+	}
+
+	password, err := readPassword("Password (leave empty to keep current): ")
+	if err != nil {
+		return err
+	}
+	if password != "" {
+		client.Password = password
+	}
+
+	return nil
+}
+
+// ChangePassword handles the master password change flow
+func (c *CLI) ChangePassword(ctx context.Context) error {
+	// Check if repository is initialized
+	if !c.service.IsRepositoryInitialized() {
+		return core.ErrVaultNotFound
+	}
+
+	// The new password is never read from a non-interactive source, so fail before reading any secret
+	if !stdinIsTerminal() {
+		return UsageError(fmt.Errorf("changing the master password requires an interactive terminal"))
+	}
+
+	printTitle("🔑 Change Master Password")
+	fmt.Fprintln(os.Stderr)
+
+	oldPassword, err := c.PromptMasterPassword(false)
+	if err != nil {
+		return fmt.Errorf("failed to read master password: %w", err)
+	}
+
+	err = c.service.CheckPassword(ctx, oldPassword)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintln(os.Stderr)
+	printInfo("Choose a new master password.")
+	fmt.Fprintln(os.Stderr)
+
+	newPassword, err := readNewPassword("New master password: ", "Confirm new master password: ")
+	if err != nil {
+		return err
+	}
+
+	printProgress("Re-encrypting vault")
+	err = c.service.ChangePassword(ctx, oldPassword, newPassword)
+	if err != nil {
+		return err
+	}
+
+	printSuccess("Master password changed successfully!")
+	return nil
+}
+
+// ListBackups handles the backup list flow
+func (c *CLI) ListBackups(ctx context.Context) error {
+	// Check if repository is initialized
+	if !c.service.IsRepositoryInitialized() {
+		return core.ErrVaultNotFound
+	}
+
+	password, err := c.PromptMasterPassword(false)
+	if err != nil {
+		return fmt.Errorf("failed to read master password: %w", err)
+	}
+
+	err = c.service.CheckPassword(ctx, password)
+	if err != nil {
+		return err
+	}
+
+	printProgress("Loading backups")
+	backups, err := c.service.ListBackups(ctx)
+	if err != nil {
+		return err
+	}
+
+	if len(backups) == 0 {
+		printWarning("No backups found")
+		printMuted("Backups are created automatically every time the vault is modified")
+		return nil
+	}
+
+	fmt.Fprintln(os.Stderr)
+	printInfo(fmt.Sprintf("Found %d backup(s)", len(backups)))
+	fmt.Fprintln(os.Stderr)
+
+	for _, backup := range backups {
+		fmt.Fprintf(os.Stderr, "%s%d. saved %s%s\n", colorCyan, backup.Index, backup.SavedAt.Format("2006-01-02 15:04:05"), colorReset)
+		if backup.Readable {
+			fmt.Fprintf(os.Stderr, "   Clients:    %d\n", backup.Clients)
+		} else {
+			fmt.Fprintf(os.Stderr, "   Clients:    %sunknown (encrypted with a different master password)%s\n", colorGray, colorReset)
+		}
+		fmt.Fprintln(os.Stderr)
+	}
+
+	printMuted("💡 Tip: Use 'authkeeper backup restore <n>' to restore a backup")
+
+	return nil
+}
+
+// RestoreBackup handles the backup restore flow
+func (c *CLI) RestoreBackup(ctx context.Context, index int, force bool) error {
+	// Check if repository is initialized
+	if !c.service.IsRepositoryInitialized() {
+		return core.ErrVaultNotFound
+	}
+
+	password, err := c.PromptMasterPassword(false)
+	if err != nil {
+		return fmt.Errorf("failed to read master password: %w", err)
+	}
+
+	err = c.service.CheckPassword(ctx, password)
+	if err != nil {
+		return err
+	}
+
+	// Confirm restore (unless force flag is set)
+	if !force {
+		fmt.Fprintln(os.Stderr)
+		printWarning(fmt.Sprintf("Are you sure you want to replace the vault with backup %d?", index))
+		printMuted("The current vault will be kept as backup 1.")
+		fmt.Fprintln(os.Stderr)
+
+		ok, err := confirm("Restore this backup?", "--force")
+		if err != nil {
+			return err
+		}
+
+		if !ok {
+			printInfo("Cancelled")
+			return nil
+		}
+	}
+
+	printProgress("Restoring backup")
+	err = c.service.RestoreBackup(ctx, index)
+	if err != nil {
+		return err
+	}
+
+	printSuccess("Backup restored successfully!")
+	return nil
+}
+
+// PromptMasterPassword prompts for master password with confirmation for new vault.
+// A configured non-interactive password source takes precedence over the terminal prompt.
+func (c *CLI) PromptMasterPassword(isNewVault bool) (string, error) {
+	password, ok, err := c.passwords.read()
+	if err != nil {
+		return "", err
+	}
+
+	if ok {
+		if isNewVault && len(password) < minPasswordLength {
+			return "", fmt.Errorf("master password must be at least %d characters long", minPasswordLength)
+		}
+
+		return password, nil
+	}
+
+	if !stdinIsTerminal() {
+		return "", errNoPasswordSource
+	}
+
+	if isNewVault {
+		printTitle("🔐 Create New Vault")
+		fmt.Fprintln(os.Stderr)
+		printInfo("You're creating a new vault. Please choose a strong master password.")
+		printWarning("This password encrypts all your credentials - don't forget it!")
+		fmt.Fprintln(os.Stderr)
 
-			printSuccess("Master password set successfully!")
-			fmt.Println()
-			return password, nil
+		password, err := readNewPassword("Enter master password: ", "Confirm master password: ")
+		if err != nil {
+			return "", err
 		}
+
+		printSuccess("Master password set successfully!")
+		fmt.Fprintln(os.Stderr)
+		return password, nil
 	}
 
 	// Existing vault - just ask for password once
-	password, err := readPassword("Master Password: ")
+	password, err = readPassword("Master Password: ")
 	if err != nil {
 		return "", err
 	}